@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/justtaldevelops/pmf/pmf"
+)
+
+// runDiff runs the diff subcommand, which prints the differences between two PMF worlds.
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	jsonOutput := fs.Bool("json", false, "print the differences as JSON, including every changed block")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pmf diff [-json] <old world> <new world>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected two worlds, got %v", fs.NArg())
+	}
+
+	a, err := pmf.DecodeLevel(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error decoding %v: %w", fs.Arg(0), err)
+	}
+	b, err := pmf.DecodeLevel(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("error decoding %v: %w", fs.Arg(1), err)
+	}
+	d, err := pmf.Diff(a, b)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+	if d.Empty() {
+		fmt.Fprintln(stdout, "The worlds are the same.")
+		return nil
+	}
+	fmt.Fprint(stdout, d)
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/justtaldevelops/pmf/pmf"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestWorld creates a small flat PMF world with a sign and a painting in a directory and returns it.
+func newTestWorld(t *testing.T, dir string) *pmf.Level {
+	t.Helper()
+	p, err := pmf.NewLevel(dir, "test", 0, 2, 8, mgl32.Vec3{8, 5, 8}, pmf.FlatGenerator{Layers: []pmf.Layer{{ID: 1, Count: 4}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetBlockID(cube.Pos{3, 5, 3}, 63); err != nil {
+		t.Fatal(err)
+	}
+	p.SetTile(pmf.SignTile{Pos: cube.Pos{3, 5, 3}, Text: [4]string{"old"}})
+	p.SetEntities([]pmf.Entity{{ID: 83, Pos: mgl32.Vec3{20.5, 5, 20.0625}, Data: map[string]interface{}{"Motive": "Kebab", "Direction": 0}}})
+	if err := p.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// runCommand runs a subcommand and returns what it printed.
+func runCommand(t *testing.T, run func(args []string) error, args ...string) string {
+	t.Helper()
+	buf, previous := &bytes.Buffer{}, stdout
+	stdout = buf
+	defer func() {
+		stdout = previous
+	}()
+	if err := run(args); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// TestRunDiff tests that the diff subcommand prints the block, tile and entity edited in one of two identical worlds,
+// as a summary and as JSON.
+func TestRunDiff(t *testing.T) {
+	oldDir, newDir := filepath.Join(t.TempDir(), "old"), filepath.Join(t.TempDir(), "new")
+	newTestWorld(t, oldDir)
+	if out := runCommand(t, runDiff, oldDir, oldDir); out != "The worlds are the same.\n" {
+		t.Errorf("got output %q for the same world", out)
+	}
+
+	p := newTestWorld(t, newDir)
+	if err := p.SetBlockID(cube.Pos{20, 2, 5}, 4); err != nil {
+		t.Fatal(err)
+	}
+	p.SetTile(pmf.SignTile{Pos: cube.Pos{3, 5, 3}, Text: [4]string{"new"}})
+	p.SetEntities(nil)
+	if err := p.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runCommand(t, runDiff, oldDir, newDir)
+	for _, line := range []string{"chunk 1, 0: 1 blocks changed", "tile [3 5 3]: changed Sign", "entity 83 at [20.5 5 20.0625]: removed"} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("output does not contain %q:\n%v", line, out)
+		}
+	}
+
+	var d pmf.LevelDiff
+	if err := json.Unmarshal([]byte(runCommand(t, runDiff, "-json", oldDir, newDir)), &d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Chunks) != 1 || len(d.Chunks[0].Blocks) != 1 || d.Chunks[0].Blocks[0] != (pmf.BlockChange{Pos: cube.Pos{20, 2, 5}, OldID: 1, NewID: 4}) {
+		t.Errorf("got chunks %+v, expected one changed block", d.Chunks)
+	}
+	if len(d.Tiles) != 1 || d.Tiles[0].Old["Text1"] != "old" || d.Tiles[0].New["Text1"] != "new" {
+		t.Errorf("got tiles %+v, expected the changed sign", d.Tiles)
+	}
+	if len(d.Entities) != 1 || d.Entities[0].Added || d.Entities[0].Entity.ID != 83 {
+		t.Errorf("got entities %+v, expected the removed painting", d.Entities)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/justtaldevelops/pmf/pmf"
+	"os"
+)
+
+// runDump runs the dump subcommand, which prints a PMF world as JSON.
+func runDump(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	ndjson := fs.Bool("ndjson", false, "print the world as newline delimited JSON instead of a JSON array")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pmf dump [-ndjson] <world>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one world, got %v", fs.NArg())
+	}
+
+	level, err := pmf.DecodeLevel(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error decoding %v: %w", fs.Arg(0), err)
+	}
+	return level.WriteJSON(stdout, *ndjson)
+}
+
+// runLoad runs the load subcommand, which builds a PMF world from a JSON dump.
+func runLoad(args []string) error {
+	fs := flag.NewFlagSet("load", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pmf load <dump file> <world>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected a dump file and a world, got %v arguments", fs.NArg())
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := pmf.ImportJSON(bufio.NewReader(f), fs.Arg(1)); err != nil {
+		return fmt.Errorf("error loading %v: %w", fs.Arg(0), err)
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ go 1.16
 
 require (
 	github.com/df-mc/dragonfly v0.2.0
+	github.com/df-mc/goleveldb v1.1.8
 	github.com/go-gl/mathgl v1.0.0
-	gopkg.in/yaml.v2 v2.3.0 // indirect
+	github.com/sandertv/gophertunnel v1.14.1
+	gopkg.in/yaml.v2 v2.3.0
 )
@@ -3,30 +3,70 @@ package main
 // Package main implements an example on converting a PMF world to a modern Minecraft Bedrock world.
 
 import (
+	"context"
 	"fmt"
-	"github.com/df-mc/dragonfly/server/world/mcdb"
 	"github.com/justtaldevelops/pmf/pmf"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"time"
 )
 
+// subcommands holds the function that runs every subcommand, by name. Without a subcommand, the example world is
+// converted.
+var subcommands = map[string]func(args []string) error{
+	"diff":  runDiff,
+	"serve": runServe,
+	"dump":  runDump,
+	"load":  runLoad,
+	"signs": runSigns,
+}
+
+// stdout is the writer that subcommands print their output to. Tests replace it to check the output.
+var stdout io.Writer = os.Stdout
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	convertExample()
+}
+
+// convertExample converts the example PMF world to a Bedrock world in the output directory.
+func convertExample() {
 	start := time.Now()
 
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
 	pm, err := pmf.DecodeLevel("example")
 	if err != nil {
 		panic(err)
 	}
-	prov, err := mcdb.New("output")
-	if err != nil {
-		panic(err)
+	for _, w := range pm.Warnings() {
+		fmt.Println("Warning:", w)
 	}
-	err = pm.Convert(prov)
+	var warnings []string
+	err = pm.ConvertWorld(ctx, "output", pmf.ConvertOptions{
+		Checkpoint: pmf.Checkpoint{File: filepath.Join("output", "checkpoint"), WorldDir: "output"},
+		Progress: func(progress pmf.ConvertProgress) {
+			fmt.Printf("\rConverted %v/%v chunks...", progress.ChunksDone, progress.ChunksTotal)
+			warnings = progress.Warnings
+		},
+	})
+	fmt.Println()
 	if err != nil {
 		panic(err)
 	}
-	err = prov.Close()
-	if err != nil {
-		panic(err)
+	for _, w := range warnings {
+		fmt.Println("Warning:", w)
 	}
 
 	fmt.Printf("Converted PMF world in %v!\n", time.Now().Sub(start))
@@ -0,0 +1,125 @@
+package pmf
+
+import (
+	"github.com/df-mc/dragonfly/server/block/cube"
+)
+
+// BiomeRule is a rule used to infer the biome of a column of a level from the blocks at its surface: the highest solid
+// block in the column and the block that players walk through on top of it, if any, such as a flower or a snow
+// layer.
+type BiomeRule struct {
+	// Surface holds the legacy block IDs that the rule matches at the surface of a column. A rule without any IDs
+	// matches every column with blocks in it.
+	Surface []byte
+	// Biome is the ID of the biome given to columns matched by the rule.
+	Biome uint8
+}
+
+// DefaultBiomeRules are the rules used to infer biomes when converting a level without other rules set.
+var DefaultBiomeRules = []BiomeRule{
+	{Surface: []byte{78, 79, 80}, Biome: 12}, // Snow layers, ice and snow: snowy tundra.
+	{Surface: []byte{12, 32, 81}, Biome: 2},  // Sand, dead bushes and cactus: desert.
+	{Surface: []byte{8, 9}, Biome: 0},        // Water: ocean.
+	{Biome: 1},                               // Everything else: plains.
+}
+
+const (
+	// defaultBiome is the biome of columns that no rule matches. It is plains, which was the only biome in PocketMine
+	// when PMF was in use.
+	defaultBiome = 1
+	// biomeRadius is the radius of the square of columns around a column that decides its biome. Inferring the biome
+	// of single columns would give every small pond or patch of sand its own biome.
+	biomeRadius = 2
+)
+
+// InferBiomes infers the biome of every column of the chunk at a chunk position from the blocks at the surface of
+// the level. The rules are checked in order, and the first rule matching the surface of a column decides its
+// biome. Every column then gets the biome found most often in the columns around it, so that only larger areas of
+// snow, sand or water get their own biome. The biomes are indexed by x | z << 4, like a HeightMap. If rules is nil,
+// DefaultBiomeRules are used.
+func (p *Level) InferBiomes(chunkX, chunkZ int, rules []BiomeRule) ([256]uint8, error) {
+	if rules == nil {
+		rules = DefaultBiomeRules
+	}
+	const size = 16 + biomeRadius*2
+	// surface holds the biome of the surface of every column in and around the chunk, or -1 for columns outside the
+	// level or without any blocks.
+	var surface [size][size]int
+	bounds := p.Bounds()
+	for x := 0; x < size; x++ {
+		for z := 0; z < size; z++ {
+			surface[x][z] = -1
+			blockX, blockZ := chunkX<<4+x-biomeRadius, chunkZ<<4+z-biomeRadius
+			if !bounds.Contains(cube.Pos{blockX, 0, blockZ}) {
+				continue
+			}
+			// Torches, flowers and other blocks players walk through do not decide the biome of the ground they stand
+			// on, but snow layers and dead bushes may still match a rule themselves.
+			top, err := p.HighestBlock(blockX, blockZ, 0)
+			if err != nil {
+				return [256]uint8{}, err
+			}
+			if top < 0 {
+				continue
+			}
+			ground, err := p.HighestBlock(blockX, blockZ, IgnoreNonSolid)
+			if err != nil {
+				return [256]uint8{}, err
+			}
+			topID, err := p.BlockID(cube.Pos{blockX, top, blockZ})
+			if err != nil {
+				return [256]uint8{}, err
+			}
+			groundID := topID
+			if ground >= 0 && ground != top {
+				if groundID, err = p.BlockID(cube.Pos{blockX, ground, blockZ}); err != nil {
+					return [256]uint8{}, err
+				}
+			}
+			surface[x][z] = int(surfaceBiome(rules, groundID, topID))
+		}
+	}
+
+	var biomes [256]uint8
+	for x := 0; x < 16; x++ {
+		for z := 0; z < 16; z++ {
+			var votes [256]int
+			for dx := 0; dx <= biomeRadius*2; dx++ {
+				for dz := 0; dz <= biomeRadius*2; dz++ {
+					if b := surface[x+dx][z+dz]; b >= 0 {
+						votes[b]++
+					}
+				}
+			}
+			// Ties are won by the biome of the column itself, and otherwise by the lowest biome ID.
+			best, own := defaultBiome, surface[x+biomeRadius][z+biomeRadius]
+			if own >= 0 {
+				best = own
+			}
+			for b, n := range votes {
+				if n > votes[best] {
+					best = b
+				}
+			}
+			biomes[x|z<<4] = uint8(best)
+		}
+	}
+	return biomes, nil
+}
+
+// surfaceBiome returns the biome of the first rule that matches one of the block IDs at the surface of a column.
+func surfaceBiome(rules []BiomeRule, ids ...byte) uint8 {
+	for _, r := range rules {
+		if len(r.Surface) == 0 {
+			return r.Biome
+		}
+		for _, surfaceID := range r.Surface {
+			for _, id := range ids {
+				if surfaceID == id {
+					return r.Biome
+				}
+			}
+		}
+	}
+	return defaultBiome
+}
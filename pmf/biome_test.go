@@ -0,0 +1,69 @@
+package pmf
+
+import (
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"testing"
+)
+
+// surfaceArea returns blocks with an ID covering an area of the surface of a test level, with its minimum corner at
+// an X and Z.
+func surfaceArea(x, z, sizeX, sizeZ int, id byte) map[cube.Pos][2]byte {
+	blocks := make(map[cube.Pos][2]byte)
+	for dx := 0; dx < sizeX; dx++ {
+		for dz := 0; dz < sizeZ; dz++ {
+			blocks[cube.Pos{x + dx, 4, z + dz}] = [2]byte{id, 0}
+		}
+	}
+	return blocks
+}
+
+// withBlocksOnTop returns the blocks passed with a block with an ID placed on top of each of them.
+func withBlocksOnTop(blocks map[cube.Pos][2]byte, id byte) map[cube.Pos][2]byte {
+	result := make(map[cube.Pos][2]byte, len(blocks)*2)
+	for pos, b := range blocks {
+		result[pos], result[pos.Side(cube.FaceUp)] = b, [2]byte{id, 0}
+	}
+	return result
+}
+
+// TestInferBiomes tests that biomes are inferred from the surface of larger areas, using the rules passed.
+func TestInferBiomes(t *testing.T) {
+	tests := []struct {
+		name   string
+		blocks map[cube.Pos][2]byte
+		rules  []BiomeRule
+		// x and z are the position of the column whose biome is checked.
+		x, z  int
+		biome uint8
+	}{
+		{name: "stone", x: 8, z: 8, biome: 1},
+		{name: "desert", blocks: surfaceArea(2, 2, 6, 6, 12), x: 4, z: 4, biome: 2},
+		{name: "edge of desert", blocks: surfaceArea(2, 2, 6, 6, 12), x: 2, z: 2, biome: 1},
+		{name: "desert across chunks", blocks: surfaceArea(13, 13, 6, 6, 12), x: 16, z: 16, biome: 2},
+		{name: "pond", blocks: surfaceArea(10, 10, 2, 2, 9), x: 10, z: 10, biome: 1},
+		{name: "lake", blocks: surfaceArea(10, 10, 5, 5, 9), x: 12, z: 12, biome: 0},
+		{name: "snow", blocks: surfaceArea(0, 0, 5, 5, 80), x: 0, z: 0, biome: 12},
+		{name: "snow layers", blocks: surfaceArea(0, 0, 5, 5, 78), x: 0, z: 0, biome: 12},
+		{name: "torches on sand", blocks: withBlocksOnTop(surfaceArea(2, 2, 6, 6, 12), 50), x: 4, z: 4, biome: 2},
+		{name: "flowers on sand", blocks: withBlocksOnTop(surfaceArea(2, 2, 6, 6, 12), 38), x: 4, z: 4, biome: 2},
+		{name: "snow layers on grass", blocks: withBlocksOnTop(surfaceArea(2, 2, 6, 6, 2), 78), x: 4, z: 4, biome: 12},
+		{name: "tall grass on stone", blocks: surfaceArea(2, 2, 6, 6, 31), x: 4, z: 4, biome: 1},
+		// At the corner of the level, 6 of the 12 columns around the column are sand, and the column itself decides.
+		{name: "tie", blocks: surfaceArea(0, 0, 3, 2, 12), x: 0, z: 1, biome: 2},
+		{name: "outvoted", blocks: surfaceArea(0, 0, 3, 2, 12), x: 0, z: 2, biome: 1},
+		{name: "custom rules", rules: []BiomeRule{{Surface: []byte{1}, Biome: 5}}, x: 8, z: 8, biome: 5},
+		{name: "no matching rule", rules: []BiomeRule{{Surface: []byte{12}, Biome: 2}}, x: 8, z: 8, biome: 1},
+		{name: "first matching rule", blocks: surfaceArea(2, 2, 6, 6, 12), rules: []BiomeRule{{Biome: 4}, {Surface: []byte{12}, Biome: 2}}, x: 4, z: 4, biome: 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			biomes, err := newTestLevel(t, test.blocks).InferBiomes(test.x>>4, test.z>>4, test.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b := biomes[test.x&0xf|(test.z&0xf)<<4]; b != test.biome {
+				t.Errorf("got biome %v at %v, %v, expected %v", b, test.x, test.z, test.biome)
+			}
+		})
+	}
+}
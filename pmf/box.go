@@ -0,0 +1,49 @@
+package pmf
+
+import "github.com/df-mc/dragonfly/server/block/cube"
+
+// Box is a box of block positions in a level, from Min up to and including Max.
+type Box struct {
+	Min, Max cube.Pos
+}
+
+// Contains checks if a position is inside the box.
+func (b Box) Contains(pos cube.Pos) bool {
+	return pos.X() >= b.Min.X() && pos.X() <= b.Max.X() &&
+		pos.Y() >= b.Min.Y() && pos.Y() <= b.Max.Y() &&
+		pos.Z() >= b.Min.Z() && pos.Z() <= b.Max.Z()
+}
+
+// Empty checks if the box holds no positions at all, which is the case if Max is smaller than Min on any axis.
+func (b Box) Empty() bool {
+	return b.Max.X() < b.Min.X() || b.Max.Y() < b.Min.Y() || b.Max.Z() < b.Min.Z()
+}
+
+// intersect returns the box of positions that are inside both boxes.
+func (b Box) intersect(other Box) Box {
+	return Box{
+		Min: cube.Pos{maxInt(b.Min.X(), other.Min.X()), maxInt(b.Min.Y(), other.Min.Y()), maxInt(b.Min.Z(), other.Min.Z())},
+		Max: cube.Pos{minInt(b.Max.X(), other.Max.X()), minInt(b.Max.Y(), other.Max.Y()), minInt(b.Max.Z(), other.Max.Z())},
+	}
+}
+
+// Bounds returns the box holding all blocks of the level.
+func (p *Level) Bounds() Box {
+	return Box{Max: cube.Pos{int(p.Width)<<4 - 1, 127, int(p.Width)<<4 - 1}}
+}
+
+// minInt returns the smallest of two integers.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt returns the largest of two integers.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
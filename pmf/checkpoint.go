@@ -0,0 +1,197 @@
+package pmf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/df-mc/dragonfly/server/world"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Checkpoint holds the files used to make a conversion resumable.
+type Checkpoint struct {
+	// File, if not empty, is the path of a file in which every chunk written to the provider is recorded, typically
+	// placed in the output directory. If the conversion is interrupted, for example by a crash or by cancelling the
+	// context, converting the same level to the same provider with the same checkpoint file skips all chunks already
+	// written, resulting in the same world as an uninterrupted conversion. The file is removed once the conversion
+	// finishes.
+	// Note that warnings and block counts of skipped chunks are not reported again.
+	File string
+	// WorldDir is the directory of the world that the provider writes to, which must be set if File is set. The
+	// checkpoint file is tied to it, and the database in it is synced to disk before a chunk is recorded, so that a
+	// chunk is never recorded without being stored.
+	WorldDir string
+}
+
+// checkpointMagic starts the header of every checkpoint file.
+const checkpointMagic = "pmf-checkpoint 1"
+
+// checkpoint records which chunks of a conversion have been written to the provider, so that a conversion that was
+// interrupted may be resumed. The file starts with a header line holding an ID of the conversion, and every chunk
+// written is appended to it as an "x z" line. Lines are only valid once their newline has been written.
+type checkpoint struct {
+	f        *os.File
+	worldDir string
+	done     map[world.ChunkPos]bool
+}
+
+// openCheckpoint opens the checkpoint file of a conversion with an ID, as returned by conversionID, reading all
+// chunks recorded in it. The file is created if it does not yet exist, and an error is returned if it was written for
+// a conversion with another ID.
+func openCheckpoint(cfg Checkpoint, id string) (*checkpoint, error) {
+	if cfg.WorldDir == "" {
+		return nil, fmt.Errorf("checkpoint file %v has no world directory", cfg.File)
+	}
+	f, err := os.OpenFile(cfg.File, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	c := &checkpoint{f: f, worldDir: cfg.WorldDir, done: make(map[world.ChunkPos]bool)}
+	if err := c.read(id); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// read reads the header and the chunks recorded in the checkpoint file. A line without a newline at the end of the
+// file was only partially written when the conversion was interrupted, so it is removed and its chunk will simply be
+// converted again.
+func (c *checkpoint) read(id string) error {
+	b, err := io.ReadAll(c.f)
+	if err != nil {
+		return err
+	}
+	header := checkpointMagic + " " + id
+	complete := bytes.LastIndexByte(b, '\n') + 1
+	lines := strings.Split(string(b[:complete]), "\n")
+	lines = lines[:len(lines)-1]
+
+	if len(lines) == 0 {
+		// The file is new, or the conversion was interrupted before the header was written.
+		if err := c.f.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := c.f.WriteAt([]byte(header+"\n"), 0); err != nil {
+			return err
+		}
+		if _, err := c.f.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+		return c.f.Sync()
+	}
+	if lines[0] != header {
+		return fmt.Errorf("checkpoint file %v belongs to another conversion: remove it to start over", c.f.Name())
+	}
+	for i, line := range lines[1:] {
+		pos, ok := parseCheckpointLine(line)
+		if !ok {
+			return fmt.Errorf("checkpoint file %v: invalid line %v %q", c.f.Name(), i+2, line)
+		}
+		c.done[pos] = true
+	}
+	if err := c.f.Truncate(int64(complete)); err != nil {
+		return err
+	}
+	_, err = c.f.Seek(int64(complete), io.SeekStart)
+	return err
+}
+
+// parseCheckpointLine parses an "x z" line of a checkpoint file.
+func parseCheckpointLine(line string) (world.ChunkPos, bool) {
+	fields := strings.Split(line, " ")
+	if len(fields) != 2 {
+		return world.ChunkPos{}, false
+	}
+	x, errX := strconv.ParseInt(fields[0], 10, 32)
+	z, errZ := strconv.ParseInt(fields[1], 10, 32)
+	return world.ChunkPos{int32(x), int32(z)}, errX == nil && errZ == nil
+}
+
+// written checks if the chunk at a position was already written by an earlier conversion.
+func (c *checkpoint) written(pos world.ChunkPos) bool {
+	return c.done[pos]
+}
+
+// record records the chunk at a position as written. The database of the world is synced to disk first, so that
+// the chunk is stored before it is recorded, and the checkpoint file is synced before returning.
+func (c *checkpoint) record(pos world.ChunkPos) error {
+	if err := c.syncWorld(); err != nil {
+		return fmt.Errorf("error syncing world: %w", err)
+	}
+	if _, err := fmt.Fprintf(c.f, "%d %d\n", pos[0], pos[1]); err != nil {
+		return err
+	}
+	c.done[pos] = true
+	return c.f.Sync()
+}
+
+// syncWorld syncs the journal files of the LevelDB database of the world to disk. The provider writes to the journal
+// without syncing it and offers no way to sync it, but every write reaches the file before it returns, so syncing the
+// files directly makes all writes so far durable. Other files of the database are synced by LevelDB itself when they
+// are written, and journal files removed in the meantime had already been compacted into those.
+func (c *checkpoint) syncWorld() error {
+	journals, err := filepath.Glob(filepath.Join(c.worldDir, "db", "*.log"))
+	if err != nil {
+		return err
+	}
+	if len(journals) == 0 {
+		return fmt.Errorf("no database found in %v", c.worldDir)
+	}
+	for _, path := range journals {
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = f.Sync()
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close closes the checkpoint file.
+func (c *checkpoint) close() error {
+	return c.f.Close()
+}
+
+// remove closes and deletes the checkpoint file once a conversion has finished.
+func (c *checkpoint) remove() error {
+	if err := c.f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(c.f.Name())
+}
+
+// conversionID returns an ID of the conversion of levels to the world in a directory, which changes if the path or
+// header of any of the levels, the options they are converted with or the world directory changes. The rewrite
+// function for sign text, which can't be compared, only counts by whether it is set.
+func conversionID(worldDir string, converters []*converter) (string, error) {
+	h := sha256.New()
+	dir, err := filepath.Abs(worldDir)
+	if err != nil {
+		return "", err
+	}
+	_, _ = fmt.Fprintf(h, "world %q\n", dir)
+	for _, c := range converters {
+		levelDir, err := filepath.Abs(c.level.worldPath)
+		if err != nil {
+			return "", err
+		}
+		p, o := c.level, c.opts
+		_, _ = fmt.Fprintf(h, "level %q %q %v %v %v %v %v\n", levelDir, p.Name, p.Seed, p.Width, p.Height, p.Spawn, c.version)
+		_, _ = fmt.Fprintf(h, "options %v %v %v %v %v %v %v %v %v %v\n", o.Offset, o.Crop, o.VoidChunks, o.VoidMargin,
+			o.VoidGenerator, o.BorderWall, o.LimitedWorld, o.BiomeRules, o.StripSignFormatting, o.RewriteSignText != nil)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
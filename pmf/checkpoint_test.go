@@ -0,0 +1,147 @@
+package pmf
+
+import (
+	"context"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestCheckpointRead tests reading checkpoint files, including files with a partially written last line and files
+// of other conversions.
+func TestCheckpointRead(t *testing.T) {
+	header := checkpointMagic + " id\n"
+	tests := []struct {
+		name     string
+		contents string
+		done     map[world.ChunkPos]bool
+		after    string
+		ok       bool
+	}{
+		{name: "new", contents: "", done: map[world.ChunkPos]bool{}, after: header, ok: true},
+		{name: "partial header", contents: "pmf-check", done: map[world.ChunkPos]bool{}, after: header, ok: true},
+		{name: "chunks", contents: header + "5 12\n-1 3\n", done: map[world.ChunkPos]bool{{5, 12}: true, {-1, 3}: true}, after: header + "5 12\n-1 3\n", ok: true},
+		{name: "partial line", contents: header + "5 12\n5 1", done: map[world.ChunkPos]bool{{5, 12}: true}, after: header + "5 12\n", ok: true},
+		{name: "other conversion", contents: checkpointMagic + " other\n5 12\n"},
+		{name: "other version", contents: "pmf-checkpoint 0 id\n"},
+		{name: "invalid line", contents: header + "5 1 2\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "checkpoint")
+			if err := os.WriteFile(path, []byte(test.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cp, err := openCheckpoint(Checkpoint{File: path, WorldDir: t.TempDir()}, "id")
+			if !test.ok {
+				if err == nil {
+					_ = cp.close()
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = cp.close()
+			if !reflect.DeepEqual(cp.done, test.done) {
+				t.Errorf("read chunks %v, expected %v", cp.done, test.done)
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != test.after {
+				t.Errorf("file holds %q after reading, expected %q", b, test.after)
+			}
+		})
+	}
+}
+
+// TestCheckpointResume tests that a conversion resumed after being interrupted results in the same world as a
+// conversion that was not interrupted, and that the checkpoint can't be used for another conversion.
+func TestCheckpointResume(t *testing.T) {
+	p := newTestLevel(t, map[cube.Pos][2]byte{{3, 5, 3}: {63, 4}, {20, 6, 20}: {89, 0}})
+	p.SetTile(SignTile{Pos: cube.Pos{3, 5, 3}, Text: [4]string{"a", "b", "c", "d"}})
+	opts := ConvertOptions{VoidChunks: true, VoidMargin: 1, BorderWall: BorderWallBarrier, Offset: world.ChunkPos{-1, 2}}
+
+	expected := openTestProvider(t)
+	if err := p.ConvertContext(context.Background(), expected, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	opts.Checkpoint = Checkpoint{File: filepath.Join(dir, "checkpoint"), WorldDir: dir}
+	prov, err := mcdb.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted := opts
+	interrupted.Progress = func(progress ConvertProgress) {
+		if progress.ChunksDone == 2 {
+			cancel()
+		}
+	}
+	if err := p.ConvertContext(ctx, prov, interrupted); err != context.Canceled {
+		t.Fatalf("expected the conversion to be cancelled, got %v", err)
+	}
+	if err := prov.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	other := opts
+	other.Offset = world.ChunkPos{}
+	if prov, err = mcdb.New(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ConvertContext(context.Background(), prov, other); err == nil {
+		t.Fatal("expected the checkpoint of another conversion to be rejected")
+	}
+
+	var resumed ConvertProgress
+	opts.Progress = func(progress ConvertProgress) {
+		resumed = progress
+	}
+	if err := p.ConvertContext(context.Background(), prov, opts); err != nil {
+		t.Fatal(err)
+	}
+	defer prov.Close()
+	// The only tile is in the first chunk, which was converted before the interruption and skipped afterwards.
+	if resumed.ChunksDone != 4 || resumed.TilesConverted != 0 {
+		t.Errorf("resumed conversion did %v chunks and %v tiles, expected 4 chunks and no tiles", resumed.ChunksDone, resumed.TilesConverted)
+	}
+	if _, err := os.Stat(opts.Checkpoint.File); !os.IsNotExist(err) {
+		t.Errorf("checkpoint file was not removed: %v", err)
+	}
+
+	for x := int32(-3); x <= 1; x++ {
+		for z := int32(0); z <= 5; z++ {
+			pos := world.ChunkPos{x, z}
+			want, wantOK, err := expected.LoadChunk(pos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, gotOK, err := prov.LoadChunk(pos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wantOK != gotOK {
+				t.Fatalf("chunk %v exists: %v, expected %v", pos, gotOK, wantOK)
+			}
+			if wantOK && !reflect.DeepEqual(chunk.DiskEncode(got, false), chunk.DiskEncode(want, false)) {
+				t.Errorf("chunk %v differs from the uninterrupted conversion", pos)
+			}
+			wantNBT, _ := expected.LoadBlockNBT(pos)
+			gotNBT, _ := prov.LoadBlockNBT(pos)
+			if !reflect.DeepEqual(gotNBT, wantNBT) {
+				t.Errorf("block entities of chunk %v differ: got %v, expected %v", pos, gotNBT, wantNBT)
+			}
+		}
+	}
+}
@@ -1,24 +1,104 @@
 package pmf
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"github.com/df-mc/dragonfly/server/block/cube"
+	"io"
 )
 
+// subChunkSize is the size in bytes of a single sub chunk. Every column of 16 blocks takes up 32 bytes: 16 bytes
+// of block IDs, 8 bytes of metadata and 8 bytes that PocketMine Alpha never writes, which are kept as they are.
+const subChunkSize = 8192
+
 // Chunk is a PMF style chunk.
 type Chunk struct {
 	// subChunks is a map of Y level to sub chunk.
 	subChunks map[uint8][]byte
+	// heightMaps caches the height maps of the chunk by their filter. It is cleared when a block ID is changed.
+	heightMaps map[HeightFilter]HeightMap
+	// modified is true if the chunk was created or changed since it was loaded or last saved, so that Level.Save
+	// only writes the chunks that need it.
+	modified bool
 }
 
-// NewEmptyChunk creates a new empty chunk.
+// NewEmptyChunk creates a new empty chunk. The chunk does not have a chunk file yet, so it is written when its level
+// is saved.
 func NewEmptyChunk() *Chunk {
 	return &Chunk{
 		subChunks: make(map[uint8][]byte),
+		modified:  true,
 	}
 }
 
-// Block gets a block name and properties from a position.
+// decodeChunk decodes the gzip compressed data of a chunk file. The mask passed holds a bit for every sub chunk
+// present in the data, and height is the maximum amount of sub chunks in the chunk.
+func decodeChunk(b []byte, mask uint16, height uint8) (*Chunk, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	// A chunk can never hold more than height sub chunks, so anything past that is either garbage or an attempt to
+	// exhaust memory.
+	limit := int64(height) * subChunkSize
+	result, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(result)) > limit {
+		return nil, fmt.Errorf("decompressed chunk exceeds %v bytes", limit)
+	}
+	buf := bytes.NewBuffer(result)
+
+	subChunks := make(map[uint8][]byte)
+	for y := uint8(0); y < height; y++ {
+		t := uint16(1 << y)
+
+		if (mask & t) == t {
+			sub, err := next(buf, subChunkSize)
+			if err != nil {
+				return nil, fmt.Errorf("sub chunk %v: %w", y, err)
+			}
+			subChunks[y] = append([]byte(nil), sub...)
+		}
+	}
+	return &Chunk{subChunks: subChunks}, nil
+}
+
+// encode encodes the sub chunks of the chunk below the height passed to the gzip compressed contents of a chunk
+// file. The bitmask of the sub chunks written is returned with it.
+func (c *Chunk) encode(height uint8) ([]byte, uint16, error) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	var mask uint16
+	for y := uint8(0); y < height; y++ {
+		sub, ok := c.subChunks[y]
+		if !ok {
+			continue
+		}
+		if _, err := w.Write(sub); err != nil {
+			return nil, 0, err
+		}
+		mask |= 1 << y
+	}
+	if err := w.Close(); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), mask, nil
+}
+
+// mask returns the bitmask of the sub chunks present in the chunk, as stored in the location mappings of a level.
+func (c *Chunk) mask() uint16 {
+	var m uint16
+	for y := range c.subChunks {
+		m |= 1 << y
+	}
+	return m
+}
+
+// Block gets a block name and properties from a position. A chunk does not know the game version of its level, so
+// the conversion table of the newest game version is used. Level.Block uses the table of the level's version.
 func (c *Chunk) Block(pos cube.Pos) (string, map[string]interface{}, error) {
 	id, err := c.BlockID(pos)
 	if err != nil {
@@ -28,21 +108,32 @@ func (c *Chunk) Block(pos cube.Pos) (string, map[string]interface{}, error) {
 	if err != nil {
 		return "", nil, err
 	}
-	converted := conversion[oldBlock{id: id, metadata: metadata}]
+	converted, ok := GameVersionUnknown.block(id, metadata)
+	if !ok {
+		return "", nil, fmt.Errorf("unknown block %v:%v", id, metadata)
+	}
 	return converted.name, converted.properties, nil
 }
 
+// lookupBlock looks up the new block for a legacy ID and metadata value. If the exact metadata value is unknown, the
+// block with metadata 0 is used instead, as unused metadata bits were often left set by legacy servers.
+func lookupBlock(id, metadata byte) (newBlock, bool) {
+	if b, ok := conversion[oldBlock{id: id, metadata: metadata}]; ok {
+		return b, true
+	}
+	b, ok := conversion[oldBlock{id: id}]
+	return b, ok
+}
+
 // SetBlockID sets the block ID at a position.
 func (c *Chunk) SetBlockID(pos cube.Pos, id byte) error {
 	if !validatePos(pos) {
 		return fmt.Errorf("block pos not valid")
 	}
 
-	chunkY := pos.Y() >> 4
-	if chunkY >= len(c.subChunks) {
-		return fmt.Errorf("chunk pos not valid")
-	}
-	c.subChunks[uint8(chunkY)][idIndex(pos)] = id
+	c.subChunk(pos)[idIndex(pos)] = id
+	c.heightMaps = nil
+	c.modified = true
 	return nil
 }
 
@@ -52,11 +143,12 @@ func (c *Chunk) BlockID(pos cube.Pos) (byte, error) {
 		return 0, fmt.Errorf("block pos not valid")
 	}
 
-	chunkY := pos.Y() >> 4
-	if chunkY >= len(c.subChunks) {
-		return 0, fmt.Errorf("chunk pos not valid")
+	sub, ok := c.subChunks[uint8(pos.Y()>>4)]
+	if !ok {
+		// Sub chunks that aren't present are entirely air.
+		return 0, nil
 	}
-	return c.subChunks[uint8(chunkY)][idIndex(pos)], nil
+	return sub[idIndex(pos)], nil
 }
 
 // SetBlockMeta sets the block metadata at a position.
@@ -65,21 +157,19 @@ func (c *Chunk) SetBlockMeta(pos cube.Pos, meta byte) error {
 		return fmt.Errorf("block pos not valid")
 	}
 
-	chunkY := pos.Y() >> 4
-	if chunkY >= len(c.subChunks) {
-		return fmt.Errorf("chunk pos not valid")
-	}
+	sub := c.subChunk(pos)
 
 	meta &= 0x0F
 	metaInd := metaIndex(pos)
-	oldMeta := c.subChunks[uint8(chunkY)][metaInd]
+	oldMeta := sub[metaInd]
 	if (pos.Y() & 1) == 0 {
 		meta = (oldMeta & 0xF0) | meta
 	} else {
 		meta = (meta << 4) | (oldMeta & 0x0F)
 	}
 
-	c.subChunks[uint8(chunkY)][metaInd] = meta
+	sub[metaInd] = meta
+	c.modified = true
 	return nil
 }
 
@@ -89,12 +179,12 @@ func (c *Chunk) BlockMeta(pos cube.Pos) (byte, error) {
 		return 0, fmt.Errorf("block pos not valid")
 	}
 
-	chunkY := pos.Y() >> 4
-	if chunkY >= len(c.subChunks) {
-		return 0, fmt.Errorf("chunk pos not valid")
+	sub, ok := c.subChunks[uint8(pos.Y()>>4)]
+	if !ok {
+		return 0, nil
 	}
 
-	meta := c.subChunks[uint8(chunkY)][metaIndex(pos)]
+	meta := sub[metaIndex(pos)]
 	if (pos.Y() & 1) == 0 {
 		meta = meta & 0x0F
 	} else {
@@ -103,6 +193,17 @@ func (c *Chunk) BlockMeta(pos cube.Pos) (byte, error) {
 	return meta, nil
 }
 
+// subChunk returns the sub chunk a position is in, creating an empty one if it doesn't exist yet.
+func (c *Chunk) subChunk(pos cube.Pos) []byte {
+	chunkY := uint8(pos.Y() >> 4)
+	sub, ok := c.subChunks[chunkY]
+	if !ok {
+		sub = make([]byte, subChunkSize)
+		c.subChunks[chunkY] = sub
+	}
+	return sub
+}
+
 // metaIndex gets the index of the metadata at a position.
 func metaIndex(pos cube.Pos) int {
 	aX, aZ, aY := offset(pos)
@@ -129,5 +230,5 @@ func offset(pos cube.Pos) (int, int, int) {
 
 // validatePos checks if a position is valid.
 func validatePos(pos cube.Pos) bool {
-	return pos.Y() > 127 || pos.Y() < 0 || pos.X() < 0 || pos.Z() < 0 || pos.X() > 255 || pos.Z() > 255
+	return pos.Y() >= 0 && pos.Y() <= 127 && pos.X() >= 0 && pos.Z() >= 0 && pos.X() <= 255 && pos.Z() <= 255
 }
@@ -0,0 +1,201 @@
+package pmf
+
+import "image/color"
+
+// blockColours holds the flat colour of every legacy block ID, used to render and export levels. Blocks whose colour
+// depends on their metadata, such as wool, are handled by BlockColour.
+var blockColours = map[byte]color.RGBA{
+	1:   {R: 125, G: 125, B: 125, A: 255}, // Stone.
+	2:   {R: 95, G: 159, B: 53, A: 255},   // Grass.
+	3:   {R: 134, G: 96, B: 67, A: 255},   // Dirt.
+	4:   {R: 122, G: 122, B: 122, A: 255}, // Cobblestone.
+	5:   {R: 157, G: 128, B: 79, A: 255},  // Planks.
+	6:   {R: 70, G: 120, B: 40, A: 255},   // Sapling.
+	7:   {R: 84, G: 84, B: 84, A: 255},    // Bedrock.
+	8:   {R: 47, G: 67, B: 244, A: 255},   // Flowing water.
+	9:   {R: 47, G: 67, B: 244, A: 255},   // Water.
+	10:  {R: 207, G: 92, B: 15, A: 255},   // Flowing lava.
+	11:  {R: 207, G: 92, B: 15, A: 255},   // Lava.
+	12:  {R: 219, G: 211, B: 160, A: 255}, // Sand.
+	13:  {R: 136, G: 126, B: 126, A: 255}, // Gravel.
+	14:  {R: 143, G: 140, B: 125, A: 255}, // Gold ore.
+	15:  {R: 136, G: 130, B: 127, A: 255}, // Iron ore.
+	16:  {R: 115, G: 115, B: 115, A: 255}, // Coal ore.
+	17:  {R: 102, G: 81, B: 51, A: 255},   // Log.
+	18:  {R: 60, G: 105, B: 40, A: 255},   // Leaves.
+	19:  {R: 195, G: 196, B: 85, A: 255},  // Sponge.
+	20:  {R: 200, G: 220, B: 230, A: 255}, // Glass.
+	21:  {R: 102, G: 112, B: 134, A: 255}, // Lapis ore.
+	22:  {R: 38, G: 67, B: 137, A: 255},   // Lapis block.
+	24:  {R: 216, G: 203, B: 155, A: 255}, // Sandstone.
+	26:  {R: 142, G: 22, B: 22, A: 255},   // Bed.
+	27:  {R: 150, G: 110, B: 60, A: 255},  // Powered rail.
+	30:  {R: 220, G: 220, B: 220, A: 255}, // Cobweb.
+	31:  {R: 80, G: 130, B: 50, A: 255},   // Tall grass.
+	32:  {R: 120, G: 90, B: 50, A: 255},   // Dead bush.
+	37:  {R: 255, G: 236, B: 79, A: 255},  // Dandelion.
+	38:  {R: 200, G: 30, B: 30, A: 255},   // Rose.
+	39:  {R: 150, G: 110, B: 80, A: 255},  // Brown mushroom.
+	40:  {R: 200, G: 40, B: 40, A: 255},   // Red mushroom.
+	41:  {R: 249, G: 212, B: 60, A: 255},  // Gold block.
+	42:  {R: 220, G: 220, B: 220, A: 255}, // Iron block.
+	43:  {R: 160, G: 160, B: 160, A: 255}, // Double stone slab.
+	44:  {R: 160, G: 160, B: 160, A: 255}, // Stone slab.
+	45:  {R: 150, G: 97, B: 83, A: 255},   // Bricks.
+	46:  {R: 219, G: 68, B: 52, A: 255},   // TNT.
+	47:  {R: 117, G: 94, B: 60, A: 255},   // Bookshelf.
+	48:  {R: 90, G: 108, B: 90, A: 255},   // Moss stone.
+	49:  {R: 20, G: 18, B: 30, A: 255},    // Obsidian.
+	50:  {R: 255, G: 200, B: 80, A: 255},  // Torch.
+	51:  {R: 240, G: 120, B: 20, A: 255},  // Fire.
+	53:  {R: 157, G: 128, B: 79, A: 255},  // Oak stairs.
+	54:  {R: 160, G: 115, B: 50, A: 255},  // Chest.
+	56:  {R: 129, G: 140, B: 143, A: 255}, // Diamond ore.
+	57:  {R: 98, G: 237, B: 228, A: 255},  // Diamond block.
+	58:  {R: 120, G: 80, B: 50, A: 255},   // Crafting table.
+	59:  {R: 170, G: 150, B: 60, A: 255},  // Wheat.
+	60:  {R: 100, G: 70, B: 45, A: 255},   // Farmland.
+	61:  {R: 110, G: 110, B: 110, A: 255}, // Furnace.
+	62:  {R: 110, G: 110, B: 110, A: 255}, // Lit furnace.
+	63:  {R: 160, G: 130, B: 80, A: 255},  // Sign.
+	64:  {R: 140, G: 110, B: 70, A: 255},  // Wooden door.
+	65:  {R: 150, G: 120, B: 70, A: 255},  // Ladder.
+	66:  {R: 120, G: 100, B: 80, A: 255},  // Rail.
+	67:  {R: 122, G: 122, B: 122, A: 255}, // Cobblestone stairs.
+	68:  {R: 160, G: 130, B: 80, A: 255},  // Wall sign.
+	71:  {R: 200, G: 200, B: 200, A: 255}, // Iron door.
+	73:  {R: 133, G: 107, B: 107, A: 255}, // Redstone ore.
+	74:  {R: 133, G: 107, B: 107, A: 255}, // Lit redstone ore.
+	78:  {R: 240, G: 250, B: 250, A: 255}, // Snow layer.
+	79:  {R: 145, G: 183, B: 253, A: 255}, // Ice.
+	80:  {R: 240, G: 250, B: 250, A: 255}, // Snow.
+	81:  {R: 85, G: 127, B: 43, A: 255},   // Cactus.
+	82:  {R: 160, G: 166, B: 179, A: 255}, // Clay.
+	83:  {R: 148, G: 192, B: 101, A: 255}, // Sugar cane.
+	85:  {R: 157, G: 128, B: 79, A: 255},  // Fence.
+	86:  {R: 198, G: 118, B: 24, A: 255},  // Pumpkin.
+	87:  {R: 111, G: 54, B: 52, A: 255},   // Netherrack.
+	88:  {R: 81, G: 62, B: 50, A: 255},    // Soul sand.
+	89:  {R: 249, G: 212, B: 156, A: 255}, // Glowstone.
+	91:  {R: 227, G: 144, B: 29, A: 255},  // Jack o'lantern.
+	92:  {R: 230, G: 220, B: 210, A: 255}, // Cake.
+	96:  {R: 126, G: 93, B: 45, A: 255},   // Trapdoor.
+	98:  {R: 122, G: 121, B: 122, A: 255}, // Stone bricks.
+	101: {R: 150, G: 150, B: 150, A: 255}, // Iron bars.
+	102: {R: 200, G: 220, B: 230, A: 255}, // Glass pane.
+	103: {R: 141, G: 145, B: 36, A: 255},  // Melon.
+	104: {R: 110, G: 150, B: 50, A: 255},  // Pumpkin stem.
+	105: {R: 110, G: 150, B: 50, A: 255},  // Melon stem.
+	107: {R: 157, G: 128, B: 79, A: 255},  // Fence gate.
+	108: {R: 150, G: 97, B: 83, A: 255},   // Brick stairs.
+	109: {R: 122, G: 121, B: 122, A: 255}, // Stone brick stairs.
+	112: {R: 44, G: 22, B: 26, A: 255},    // Nether bricks.
+	114: {R: 44, G: 22, B: 26, A: 255},    // Nether brick stairs.
+	128: {R: 216, G: 203, B: 155, A: 255}, // Sandstone stairs.
+	141: {R: 80, G: 140, B: 40, A: 255},   // Carrots.
+	142: {R: 80, G: 140, B: 40, A: 255},   // Potatoes.
+	155: {R: 236, G: 233, B: 226, A: 255}, // Quartz block.
+	156: {R: 236, G: 233, B: 226, A: 255}, // Quartz stairs.
+	157: {R: 157, G: 128, B: 79, A: 255},  // Double wooden slab.
+	158: {R: 157, G: 128, B: 79, A: 255},  // Wooden slab.
+	170: {R: 166, G: 136, B: 38, A: 255},  // Hay bale.
+	172: {R: 152, G: 94, B: 67, A: 255},   // Hardened clay.
+	173: {R: 16, G: 15, B: 15, A: 255},    // Coal block.
+	244: {R: 80, G: 140, B: 40, A: 255},   // Beetroot.
+	245: {R: 110, G: 110, B: 110, A: 255}, // Stonecutter.
+	246: {R: 100, G: 30, B: 40, A: 255},   // Glowing obsidian.
+	247: {R: 80, G: 80, B: 80, A: 255},    // Nether reactor core.
+}
+
+// dyeColours holds the colours of wool, carpet and stained clay, indexed by metadata value.
+var dyeColours = [16]color.RGBA{
+	{R: 233, G: 236, B: 236, A: 255}, // White.
+	{R: 240, G: 118, B: 19, A: 255},  // Orange.
+	{R: 189, G: 68, B: 179, A: 255},  // Magenta.
+	{R: 58, G: 175, B: 217, A: 255},  // Light blue.
+	{R: 248, G: 197, B: 39, A: 255},  // Yellow.
+	{R: 112, G: 185, B: 25, A: 255},  // Lime.
+	{R: 237, G: 141, B: 172, A: 255}, // Pink.
+	{R: 62, G: 68, B: 71, A: 255},    // Grey.
+	{R: 142, G: 142, B: 134, A: 255}, // Light grey.
+	{R: 21, G: 137, B: 145, A: 255},  // Cyan.
+	{R: 121, G: 42, B: 172, A: 255},  // Purple.
+	{R: 53, G: 57, B: 157, A: 255},   // Blue.
+	{R: 114, G: 71, B: 40, A: 255},   // Brown.
+	{R: 84, G: 109, B: 27, A: 255},   // Green.
+	{R: 161, G: 39, B: 34, A: 255},   // Red.
+	{R: 20, G: 21, B: 25, A: 255},    // Black.
+}
+
+// woodColours holds the colours of the planks of every wood type, indexed by the wood type in the metadata.
+var woodColours = [4]color.RGBA{
+	{R: 157, G: 128, B: 79, A: 255},  // Oak.
+	{R: 104, G: 78, B: 47, A: 255},   // Spruce.
+	{R: 196, G: 179, B: 123, A: 255}, // Birch.
+	{R: 154, G: 110, B: 77, A: 255},  // Jungle.
+}
+
+// logColours holds the colours of the bark of every wood type, indexed by the wood type in the metadata.
+var logColours = [4]color.RGBA{
+	{R: 102, G: 81, B: 51, A: 255},   // Oak.
+	{R: 45, G: 28, B: 12, A: 255},    // Spruce.
+	{R: 206, G: 206, B: 201, A: 255}, // Birch.
+	{R: 87, G: 67, B: 26, A: 255},    // Jungle.
+}
+
+// unknownColour is the colour of blocks without a known colour.
+var unknownColour = color.RGBA{R: 200, G: 0, B: 200, A: 255}
+
+// BlockColour returns the flat colour of a block with a legacy ID and metadata value, as used when rendering and
+// exporting levels. False is returned for air and blocks without a known colour, for which a magenta colour is
+// returned instead.
+func BlockColour(id, meta byte) (color.RGBA, bool) {
+	switch id {
+	case 0:
+		return color.RGBA{}, false
+	case 35, 159, 171: // Wool, stained clay and carpet.
+		c := dyeColours[meta&0xf]
+		if id == 159 {
+			// Stained clay is a lot duller than wool.
+			c = color.RGBA{R: c.R/2 + 60, G: c.G/2 + 40, B: c.B/2 + 30, A: 255}
+		}
+		return c, true
+	case 5, 157, 158: // Planks and wooden slabs.
+		return woodColours[meta&0x3], true
+	case 17:
+		return logColours[meta&0x3], true
+	}
+	c, ok := blockColours[id]
+	if !ok {
+		return unknownColour, false
+	}
+	return c, true
+}
+
+// colourMeta returns the bits of a metadata value that the colour of a block with the ID passed depends on, such as
+// the colour of wool. All other bits, such as the direction of stairs, are cleared.
+func colourMeta(id, meta byte) byte {
+	switch id {
+	case 35, 159, 171:
+		return meta & 0xf
+	case 5, 17, 157, 158:
+		return meta & 0x3
+	}
+	return 0
+}
+
+// nonFullBlocks holds the legacy IDs of all blocks that don't fill their whole block, or that can be seen through,
+// and so never hide the faces of the blocks next to them.
+var nonFullBlocks = map[byte]bool{
+	0: true, 6: true, 8: true, 9: true, 10: true, 11: true, 18: true, 20: true, 26: true, 27: true, 30: true,
+	31: true, 32: true, 37: true, 38: true, 39: true, 40: true, 44: true, 50: true, 51: true, 53: true, 59: true,
+	63: true, 64: true, 65: true, 66: true, 67: true, 68: true, 71: true, 78: true, 79: true, 81: true, 83: true,
+	85: true, 92: true, 96: true, 101: true, 102: true, 104: true, 105: true, 107: true, 108: true, 109: true,
+	114: true, 128: true, 141: true, 142: true, 156: true, 158: true, 171: true, 244: true,
+}
+
+// faceHidden checks if the face of a block with the ID passed is hidden by the neighbouring block on that side. A face
+// is hidden by full blocks, and by blocks of the same type, so that the faces between two water blocks are hidden.
+func faceHidden(id, neighbour byte) bool {
+	return neighbour == id || !nonFullBlocks[neighbour]
+}
@@ -1,99 +1,818 @@
 package pmf
 
 import (
+	"context"
 	"fmt"
 	"github.com/df-mc/dragonfly/server/block/cube"
 	"github.com/df-mc/dragonfly/server/world"
 	"github.com/df-mc/dragonfly/server/world/chunk"
 	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"math"
+	"sort"
 )
 
+// ConvertOptions holds options that change the way a Level is converted.
+type ConvertOptions struct {
+	// Progress, if not nil, is called every time a chunk has been converted with the progress of the conversion so
+	// far. It is called from the goroutine that is converting the level.
+	Progress func(progress ConvertProgress)
+	// Checkpoint holds the checkpoint file through which the conversion may be resumed if it is interrupted. By
+	// default, no checkpoint file is used.
+	Checkpoint Checkpoint
+	// VoidChunks specifies if empty chunks should be written for chunks of the level without any blocks, and for
+	// VoidMargin chunks around the level. Targets only generate terrain in chunks that don't exist, so this keeps
+	// the converted world as finite as the original.
+	VoidChunks bool
+	// VoidMargin is the amount of chunks around the level for which empty chunks are written if VoidChunks is true.
+	VoidMargin int
+	// VoidGenerator specifies if the world generator of the converted world should be set to a void generator, so
+	// that no terrain is generated outside the chunks written either. Because the provider cannot store the
+	// generator, it is only applied by Level.ConvertWorld and ConvertBatchWorld.
+	VoidGenerator bool
+	// BorderWall is the wall built around the edge of the level, recreating the finite world of legacy versions,
+	// where players could not walk off the world. The wall is built right outside the level, from the bottom to the
+	// top of the world. By default, no wall is built.
+	BorderWall BorderWall
+	// LimitedWorld specifies if the converted world should be made a limited world with the size of the level, like
+	// the "Old" worlds of Bedrock Edition, which have an invisible border at their edge. Because the provider cannot
+	// store this setting, it is only applied by Level.ConvertWorld and ConvertBatchWorld. Limited worlds need their
+	// own generator, so it cannot be combined with VoidGenerator.
+	LimitedWorld bool
+	// Offset is the offset in chunks at which the level is placed in the converted world. Blocks, block entities,
+	// entities and the spawn position are all moved by it.
+	Offset world.ChunkPos
+	// Crop, if not nil, is the box of blocks of the level that is converted. Blocks and tiles outside of it are left
+	// out, and chunks without any blocks inside of it are not converted at all. The void margin and border wall are
+	// placed around the box instead of around the level.
+	Crop *Box
+	// BiomeRules holds the rules used to infer the biome of every column of the converted world from the blocks at
+	// its surface, as done by Level.InferBiomes. If nil, DefaultBiomeRules are used.
+	BiomeRules []BiomeRule
+	// StripSignFormatting specifies if legacy § formatting codes, such as colours, should be removed from the text of
+	// signs. By default, they are kept, as modern versions still support them.
+	StripSignFormatting bool
+	// RewriteSignText, if not nil, is called with the position and lines of text of every sign after they have been
+	// normalised using NormaliseSignText, and returns the lines written to the converted world. It can be used to
+	// replace outdated text, such as the addresses of servers.
+	RewriteSignText func(pos cube.Pos, lines [4]string) [4]string
+}
+
+// BorderWall is a kind of wall that may be built around the edge of a converted level.
+type BorderWall int
+
+const (
+	// BorderWallNone builds no wall around the level.
+	BorderWallNone BorderWall = iota
+	// BorderWallInvisibleBedrock builds a wall of invisible bedrock around the level, like legacy versions had.
+	BorderWallInvisibleBedrock
+	// BorderWallBarrier builds a wall of barrier blocks around the level.
+	BorderWallBarrier
+)
+
+// block returns the name of the block the wall is built of.
+func (b BorderWall) block() string {
+	switch b {
+	case BorderWallInvisibleBedrock:
+		return "minecraft:invisibleBedrock"
+	case BorderWallBarrier:
+		return "minecraft:barrier"
+	}
+	return "minecraft:air"
+}
+
+// ConvertProgress holds the progress of a conversion.
+type ConvertProgress struct {
+	// ChunksDone is the amount of PMF chunks that have been converted so far.
+	ChunksDone int
+	// ChunksTotal is the total amount of PMF chunks that will be converted.
+	ChunksTotal int
+	// BlocksWritten is the amount of non-air blocks that have been written to the provider so far.
+	BlocksWritten int
+	// TilesConverted is the amount of tiles that have been converted to block entities so far.
+	TilesConverted int
+	// EntitiesConverted is the amount of entities that have been written to the provider so far.
+	EntitiesConverted int
+	// Warnings holds all warnings produced so far, such as unknown blocks or tiles that could not be converted.
+	// Unknown blocks are reported once for every ID and metadata value, along with the amount of blocks replaced.
+	// Every progress holds its own copy of the warnings.
+	Warnings []string
+}
+
 // Convert converts the PMF level to a provider.
 func (p *Level) Convert(prov *mcdb.Provider) error {
-	settings := prov.Settings()
-	settings.Name = p.Name
-	settings.Spawn = cube.Pos{int(p.Spawn.X()), int(p.Spawn.Y()), int(p.Spawn.Z())}
-	settings.Time = int64(p.Time)
-	prov.SaveSettings(settings)
+	return p.ConvertContext(context.Background(), prov, ConvertOptions{})
+}
+
+// ConvertContext converts the PMF level to a provider using the options passed. If the game version of the level is
+// unknown, it is detected first. The context is checked between every chunk, and if it is cancelled, the conversion stops and the error of the context is returned. Chunks that
+// were converted before the cancellation remain written to the provider.
+// VoidGenerator and LimitedWorld cannot be stored by a provider, so an error is returned if either is set: use
+// ConvertWorld to apply them.
+func (p *Level) ConvertContext(ctx context.Context, prov *mcdb.Provider, opts ConvertOptions) error {
+	if err := checkProviderOptions(opts); err != nil {
+		return err
+	}
+	return p.convert(ctx, prov, opts)
+}
+
+// convert converts the PMF level to a provider like ConvertContext, ignoring the options stored in the level.dat.
+func (p *Level) convert(ctx context.Context, prov *mcdb.Provider, opts ConvertOptions) error {
+	c, err := newConverter(p, prov, opts)
+	if err != nil {
+		return err
+	}
+	if opts.Checkpoint.File == "" {
+		c.saveSettings()
+		return c.run(ctx, nil)
+	}
+	id, err := conversionID(opts.Checkpoint.WorldDir, []*converter{c})
+	if err != nil {
+		return err
+	}
+	cp, err := openCheckpoint(opts.Checkpoint, id)
+	if err != nil {
+		return fmt.Errorf("error opening checkpoint: %w", err)
+	}
+	c.saveSettings()
+	if err := c.run(ctx, cp); err != nil {
+		_ = cp.close()
+		return err
+	}
+	return cp.remove()
+}
+
+// BatchLevel is a level that is converted as part of a batch, along with the options it is converted with.
+type BatchLevel struct {
+	Level   *Level
+	Options ConvertOptions
+}
+
+// ConvertBatch converts several levels into a single provider, placing each of them at the Offset in its options,
+// for example side by side. The name, spawn and time of the world are taken from the first level. The chunks of the
+// levels may not overlap, and void margins and border walls never replace chunks of other levels. The checkpoint
+// passed, if its file is not empty, is used for the whole batch in the same way as ConvertOptions.Checkpoint, which
+// is ignored for the levels of a batch. Like ConvertContext, an error is returned if VoidGenerator or LimitedWorld is
+// set for any of the levels: use ConvertBatchWorld to apply them.
+func ConvertBatch(ctx context.Context, prov *mcdb.Provider, levels []BatchLevel, cfg Checkpoint) error {
+	for i, l := range levels {
+		if err := checkProviderOptions(l.Options); err != nil {
+			return fmt.Errorf("level %v: %w", i, err)
+		}
+	}
+	return convertBatch(ctx, prov, levels, cfg)
+}
+
+// convertBatch converts several levels into a single provider like ConvertBatch, ignoring the options stored in the
+// level.dat.
+func convertBatch(ctx context.Context, prov *mcdb.Provider, levels []BatchLevel, cfg Checkpoint) error {
+	batch := &batchState{levelChunks: make(map[world.ChunkPos]bool), marginChunks: make(map[world.ChunkPos]bool)}
+	converters := make([]*converter, 0, len(levels))
+	for i, l := range levels {
+		c, err := newConverter(l.Level, prov, l.Options)
+		if err != nil {
+			return fmt.Errorf("level %v: %w", i, err)
+		}
+		c.batch, c.index = batch, i
+		for _, pos := range c.targetChunks() {
+			if batch.levelChunks[pos] {
+				return fmt.Errorf("level %v overlaps another level at chunk %v", i, pos)
+			}
+			batch.levelChunks[pos] = true
+		}
+		converters = append(converters, c)
+	}
+	if len(converters) == 0 {
+		return nil
+	}
+	var cp *checkpoint
+	if cfg.File != "" {
+		id, err := conversionID(cfg.WorldDir, converters)
+		if err != nil {
+			return err
+		}
+		if cp, err = openCheckpoint(cfg, id); err != nil {
+			return fmt.Errorf("error opening checkpoint: %w", err)
+		}
+	}
+	converters[0].saveSettings()
+	for i, c := range converters {
+		if err := c.run(ctx, cp); err != nil {
+			if cp != nil {
+				_ = cp.close()
+			}
+			return fmt.Errorf("level %v: %w", i, err)
+		}
+	}
+	if cp != nil {
+		return cp.remove()
+	}
+	return nil
+}
+
+// batchState holds the state shared by the converters of a batch.
+type batchState struct {
+	// levelChunks holds the chunks that the levels of the batch are converted to.
+	levelChunks map[world.ChunkPos]bool
+	// marginChunks holds the chunks around levels that have been written so far.
+	marginChunks map[world.ChunkPos]bool
+}
+
+// converter holds the state of a single conversion of a Level to a provider.
+type converter struct {
+	level *Level
+	prov  *mcdb.Provider
+	opts  ConvertOptions
+	// version is the game version whose conversion table is used.
+	version GameVersion
+	// air is the runtime ID of air.
+	air uint32
+	// tiles holds the tiles of the level, grouped by the chunk they are in.
+	tiles map[world.ChunkPos][]Tile
+	// movedEntities holds the entities of the level, moved to their position in the converted world.
+	movedEntities []Entity
+	// entities holds the indices of the moved entities, grouped by the chunk they are in in the converted world.
+	entities map[world.ChunkPos][]int
+	// index is the index of the level in the batch it is converted in, which makes the unique IDs of its entities
+	// differ from those of other levels.
+	index int
+	// area is the box of blocks of the level that is converted.
+	area Box
+	// wall is the runtime ID of the block the border wall is built of.
+	wall uint32
+	// batch is the state of the batch the conversion is part of, or nil if the level is converted on its own.
+	batch *batchState
+	// progress is the progress of the conversion so far, without the warnings for unknown blocks.
+	progress ConvertProgress
+	// unknownBlocks holds the unknown blocks replaced with air so far, by their ID and metadata value.
+	unknownBlocks map[[2]byte]*unknownBlock
+}
+
+// unknownBlock holds the blocks of an unknown ID and metadata value that were replaced with air.
+type unknownBlock struct {
+	// first is the position of the first block found.
+	first cube.Pos
+	// count is the amount of blocks found.
+	count int
+}
 
+// newConverter returns a converter of a level to a provider. If the game version of the level is unknown, it is
+// detected first.
+func newConverter(p *Level, prov *mcdb.Provider, opts ConvertOptions) (*converter, error) {
+	if opts.VoidGenerator && opts.LimitedWorld {
+		return nil, fmt.Errorf("VoidGenerator and LimitedWorld cannot be combined")
+	}
 	airRuntimeID, ok := chunk.StateToRuntimeID("minecraft:air", nil)
 	if !ok {
 		panic("could not find air runtime id")
 	}
+	version, err := p.gameVersion()
+	if err != nil {
+		return nil, fmt.Errorf("error detecting game version: %w", err)
+	}
+	wallRuntimeID, ok := chunk.StateToRuntimeID(opts.BorderWall.block(), nil)
+	if !ok {
+		return nil, fmt.Errorf("could not find runtime id for border wall %v", opts.BorderWall.block())
+	}
+	c := &converter{
+		level:    p,
+		version:  version,
+		prov:     prov,
+		opts:     opts,
+		air:      airRuntimeID,
+		wall:     wallRuntimeID,
+		tiles:    make(map[world.ChunkPos][]Tile),
+		entities: make(map[world.ChunkPos][]int),
+		area:     p.convertArea(opts),
+
+		unknownBlocks: make(map[[2]byte]*unknownBlock),
+	}
+	c.progress.ChunksTotal = len(c.sourceChunks())
+
+	for _, t := range p.Tiles() {
+		pos := t.Position()
+		if !c.area.Contains(pos) {
+			if !p.Bounds().Contains(pos) {
+				c.warn("%v tile at %v is outside of the world", t.ID(), pos)
+			}
+			continue
+		}
+		chunkPos := world.ChunkPos{int32(pos.X() >> 4), int32(pos.Z() >> 4)}
+		c.tiles[chunkPos] = append(c.tiles[chunkPos], t)
+	}
+	c.movedEntities = p.MovedEntities(opts)
+	for i, e := range c.movedEntities {
+		chunkPos := world.ChunkPos{int32(math.Floor(float64(e.Pos.X()))) >> 4, int32(math.Floor(float64(e.Pos.Z()))) >> 4}
+		c.entities[chunkPos] = append(c.entities[chunkPos], i)
+	}
+	return c, nil
+}
+
+// convertArea returns the box of blocks of the level that is converted with the options passed.
+func (p *Level) convertArea(opts ConvertOptions) Box {
+	if opts.Crop == nil {
+		return p.Bounds()
+	}
+	return p.Bounds().intersect(*opts.Crop)
+}
+
+// saveSettings saves the name, spawn position and time of the level to the provider.
+func (c *converter) saveSettings() {
+	settings := c.prov.Settings()
+	settings.Name = c.level.Name
+	settings.Spawn = c.target(cube.Pos{int(c.level.Spawn.X()), int(c.level.Spawn.Y()), int(c.level.Spawn.Z())})
+	settings.Time = int64(c.level.Time)
+	c.prov.SaveSettings(settings)
+}
+
+// run converts the chunks of the level and writes the chunks around it.
+func (c *converter) run(ctx context.Context, cp *checkpoint) error {
+	if err := c.convertChunks(ctx, cp); err != nil {
+		return err
+	}
+	return c.writeMargin(ctx)
+}
+
+// target returns the position in the converted world of a block of the level.
+func (c *converter) target(pos cube.Pos) cube.Pos {
+	return pos.Add(cube.Pos{int(c.opts.Offset.X()) << 4, 0, int(c.opts.Offset.Z()) << 4})
+}
+
+// sourceChunks returns the positions of all chunks of the level that hold blocks inside the converted area.
+func (c *converter) sourceChunks() []world.ChunkPos {
+	if c.area.Empty() {
+		return nil
+	}
+	var positions []world.ChunkPos
+	for x := c.area.Min.X() >> 4; x <= c.area.Max.X()>>4; x++ {
+		for z := c.area.Min.Z() >> 4; z <= c.area.Max.Z()>>4; z++ {
+			positions = append(positions, world.ChunkPos{int32(x), int32(z)})
+		}
+	}
+	return positions
+}
+
+// targetChunks returns the positions in the converted world of all chunks returned by sourceChunks.
+func (c *converter) targetChunks() []world.ChunkPos {
+	positions := c.sourceChunks()
+	for i, pos := range positions {
+		positions[i] = world.ChunkPos{pos.X() + c.opts.Offset.X(), pos.Z() + c.opts.Offset.Z()}
+	}
+	return positions
+}
+
+// warn adds a warning to the progress of the conversion.
+func (c *converter) warn(format string, a ...interface{}) {
+	c.progress.Warnings = append(c.progress.Warnings, fmt.Sprintf(format, a...))
+}
 
-	chunks := make(map[world.ChunkPos]*chunk.Chunk)
-	for x := 0; x < 256; x++ {
-		for z := 0; z < 256; z++ {
+// warnUnknownBlock counts an unknown block that was replaced with air. Unknown blocks are grouped by their ID and
+// metadata value, as a world may hold millions of them.
+func (c *converter) warnUnknownBlock(id, meta byte, pos cube.Pos) {
+	b, ok := c.unknownBlocks[[2]byte{id, meta}]
+	if !ok {
+		b = &unknownBlock{first: pos}
+		c.unknownBlocks[[2]byte{id, meta}] = b
+	}
+	b.count++
+}
+
+// report returns the progress of the conversion so far, with a copy of the warnings that the converter does not
+// change afterwards, followed by a warning for every unknown ID and metadata value sorted by ID and metadata.
+func (c *converter) report() ConvertProgress {
+	progress := c.progress
+	progress.Warnings = make([]string, len(c.progress.Warnings), len(c.progress.Warnings)+len(c.unknownBlocks))
+	copy(progress.Warnings, c.progress.Warnings)
+
+	keys := make([][2]byte, 0, len(c.unknownBlocks))
+	for k := range c.unknownBlocks {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || (keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1])
+	})
+	for _, k := range keys {
+		b := c.unknownBlocks[k]
+		if b.count == 1 {
+			progress.Warnings = append(progress.Warnings, fmt.Sprintf("unknown block %v:%v at %v was replaced with air", k[0], k[1], b.first))
+			continue
+		}
+		progress.Warnings = append(progress.Warnings, fmt.Sprintf("%v unknown blocks %v:%v, first at %v, were replaced with air", b.count, k[0], k[1], b.first))
+	}
+	return progress
+}
+
+// convertChunks converts all chunks of the level, checking the context between every chunk. If the checkpoint passed
+// is not nil, chunks already recorded in it are skipped and every chunk converted is recorded.
+func (c *converter) convertChunks(ctx context.Context, cp *checkpoint) error {
+	for _, pos := range c.sourceChunks() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Chunks are recorded by their position in the converted world, which is unique even in a batch.
+		targetPos := world.ChunkPos{pos.X() + c.opts.Offset.X(), pos.Z() + c.opts.Offset.Z()}
+		if cp == nil || !cp.written(targetPos) {
+			if err := c.convertChunk(pos); err != nil {
+				return err
+			}
+			if cp != nil {
+				if err := cp.record(targetPos); err != nil {
+					return fmt.Errorf("error writing checkpoint: %w", err)
+				}
+			}
+		}
+		c.progress.ChunksDone++
+		if c.opts.Progress != nil {
+			c.opts.Progress(c.report())
+		}
+	}
+	return nil
+}
+
+// convertChunk converts the PMF chunk at a position and writes it, along with its block entities, to the provider
+// at its position in the converted world. Chunks that are entirely air are not written.
+func (c *converter) convertChunk(pos world.ChunkPos) error {
+	ch, blockEntities, err := c.buildChunk(pos)
+	if err != nil {
+		return err
+	}
+	targetPos := world.ChunkPos{pos.X() + c.opts.Offset.X(), pos.Z() + c.opts.Offset.Z()}
+	if c.opts.BorderWall != BorderWallNone {
+		// A cropped area does not have to line up with chunks, so the wall may run through the chunks of the level.
+		withWall := ch
+		if withWall == nil {
+			withWall = c.emptyChunk()
+		}
+		if c.buildWall(targetPos, withWall) {
+			ch = withWall
+		}
+	}
+	if ch == nil {
+		if c.opts.VoidChunks {
+			if err := c.prov.SaveChunk(targetPos, c.emptyChunk()); err != nil {
+				return err
+			}
+		}
+		return c.saveEntities(targetPos)
+	}
+	for _, data := range blockEntities {
+		x, _ := data["x"].(int32)
+		z, _ := data["z"].(int32)
+		data["x"], data["z"] = x+c.opts.Offset.X()<<4, z+c.opts.Offset.Z()<<4
+		if pairX, ok := data["pairx"].(int32); ok {
+			pairZ, _ := data["pairz"].(int32)
+			data["pairx"], data["pairz"] = pairX+c.opts.Offset.X()<<4, pairZ+c.opts.Offset.Z()<<4
+		}
+	}
+	if err := c.setBiomes(pos, ch); err != nil {
+		return err
+	}
+	if err := c.prov.SaveChunk(targetPos, ch); err != nil {
+		return err
+	}
+	if err := c.prov.SaveBlockNBT(targetPos, blockEntities); err != nil {
+		return err
+	}
+	return c.saveEntities(targetPos)
+}
+
+// saveEntities converts the entities of the level in the chunk at a position in the converted world and writes them
+// to the provider. Entities that are not supported are left out with a warning.
+func (c *converter) saveEntities(pos world.ChunkPos) error {
+	indices := c.entities[pos]
+	if len(indices) == 0 {
+		return nil
+	}
+	entities := make([]world.SaveableEntity, 0, len(indices))
+	for _, i := range indices {
+		e := c.movedEntities[i]
+		converted, ok := convertEntity(e, int64(c.index)<<32|int64(i+1))
+		if !ok {
+			c.warn("entity %v at %v is not supported and was not converted", e.ID, e.Pos)
+			continue
+		}
+		entities = append(entities, converted)
+	}
+	c.progress.EntitiesConverted += len(entities)
+	return c.prov.SaveEntities(pos, entities)
+}
+
+// setBiomes sets the biomes of a converted chunk to the biomes inferred from the surface of the PMF chunk at a
+// position.
+func (c *converter) setBiomes(pos world.ChunkPos, ch *chunk.Chunk) error {
+	biomes, err := c.level.InferBiomes(int(pos.X()), int(pos.Z()), c.opts.BiomeRules)
+	if err != nil {
+		return err
+	}
+	for i, b := range biomes {
+		ch.SetBiomeID(uint8(i&0xf), uint8(i>>4), b)
+	}
+	return nil
+}
+
+// buildChunk converts the PMF chunk at a position to a modern chunk and the block entities in it. If the chunk is
+// entirely air, the chunk returned is nil.
+func (c *converter) buildChunk(pos world.ChunkPos) (*chunk.Chunk, []map[string]interface{}, error) {
+	pm, err := c.level.Chunk(int(pos.X()), int(pos.Z()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ch *chunk.Chunk
+	// reactors holds the metadata of every nether reactor core in the chunk, as their state is kept in the block
+	// entity instead of the block in modern versions.
+	reactors := make(map[cube.Pos]byte)
+	// multiBlocks holds the positions of all blocks that are part of a multi block structure, which are fixed up
+	// once all other blocks of the chunk have been converted.
+	var multiBlocks []cube.Pos
+	baseX, baseZ := int(pos.X())<<4, int(pos.Z())<<4
+	for x := baseX; x < baseX+16; x++ {
+		for z := baseZ; z < baseZ+16; z++ {
 			for y := 0; y < 128; y++ {
-				name, properties, err := p.Block(cube.Pos{x, y, z})
+				blockPos := cube.Pos{x, y, z}
+				if !c.area.Contains(blockPos) {
+					continue
+				}
+				id, err := pm.BlockID(blockPos)
 				if err != nil {
-					panic(err)
+					return nil, nil, err
 				}
-				if name == "minecraft:air" {
+				meta, err := pm.BlockMeta(blockPos)
+				if err != nil {
+					return nil, nil, err
+				}
+				b, ok := c.version.block(id, meta)
+				if !ok {
+					c.warnUnknownBlock(id, meta, blockPos)
+					continue
+				}
+				if b.name == "minecraft:air" {
 					continue
 				}
+				if b.name == "minecraft:netherreactor" {
+					reactors[blockPos] = meta
+				}
+				if _, ok := multiBlockFixers[id]; ok {
+					multiBlocks = append(multiBlocks, blockPos)
+				}
 
-				chunkPos := world.ChunkPos{int32(x >> 4), int32(z >> 4)}
-				ch, ok := chunks[chunkPos]
-				if !ok {
-					ch = chunk.New(airRuntimeID)
-					for x := uint8(0); x < 17; x++ {
-						for z := uint8(0); z < 17; z++ {
-							ch.SetBiomeID(x, z, 1) // The only biome in PM when PMF was a thing was plains.
-						}
-					}
-
-					chunks[chunkPos] = ch
+				if ch == nil {
+					ch = c.emptyChunk()
 				}
 
-				rid, ok := chunk.StateToRuntimeID(name, properties)
+				rid, ok := chunk.StateToRuntimeID(b.name, b.properties)
 				if !ok {
-					panic(fmt.Errorf("could not find runtime id for state: %v, %v", name, properties))
+					return nil, nil, fmt.Errorf("could not find runtime id for state: %v, %v", b.name, b.properties)
 				}
 
 				ch.SetRuntimeID(uint8(x), int16(y), uint8(z), 0, rid)
+				c.progress.BlocksWritten++
 			}
 		}
 	}
+	if ch == nil {
+		return nil, nil, nil
+	}
 
-	blockEntities := make(map[world.ChunkPos][]map[string]interface{})
-	for _, t := range p.tiles {
-		tileType := t["id"]
-
-		switch tileType {
-		case "Sign":
-			x, y, z := t["x"].(int), t["y"].(int), t["z"].(int)
-
-			chunkPos := world.ChunkPos{int32(x >> 4), int32(z >> 4)}
-			if _, ok := blockEntities[chunkPos]; !ok {
-				blockEntities[chunkPos] = []map[string]interface{}{}
-			}
-
-			textOne, textTwo, textThree, textFour := t["Text1"].(string), t["Text2"].(string), t["Text3"].(string), t["Text4"].(string)
+	blockEntities, err := c.fixMultiBlocks(ch, multiBlocks)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, t := range c.tiles[pos] {
+		tilePos := t.Position()
 
+		switch t := t.(type) {
+		case SignTile:
 			data := map[string]interface{}{
 				"id":                          "Sign",
 				"SignTextColor":               int32(-0x1000000),
 				"IgnoreLighting":              boolByte(false),
 				"TextIgnoreLegacyBugResolved": boolByte(false),
-				"Text":                        textOne + "\n" + textTwo + "\n" + textThree + "\n" + textFour,
+				"Text":                        signText(t, c.opts),
 			}
-			data["x"], data["y"], data["z"] = int32(x), int32(y), int32(z)
+			data["x"], data["y"], data["z"] = int32(tilePos.X()), int32(tilePos.Y()), int32(tilePos.Z())
 
-			blockEntities[chunkPos] = append(blockEntities[chunkPos], data)
+			blockEntities = append(blockEntities, data)
+		case NetherReactorTile:
+			meta, ok := reactors[tilePos]
+			if !ok {
+				// The nether reactor core the tile belonged to is gone, so there's nothing to attach it to.
+				c.warn("NetherReactor tile at %v has no nether reactor core", tilePos)
+				continue
+			}
+			delete(reactors, tilePos)
+			blockEntities = append(blockEntities, netherReactorData(t, meta))
+		case ChestTile:
+			blockEntities = append(blockEntities, c.chestData(t))
+		case FurnaceTile:
+			blockEntities = append(blockEntities, c.furnaceData(t))
+		default:
+			c.warn("%v tile at %v is not supported and was not converted", t.ID(), tilePos)
+			continue
 		}
+		c.progress.TilesConverted++
 	}
 
-	for pos, ch := range chunks {
-		err := prov.SaveChunk(pos, ch)
-		if err != nil {
+	// Nether reactor cores without a tile still need a block entity to keep the state stored in their metadata.
+	for _, reactorPos := range sortedPositions(reactors) {
+		blockEntities = append(blockEntities, netherReactorData(NetherReactorTile{Pos: reactorPos}, reactors[reactorPos]))
+	}
+
+	return ch, blockEntities, nil
+}
+
+// writeMargin writes the chunks around the converted area: empty chunks in a margin of VoidMargin chunks if
+// VoidChunks is set, and the chunks holding the border wall if one should be built. In a batch, chunks of other
+// levels are left alone, and the wall is added to margin chunks already written for another level.
+func (c *converter) writeMargin(ctx context.Context) error {
+	if c.area.Empty() {
+		return nil
+	}
+	for _, pos := range c.marginChunks() {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
-		err = prov.SaveBlockNBT(pos, blockEntities[pos])
-		if err != nil {
+		ch := c.emptyChunk()
+		if c.batch != nil {
+			if c.batch.levelChunks[pos] {
+				continue
+			}
+			if c.batch.marginChunks[pos] {
+				existing, ok, err := c.prov.LoadChunk(pos)
+				if err != nil {
+					return err
+				}
+				if ok {
+					ch = existing
+				}
+			}
+			c.batch.marginChunks[pos] = true
+		}
+		if c.opts.BorderWall != BorderWallNone {
+			c.buildWall(pos, ch)
+		}
+		if err := c.prov.SaveChunk(pos, ch); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
+
+// marginChunks returns the positions in the converted world of the chunks written around the converted area: a margin
+// of VoidMargin chunks if VoidChunks is set, and at least the first ring of chunks if a border wall is built.
+func (c *converter) marginChunks() []world.ChunkPos {
+	var margin int32
+	if c.opts.VoidChunks {
+		margin = int32(c.opts.VoidMargin)
+	}
+	if c.opts.BorderWall != BorderWallNone && margin < 1 {
+		// The wall is built right outside the area, so it is always in the first ring of chunks around it.
+		margin = 1
+	}
+	min, max := c.target(c.area.Min), c.target(c.area.Max)
+	minX, minZ, maxX, maxZ := int32(min.X()>>4), int32(min.Z()>>4), int32(max.X()>>4), int32(max.Z()>>4)
+	var positions []world.ChunkPos
+	for x := minX - margin; x <= maxX+margin; x++ {
+		for z := minZ - margin; z <= maxZ+margin; z++ {
+			if x >= minX && x <= maxX && z >= minZ && z <= maxZ {
+				// Chunks of the level itself are not part of the margin.
+				continue
+			}
+			positions = append(positions, world.ChunkPos{x, z})
+		}
+	}
+	return positions
+}
+
+// buildWall builds the part of the border wall that is in the chunk at the position passed in the converted world,
+// and reports if any of it was.
+func (c *converter) buildWall(pos world.ChunkPos, ch *chunk.Chunk) bool {
+	built := false
+	for x := uint8(0); x < 16; x++ {
+		for z := uint8(0); z < 16; z++ {
+			if !c.onWall(int(pos.X())<<4+int(x), int(pos.Z())<<4+int(z)) {
+				continue
+			}
+			for y := int16(cube.MinY); y <= cube.MaxY; y++ {
+				ch.SetRuntimeID(x, y, z, 0, c.wall)
+			}
+			built = true
+		}
+	}
+	return built
+}
+
+// onWall checks if the column at an X and Z in the converted world is part of the border wall, which surrounds the
+// converted area one block outside of its edge.
+func (c *converter) onWall(x, z int) bool {
+	min, max := c.target(c.area.Min), c.target(c.area.Max)
+	if x < min.X()-1 || x > max.X()+1 || z < min.Z()-1 || z > max.Z()+1 {
+		return false
+	}
+	return x == min.X()-1 || x == max.X()+1 || z == min.Z()-1 || z == max.Z()+1
+}
+
+// emptyChunk returns a new chunk without any blocks.
+func (c *converter) emptyChunk() *chunk.Chunk {
+	ch := chunk.New(c.air)
+	for x := uint8(0); x < 16; x++ {
+		for z := uint8(0); z < 16; z++ {
+			ch.SetBiomeID(x, z, defaultBiome)
+		}
+	}
+	return ch
+}
+
+// netherReactorData returns the block entity data of a nether reactor core. Legacy versions stored the look of the
+// reactor in the block metadata (0 for a fresh core, 1 for an activated core and 2 for a burnt out core), while the
+// tile only held the progress. Modern versions have a single nether reactor block without states, and the state is
+// only kept in the block entity, so the metadata is merged into the tile state: a burnt out core is always both
+// initialised and finished, and an activated core is always initialised.
+func netherReactorData(t NetherReactorTile, meta byte) map[string]interface{} {
+	finished := t.HasFinished || meta == 2
+	initialised := t.IsInitialized || finished || meta == 1
+
+	pos := t.Position()
+	return map[string]interface{}{
+		"id":            "NetherReactor",
+		"IsInitialized": boolByte(initialised),
+		"HasFinished":   boolByte(finished),
+		"Progress":      int16(t.Progress),
+		"x":             int32(pos.X()),
+		"y":             int32(pos.Y()),
+		"z":             int32(pos.Z()),
+	}
+}
+
+// chestData returns the block entity data of a chest. A paired chest keeps its pair, and the chest with the lowest
+// position of the two leads the pair.
+func (c *converter) chestData(t ChestTile) map[string]interface{} {
+	pos := t.Position()
+	data := map[string]interface{}{
+		"id":       "Chest",
+		"Findable": boolByte(false),
+		"Items":    c.itemsData(t.Items, pos),
+		"x":        int32(pos.X()),
+		"y":        int32(pos.Y()),
+		"z":        int32(pos.Z()),
+	}
+	if t.Paired {
+		pair := cube.Pos{t.PairX, pos.Y(), t.PairZ}
+		data["pairx"], data["pairz"] = int32(pair.X()), int32(pair.Z())
+		data["pairlead"] = boolByte(posLess(pos, pair))
+	}
+	return data
+}
+
+// furnaceData returns the block entity data of a furnace.
+func (c *converter) furnaceData(t FurnaceTile) map[string]interface{} {
+	pos := t.Position()
+	return map[string]interface{}{
+		"id":           "Furnace",
+		"Items":        c.itemsData(t.Items, pos),
+		"BurnTime":     int16(t.BurnTime),
+		"CookTime":     int16(t.CookTime),
+		"BurnDuration": int16(t.MaxTime),
+		"StoredXPInt":  int32(0),
+		"x":            int32(pos.X()),
+		"y":            int32(pos.Y()),
+		"z":            int32(pos.Z()),
+	}
+}
+
+// itemsData returns the block entity data of the items in the inventory of the tile at a position, converted like
+// LookupItem using the table of the game version of the conversion. Items that are unknown or empty are left out with
+// a warning.
+func (c *converter) itemsData(items []Item, pos cube.Pos) []interface{} {
+	l := make([]interface{}, 0, len(items))
+	for _, it := range items {
+		if it.Count <= 0 || it.Slot < 0 || it.Slot > 0xff {
+			c.warn("invalid item %v:%v in slot %v of the tile at %v was removed", it.ID, it.Damage, it.Slot, pos)
+			continue
+		}
+		name, meta, ok := lookupItem(c.version, it.ID, it.Damage)
+		if !ok {
+			c.warn("unknown item %v:%v in the tile at %v was removed", it.ID, it.Damage, pos)
+			continue
+		}
+		l = append(l, map[string]interface{}{
+			"Name":        name,
+			"Damage":      meta,
+			"Count":       byte(minInt(it.Count, 0xff)),
+			"Slot":        byte(it.Slot),
+			"WasPickedUp": boolByte(false),
+		})
+	}
+	return l
+}
+
+// sortedPositions returns the keys of a map of positions, sorted by X, Z and Y.
+func sortedPositions(m map[cube.Pos]byte) []cube.Pos {
+	positions := make([]cube.Pos, 0, len(m))
+	for pos := range m {
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return posLess(positions[i], positions[j])
+	})
+	return positions
+}
@@ -0,0 +1,466 @@
+package pmf
+
+import (
+	"bytes"
+	"context"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/opt"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/go-gl/mathgl/mgl64"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newTestLevel creates a level of 2x2 chunks in a temporary directory, filled with stone up to Y 4 and the blocks
+// passed.
+func newTestLevel(t *testing.T, blocks map[cube.Pos][2]byte) *Level {
+	t.Helper()
+	gen, err := ParsePreset("1,3x1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewLevel(t.TempDir(), "test", 0, 2, 8, mgl32.Vec3{8, 5, 8}, gen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pos, b := range blocks {
+		if err := p.SetBlockID(pos, b[0]); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.SetBlockMeta(pos, b[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p
+}
+
+// openTestProvider opens a provider in a temporary directory, which is closed when the test finishes.
+func openTestProvider(t *testing.T) *mcdb.Provider {
+	t.Helper()
+	prov, err := mcdb.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = prov.Close()
+	})
+	return prov
+}
+
+// blockEntityAt returns the block entity at a position in the provider, or nil if there is none.
+func blockEntityAt(t *testing.T, prov *mcdb.Provider, pos cube.Pos) map[string]interface{} {
+	t.Helper()
+	blockEntities, err := prov.LoadBlockNBT(chunkPosOf(pos))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range blockEntities {
+		if data["x"] == int32(pos.X()) && data["y"] == int32(pos.Y()) && data["z"] == int32(pos.Z()) {
+			return data
+		}
+	}
+	return nil
+}
+
+// TestConvertContainers tests that chests and furnaces are converted with their items.
+func TestConvertContainers(t *testing.T) {
+	chest, pair, furnace := cube.Pos{3, 4, 3}, cube.Pos{4, 4, 3}, cube.Pos{20, 4, 5}
+	p := newTestLevel(t, map[cube.Pos][2]byte{chest: {54, 2}, pair: {54, 2}, furnace: {61, 3}})
+	p.SetTile(ChestTile{Pos: chest, Paired: true, PairX: 4, PairZ: 3, Items: []Item{
+		{ID: 264, Count: 3, Slot: 0},           // Diamonds.
+		{ID: 5, Damage: 2, Count: 64, Slot: 1}, // Birch planks.
+		{ID: 30000, Count: 1, Slot: 2},         // Unknown.
+	}})
+	p.SetTile(ChestTile{Pos: pair, Paired: true, PairX: 3, PairZ: 3})
+	p.SetTile(FurnaceTile{Pos: furnace, BurnTime: 100, CookTime: 50, MaxTime: 200, Items: []Item{{ID: 15, Count: 2}}})
+
+	prov := openTestProvider(t)
+	var progress ConvertProgress
+	err := p.ConvertContext(context.Background(), prov, ConvertOptions{Offset: world.ChunkPos{1, 0}, Progress: func(pr ConvertProgress) {
+		progress = pr
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.TilesConverted != 3 {
+		t.Errorf("converted %v tiles, expected 3", progress.TilesConverted)
+	}
+
+	tests := []struct {
+		name string
+		pos  cube.Pos
+		want map[string]interface{}
+	}{
+		{name: "chest", pos: chest, want: map[string]interface{}{
+			"id": "Chest", "Findable": byte(0), "x": int32(19), "y": int32(4), "z": int32(3),
+			"pairx": int32(20), "pairz": int32(3), "pairlead": byte(1),
+			"Items": []interface{}{
+				map[string]interface{}{"Name": "minecraft:diamond", "Damage": int16(0), "Count": byte(3), "Slot": byte(0), "WasPickedUp": byte(0)},
+				map[string]interface{}{"Name": "minecraft:planks", "Damage": int16(2), "Count": byte(64), "Slot": byte(1), "WasPickedUp": byte(0)},
+			},
+		}},
+		{name: "pair", pos: pair, want: map[string]interface{}{
+			"id": "Chest", "Findable": byte(0), "x": int32(20), "y": int32(4), "z": int32(3),
+			"pairx": int32(19), "pairz": int32(3), "pairlead": byte(0), "Items": []interface{}{},
+		}},
+		{name: "furnace", pos: furnace, want: map[string]interface{}{
+			"id": "Furnace", "x": int32(36), "y": int32(4), "z": int32(5),
+			"BurnTime": int16(100), "CookTime": int16(50), "BurnDuration": int16(200), "StoredXPInt": int32(0),
+			"Items": []interface{}{
+				map[string]interface{}{"Name": "minecraft:iron_ore", "Damage": int16(0), "Count": byte(2), "Slot": byte(0), "WasPickedUp": byte(0)},
+			},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := blockEntityAt(t, prov, test.pos.Add(cube.Pos{16, 0, 0}))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got block entity\n%#v\nexpected\n%#v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestConvertNetherReactor tests that nether reactor cores are converted with a block entity holding the state of
+// their tile, or the state stored in their metadata if they have no tile.
+func TestConvertNetherReactor(t *testing.T) {
+	tests := []struct {
+		name string
+		meta byte
+		tile *NetherReactorTile
+		// core is false if there is no nether reactor core at the position of the tile.
+		core bool
+		want map[string]interface{}
+	}{
+		{name: "active", tile: &NetherReactorTile{IsInitialized: true, Progress: 300}, core: true, want: map[string]interface{}{
+			"IsInitialized": byte(1), "HasFinished": byte(0), "Progress": int16(300),
+		}},
+		{name: "unused", tile: &NetherReactorTile{}, core: true, want: map[string]interface{}{
+			"IsInitialized": byte(0), "HasFinished": byte(0), "Progress": int16(0),
+		}},
+		{name: "finished metadata", meta: 2, tile: &NetherReactorTile{Progress: 900}, core: true, want: map[string]interface{}{
+			"IsInitialized": byte(1), "HasFinished": byte(1), "Progress": int16(900),
+		}},
+		{name: "initialised metadata without tile", meta: 1, core: true, want: map[string]interface{}{
+			"IsInitialized": byte(1), "HasFinished": byte(0), "Progress": int16(0),
+		}},
+		{name: "tile without core", tile: &NetherReactorTile{IsInitialized: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pos := cube.Pos{5, 4, 6}
+			var blocks map[cube.Pos][2]byte
+			if test.core {
+				blocks = map[cube.Pos][2]byte{pos: {247, test.meta}}
+			}
+			p := newTestLevel(t, blocks)
+			if test.tile != nil {
+				test.tile.Pos = pos
+				p.SetTile(*test.tile)
+			}
+			prov := openTestProvider(t)
+			var progress ConvertProgress
+			err := p.ConvertContext(context.Background(), prov, ConvertOptions{Progress: func(pr ConvertProgress) {
+				progress = pr
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := blockEntityAt(t, prov, pos)
+			if test.want == nil {
+				if got != nil {
+					t.Errorf("got block entity %v, expected none", got)
+				}
+				if len(progress.Warnings) != 1 {
+					t.Errorf("got warnings %v, expected a warning for the tile", progress.Warnings)
+				}
+				return
+			}
+			if name := blockNameAt(t, prov, pos); name != "minecraft:netherreactor" {
+				t.Errorf("got block %v, expected minecraft:netherreactor", name)
+			}
+			want := map[string]interface{}{"id": "NetherReactor", "x": int32(5), "y": int32(4), "z": int32(6)}
+			for k, v := range test.want {
+				want[k] = v
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got block entity\n%#v\nexpected\n%#v", got, want)
+			}
+		})
+	}
+}
+
+// TestConvertWarnings tests that unknown blocks are reported once for every ID and metadata value, and that every
+// progress holds its own copy of the warnings.
+func TestConvertWarnings(t *testing.T) {
+	blocks := map[cube.Pos][2]byte{{1, 5, 1}: {210, 2}}
+	for x := 0; x < 32; x++ {
+		blocks[cube.Pos{x, 6, 20}] = [2]byte{210, 1}
+	}
+	p := newTestLevel(t, blocks)
+	p.SetTile(UnknownTile{TileID: "Unknown", Pos: cube.Pos{64, 5, 5}})
+
+	var warnings [][]string
+	err := p.ConvertContext(context.Background(), openTestProvider(t), ConvertOptions{Progress: func(progress ConvertProgress) {
+		warnings = append(warnings, append([]string(nil), progress.Warnings...))
+		// Callbacks may change the warnings they get without changing those of later progress.
+		progress.Warnings[0] = "changed"
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		chunk    int
+		warnings []string
+	}{
+		{chunk: 0, warnings: []string{
+			"Unknown tile at [64 5 5] is outside of the world",
+			"unknown block 210:2 at [1 5 1] was replaced with air",
+		}},
+		{chunk: 1, warnings: []string{
+			"Unknown tile at [64 5 5] is outside of the world",
+			"16 unknown blocks 210:1, first at [0 6 20], were replaced with air",
+			"unknown block 210:2 at [1 5 1] was replaced with air",
+		}},
+		{chunk: 3, warnings: []string{
+			"Unknown tile at [64 5 5] is outside of the world",
+			"32 unknown blocks 210:1, first at [0 6 20], were replaced with air",
+			"unknown block 210:2 at [1 5 1] was replaced with air",
+		}},
+	}
+	for _, test := range tests {
+		if got := warnings[test.chunk]; !reflect.DeepEqual(got, test.warnings) {
+			t.Errorf("chunk %v: got warnings %q, expected %q", test.chunk, got, test.warnings)
+		}
+	}
+}
+
+// chunkPosOf returns the position of the chunk that a block position is in.
+func chunkPosOf(pos cube.Pos) world.ChunkPos {
+	return world.ChunkPos{int32(pos.X() >> 4), int32(pos.Z() >> 4)}
+}
+
+// blockNameAt returns the name of the block at a position in the provider.
+func blockNameAt(t *testing.T, prov *mcdb.Provider, pos cube.Pos) string {
+	t.Helper()
+	ch, ok, err := prov.LoadChunk(chunkPosOf(pos))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		return "minecraft:air"
+	}
+	name, _, _ := chunk.RuntimeIDToState(ch.RuntimeID(uint8(pos.X()), int16(pos.Y()), uint8(pos.Z()), 0))
+	return name
+}
+
+// entitiesAt returns the NBT data of the entities saved in the chunk of a position in the world in the directory
+// passed. Converted entities are not registered, so providers do not load them, and the data is read from the
+// database directly. The provider of the world must be closed first.
+func entitiesAt(t *testing.T, dir string, pos cube.Pos) []map[string]interface{} {
+	t.Helper()
+	db, err := leveldb.OpenFile(filepath.Join(dir, "db"), &opt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	chunkPos := chunkPosOf(pos)
+	x, z := uint32(chunkPos.X()), uint32(chunkPos.Z())
+	key := []byte{byte(x), byte(x >> 8), byte(x >> 16), byte(x >> 24), byte(z), byte(z >> 8), byte(z >> 16), byte(z >> 24), '2'}
+	b, err := db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entities []map[string]interface{}
+	buf := bytes.NewBuffer(b)
+	dec := nbt.NewDecoderWithEncoding(buf, nbt.LittleEndian)
+	for buf.Len() != 0 {
+		var data map[string]interface{}
+		if err := dec.Decode(&data); err != nil {
+			t.Fatal(err)
+		}
+		entities = append(entities, data)
+	}
+	return entities
+}
+
+// newMoveTestLevel creates a test level with a gold block and a sign in the first chunk, and a diamond block with a
+// painting above it in the last chunk, along with a dropped item, which is not converted.
+func newMoveTestLevel(t *testing.T) *Level {
+	t.Helper()
+	p := newTestLevel(t, map[cube.Pos][2]byte{{3, 4, 3}: {41, 0}, {3, 5, 3}: {63, 0}, {20, 4, 20}: {57, 0}})
+	p.SetTile(SignTile{Pos: cube.Pos{3, 5, 3}, Text: [4]string{"moved"}})
+	p.SetEntities([]Entity{
+		{ID: 83, Pos: mgl32.Vec3{20.5, 5, 20.0625}, Data: map[string]interface{}{"Motive": "Kebab", "Direction": 0, "TileX": 20, "TileY": 5, "TileZ": 19}},
+		{ID: 64, Pos: mgl32.Vec3{3.5, 5, 4.5}},
+	})
+	return p
+}
+
+// TestConvertMoved tests that blocks, tiles and entities are moved by the offset of a conversion, and left out if they
+// are outside of the crop box.
+func TestConvertMoved(t *testing.T) {
+	gold, sign, diamond := cube.Pos{3, 4, 3}, cube.Pos{3, 5, 3}, cube.Pos{20, 4, 20}
+	tests := []struct {
+		name string
+		opts ConvertOptions
+		// gold and diamond are the offsets in blocks of the blocks in the first and last chunk, or nil if they are
+		// cropped away.
+		gold, diamond *cube.Pos
+		entities      int
+	}{
+		{name: "in place", gold: &cube.Pos{}, diamond: &cube.Pos{}, entities: 1},
+		{name: "offset", opts: ConvertOptions{Offset: world.ChunkPos{2, -1}}, gold: &cube.Pos{32, 0, -16}, diamond: &cube.Pos{32, 0, -16}, entities: 1},
+		{name: "crop", opts: ConvertOptions{Crop: &Box{Max: cube.Pos{15, 127, 15}}}, gold: &cube.Pos{}},
+		{name: "offset and crop", opts: ConvertOptions{Offset: world.ChunkPos{-1, -1}, Crop: &Box{Min: cube.Pos{16, 0, 16}, Max: cube.Pos{31, 127, 31}}}, diamond: &cube.Pos{-16, 0, -16}, entities: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newMoveTestLevel(t)
+			dir := t.TempDir()
+			prov := openWorld(t, dir)
+			var progress ConvertProgress
+			test.opts.Progress = func(pr ConvertProgress) {
+				progress = pr
+			}
+			if err := p.ConvertContext(context.Background(), prov, test.opts); err != nil {
+				t.Fatal(err)
+			}
+			if progress.EntitiesConverted != test.entities {
+				t.Errorf("converted %v entities, expected %v", progress.EntitiesConverted, test.entities)
+			}
+
+			if test.gold != nil {
+				if name := blockNameAt(t, prov, gold.Add(*test.gold)); name != "minecraft:gold_block" {
+					t.Errorf("got %v at the gold block, expected minecraft:gold_block", name)
+				}
+				if data := blockEntityAt(t, prov, sign.Add(*test.gold)); data == nil || data["Text"] != "moved" {
+					t.Errorf("got sign block entity %v, expected the text moved", data)
+				}
+			}
+			if test.diamond == nil {
+				return
+			}
+			target := diamond.Add(*test.diamond)
+			if name := blockNameAt(t, prov, target); name != "minecraft:diamond_block" {
+				t.Errorf("got %v at the diamond block, expected minecraft:diamond_block", name)
+			}
+			if err := prov.Close(); err != nil {
+				t.Fatal(err)
+			}
+			entities := entitiesAt(t, dir, target)
+			if len(entities) != 1 {
+				t.Fatalf("got entities %v, expected a painting", entities)
+			}
+			want := map[string]interface{}{
+				"identifier": "minecraft:painting",
+				"Pos":        []interface{}{float32(target.X()) + 0.5, float32(5), float32(target.Z()) + 0.0625},
+				"Motion":     []interface{}{float32(0), float32(0), float32(0)},
+				"Rotation":   []interface{}{float32(0), float32(0)},
+				"UniqueID":   int64(1),
+				"Motive":     "Kebab",
+				"Direction":  byte(0),
+			}
+			if !reflect.DeepEqual(entities[0], want) {
+				t.Errorf("got painting\n%#v\nexpected\n%#v", entities[0], want)
+			}
+		})
+	}
+}
+
+// TestEntityDataPosition tests that the position and rotation of converted entities are read from both the data
+// written by convertEntity and data decoded from NBT, and are zero if the data holds none.
+func TestEntityDataPosition(t *testing.T) {
+	converted, _ := convertEntity(Entity{ID: 12, Pos: mgl32.Vec3{1, 2, 3}, Rotation: mgl32.Vec2{90, 45}}, 1)
+	tests := []struct {
+		name       string
+		data       map[string]interface{}
+		pos        mgl64.Vec3
+		yaw, pitch float64
+	}{
+		{name: "converted", data: converted.data, pos: mgl64.Vec3{1, 2, 3}, yaw: 90, pitch: 45},
+		{name: "decoded", data: map[string]interface{}{
+			"Pos":      []interface{}{float32(4), float32(5), float32(6)},
+			"Rotation": []interface{}{float32(180), float32(-10)},
+		}, pos: mgl64.Vec3{4, 5, 6}, yaw: 180, pitch: -10},
+		{name: "no rotation", data: map[string]interface{}{"Pos": []interface{}{float32(4), float32(5), float32(6)}}, pos: mgl64.Vec3{4, 5, 6}},
+		{name: "empty", data: map[string]interface{}{}},
+		{name: "too short", data: map[string]interface{}{"Pos": []float32{1, 2}, "Rotation": []interface{}{float32(1)}}},
+		{name: "wrong types", data: map[string]interface{}{"Pos": []interface{}{1, 2, 3}, "Rotation": "up"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := entityData{identifier: "minecraft:pig"}.DecodeNBT(test.data).(entityData)
+			if pos := e.Position(); pos != test.pos {
+				t.Errorf("got position %v, expected %v", pos, test.pos)
+			}
+			if yaw, pitch := e.Rotation(); yaw != test.yaw || pitch != test.pitch {
+				t.Errorf("got rotation %v, %v, expected %v, %v", yaw, pitch, test.yaw, test.pitch)
+			}
+		})
+	}
+}
+
+// TestConvertBatch tests that levels converted in a batch are placed at their offsets, and that overlapping levels
+// are refused.
+func TestConvertBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		offsets []world.ChunkPos
+		ok      bool
+	}{
+		{name: "side by side", offsets: []world.ChunkPos{{0, 0}, {2, 0}}, ok: true},
+		{name: "diagonal", offsets: []world.ChunkPos{{-2, -2}, {0, 0}, {2, 2}}, ok: true},
+		{name: "overlapping", offsets: []world.ChunkPos{{0, 0}, {1, 1}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var levels []BatchLevel
+			for _, offset := range test.offsets {
+				levels = append(levels, BatchLevel{Level: newMoveTestLevel(t), Options: ConvertOptions{Offset: offset, VoidChunks: true, BorderWall: BorderWallBarrier}})
+			}
+			dir := t.TempDir()
+			prov := openWorld(t, dir)
+			err := ConvertBatch(context.Background(), prov, levels, Checkpoint{})
+			if !test.ok {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, offset := range test.offsets {
+				shift := cube.Pos{int(offset.X()) << 4, 0, int(offset.Z()) << 4}
+				if name := blockNameAt(t, prov, cube.Pos{3, 4, 3}.Add(shift)); name != "minecraft:gold_block" {
+					t.Errorf("level %v: got %v at the gold block, expected minecraft:gold_block", i, name)
+				}
+			}
+			if r, err := VerifyBatch(prov, levels); err != nil || !r.OK() {
+				t.Errorf("batch did not verify: %v\n%v", err, r)
+			}
+			if err := prov.Close(); err != nil {
+				t.Fatal(err)
+			}
+			for i, offset := range test.offsets {
+				shift := cube.Pos{int(offset.X()) << 4, 0, int(offset.Z()) << 4}
+				entities := entitiesAt(t, dir, cube.Pos{20, 4, 20}.Add(shift))
+				if len(entities) != 1 || entities[0]["UniqueID"] != int64(i)<<32|1 {
+					t.Errorf("level %v: got entities %v, expected a painting with unique ID %v", i, entities, int64(i)<<32|1)
+				}
+			}
+		})
+	}
+}
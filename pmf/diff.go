@@ -0,0 +1,315 @@
+package pmf
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// LevelDiff holds the differences between two levels, as returned by Diff.
+type LevelDiff struct {
+	// Header holds the fields of the level.pmf header that differ.
+	Header []FieldChange `json:"header,omitempty"`
+	// Chunks holds the chunks whose sub chunks or blocks differ, sorted by X and Z.
+	Chunks []ChunkDiff `json:"chunks,omitempty"`
+	// Tiles holds the tiles that were added, removed or changed, sorted by position.
+	Tiles []TileChange `json:"tiles,omitempty"`
+	// Entities holds the entities that were added or removed. Entities have no identity in PMF, so an entity that
+	// moved shows up as removed and added again.
+	Entities []EntityChange `json:"entities,omitempty"`
+}
+
+// FieldChange is a field of the level header with a different value in both levels.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// ChunkDiff holds the differences of a single chunk.
+type ChunkDiff struct {
+	X int `json:"x"`
+	Z int `json:"z"`
+	// OldMask and NewMask are the bitmasks of the sub chunks present in the chunk in both levels.
+	OldMask uint16 `json:"old_mask"`
+	NewMask uint16 `json:"new_mask"`
+	// Blocks holds every block whose ID or metadata differs.
+	Blocks []BlockChange `json:"blocks,omitempty"`
+}
+
+// BlockChange is a block with a different ID or metadata value in both levels.
+type BlockChange struct {
+	Pos     cube.Pos `json:"pos"`
+	OldID   byte     `json:"old_id"`
+	OldMeta byte     `json:"old_meta"`
+	NewID   byte     `json:"new_id"`
+	NewMeta byte     `json:"new_meta"`
+}
+
+// TileChange is a tile that differs between both levels. Old is nil for tiles that were added, and New is nil for
+// tiles that were removed. Both hold the tiles.yml representation of the tile, including its ID.
+type TileChange struct {
+	Pos cube.Pos               `json:"pos"`
+	Old map[string]interface{} `json:"old,omitempty"`
+	New map[string]interface{} `json:"new,omitempty"`
+}
+
+// EntityChange is an entity that is only present in one of both levels.
+type EntityChange struct {
+	Added  bool   `json:"added"`
+	Entity Entity `json:"entity"`
+}
+
+// Empty returns true if both levels compared were the same.
+func (d LevelDiff) Empty() bool {
+	return len(d.Header) == 0 && len(d.Chunks) == 0 && len(d.Tiles) == 0 && len(d.Entities) == 0
+}
+
+// String returns a compact summary of the differences, with a line for every header field, chunk, tile and entity
+// that differs. Block changes are only counted.
+func (d LevelDiff) String() string {
+	b := &strings.Builder{}
+	for _, f := range d.Header {
+		_, _ = fmt.Fprintf(b, "header %v: %v -> %v\n", f.Field, f.Old, f.New)
+	}
+	for _, c := range d.Chunks {
+		_, _ = fmt.Fprintf(b, "chunk %v, %v:", c.X, c.Z)
+		if c.OldMask != c.NewMask {
+			_, _ = fmt.Fprintf(b, " sub chunks %#04x -> %#04x,", c.OldMask, c.NewMask)
+		}
+		_, _ = fmt.Fprintf(b, " %v blocks changed\n", len(c.Blocks))
+	}
+	for _, t := range d.Tiles {
+		switch {
+		case t.Old == nil:
+			_, _ = fmt.Fprintf(b, "tile %v: added %v\n", t.Pos, t.New["id"])
+		case t.New == nil:
+			_, _ = fmt.Fprintf(b, "tile %v: removed %v\n", t.Pos, t.Old["id"])
+		default:
+			_, _ = fmt.Fprintf(b, "tile %v: changed %v\n", t.Pos, t.New["id"])
+		}
+	}
+	for _, e := range d.Entities {
+		action := "removed"
+		if e.Added {
+			action = "added"
+		}
+		_, _ = fmt.Fprintf(b, "entity %v at %v: %v\n", e.Entity.ID, e.Entity.Pos, action)
+	}
+	return b.String()
+}
+
+// Diff compares level a with level b and returns the differences, with a as the old and b as the new level. Chunks
+// whose compressed chunk files and sub chunk bitmasks are identical are skipped without decoding them. Chunks that
+// are outside one of both levels or have a missing or empty chunk file are compared as if they were entirely air,
+// unless the Generator of the level generates them.
+func Diff(a, b *Level) (LevelDiff, error) {
+	d := LevelDiff{Header: diffHeaders(a, b)}
+
+	width := a.Width
+	if b.Width > width {
+		width = b.Width
+	}
+	for x := 0; x < int(width); x++ {
+		for z := 0; z < int(width); z++ {
+			c, err := diffChunks(a, b, x, z)
+			if err != nil {
+				return d, fmt.Errorf("error comparing chunk %v, %v: %w", x, z, err)
+			}
+			if c != nil {
+				d.Chunks = append(d.Chunks, *c)
+			}
+		}
+	}
+
+	d.Tiles = diffTiles(a.tiles, b.tiles)
+	d.Entities = diffEntities(a.entities, b.entities)
+	return d, nil
+}
+
+// diffHeaders compares the header fields of two levels.
+func diffHeaders(a, b *Level) []FieldChange {
+	var changes []FieldChange
+	add := func(field string, old, new interface{}) {
+		if old != new {
+			changes = append(changes, FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+	add("Version", a.Version, b.Version)
+	add("Name", a.Name, b.Name)
+	add("Seed", a.Seed, b.Seed)
+	add("Time", a.Time, b.Time)
+	add("Spawn", a.Spawn, b.Spawn)
+	add("Width", a.Width, b.Width)
+	add("Height", a.Height, b.Height)
+	return changes
+}
+
+// diffChunks compares the chunk at a position in two levels. If the chunks are the same, nil is returned.
+func diffChunks(a, b *Level, x, z int) (*ChunkDiff, error) {
+	index := getIndex(x, z)
+	oldMask, newMask := a.locationMappings[index], b.locationMappings[index]
+	if oldMask == newMask && a.Height == b.Height {
+		oldFile, oldErr := a.chunkFile(x, z)
+		newFile, newErr := b.chunkFile(x, z)
+		if oldErr == nil && newErr == nil && bytes.Equal(oldFile, newFile) {
+			return nil, nil
+		}
+	}
+
+	oldChunk, err := a.chunkOrEmpty(x, z)
+	if err != nil {
+		return nil, err
+	}
+	newChunk, err := b.chunkOrEmpty(x, z)
+	if err != nil {
+		return nil, err
+	}
+	oldMask, newMask = oldChunk.mask(), newChunk.mask()
+
+	d := &ChunkDiff{X: x, Z: z, OldMask: oldMask, NewMask: newMask}
+	for y := uint8(0); y < maxHeight; y++ {
+		oldSub, newSub := oldChunk.subChunks[y], newChunk.subChunks[y]
+		if bytes.Equal(oldSub, newSub) {
+			continue
+		}
+		for bx := x << 4; bx < (x<<4)+16; bx++ {
+			for bz := z << 4; bz < (z<<4)+16; bz++ {
+				for by := int(y) << 4; by < (int(y)<<4)+16; by++ {
+					pos := cube.Pos{bx, by, bz}
+					oldID, _ := oldChunk.BlockID(pos)
+					oldMeta, _ := oldChunk.BlockMeta(pos)
+					newID, _ := newChunk.BlockID(pos)
+					newMeta, _ := newChunk.BlockMeta(pos)
+					if oldID != newID || oldMeta != newMeta {
+						d.Blocks = append(d.Blocks, BlockChange{Pos: pos, OldID: oldID, OldMeta: oldMeta, NewID: newID, NewMeta: newMeta})
+					}
+				}
+			}
+		}
+	}
+	if oldMask == newMask && len(d.Blocks) == 0 {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// chunkFile reads the compressed chunk file of the chunk at a position.
+func (p *Level) chunkFile(x, z int) ([]byte, error) {
+	if x >= int(p.Width) || z >= int(p.Width) {
+		return nil, fmt.Errorf("chunk %v, %v is outside of the world", x, z)
+	}
+	return readFileLimited(path.Join(p.worldPath, chunkFilePath(x, z)), maxChunkFileSize)
+}
+
+// chunkOrEmpty returns the chunk at a position, or an empty chunk if it is outside of the level or its chunk file is
+// missing or empty.
+func (p *Level) chunkOrEmpty(x, z int) (*Chunk, error) {
+	if x >= int(p.Width) || z >= int(p.Width) {
+		return NewEmptyChunk(), nil
+	}
+	c, err := p.Chunk(x, z)
+	if os.IsNotExist(err) {
+		return NewEmptyChunk(), nil
+	}
+	if err != nil {
+		// Empty chunk files cannot be decoded, but hold no blocks either.
+		if b, fileErr := p.chunkFile(x, z); fileErr == nil && len(b) == 0 {
+			return NewEmptyChunk(), nil
+		}
+	}
+	return c, err
+}
+
+// diffTiles compares the tiles of two levels.
+func diffTiles(a, b map[cube.Pos]Tile) []TileChange {
+	var changes []TileChange
+	for pos, t := range a {
+		old := TileData(t)
+		other, ok := b[pos]
+		if !ok {
+			changes = append(changes, TileChange{Pos: pos, Old: old})
+			continue
+		}
+		if n := TileData(other); !reflect.DeepEqual(old, n) {
+			changes = append(changes, TileChange{Pos: pos, Old: old, New: n})
+		}
+	}
+	for pos, t := range b {
+		if _, ok := a[pos]; !ok {
+			changes = append(changes, TileChange{Pos: pos, New: TileData(t)})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return posLess(changes[i].Pos, changes[j].Pos)
+	})
+	return changes
+}
+
+// diffEntities compares the entities of two levels. Entities that are equal in all fields are matched with each
+// other, and all others are reported as added or removed.
+func diffEntities(a, b []Entity) []EntityChange {
+	remaining := make(map[string]int)
+	for _, e := range b {
+		remaining[entityKey(e)]++
+	}
+	var changes []EntityChange
+	for _, e := range a {
+		key := entityKey(e)
+		if remaining[key] > 0 {
+			remaining[key]--
+			continue
+		}
+		changes = append(changes, EntityChange{Entity: e})
+	}
+	for _, e := range b {
+		key := entityKey(e)
+		if remaining[key] > 0 {
+			remaining[key]--
+			changes = append(changes, EntityChange{Added: true, Entity: e})
+		}
+	}
+	return changes
+}
+
+// entityKey returns a string that is the same for two entities only if all their fields are equal. Maps are printed
+// with sorted keys, so the order of the fields does not matter.
+func entityKey(e Entity) string {
+	return fmt.Sprint(e.ID, e.Pos, e.Motion, e.Rotation, e.Data)
+}
+
+// stringKeys converts all maps with interface keys, as decoded from YAML, in a value to maps with string keys.
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = stringKeys(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[k] = stringKeys(val)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			l[i] = stringKeys(val)
+		}
+		return l
+	case []map[string]interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			l[i] = stringKeys(val)
+		}
+		return l
+	}
+	return v
+}
@@ -0,0 +1,121 @@
+package pmf
+
+import (
+	"encoding/json"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/go-gl/mathgl/mgl32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newDiffTestLevel creates a test level with a sign and a painting, saved to disk.
+func newDiffTestLevel(t *testing.T) *Level {
+	t.Helper()
+	p := newTestLevel(t, map[cube.Pos][2]byte{{3, 5, 3}: {63, 0}})
+	p.SetTile(SignTile{Pos: cube.Pos{3, 5, 3}, Text: [4]string{"old"}})
+	p.SetEntities([]Entity{{ID: 83, Pos: mgl32.Vec3{20.5, 5, 20.0625}, Data: map[string]interface{}{"Motive": "Kebab", "Direction": 0}}})
+	if err := p.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// TestDiff tests that Diff reports a block, tile and entity edited in one of two identical levels, in its summary and
+// in JSON.
+func TestDiff(t *testing.T) {
+	a, b := newDiffTestLevel(t), newDiffTestLevel(t)
+	if err := b.SetBlockID(cube.Pos{20, 2, 5}, 4); err != nil {
+		t.Fatal(err)
+	}
+	b.SetTile(SignTile{Pos: cube.Pos{3, 5, 3}, Text: [4]string{"new"}})
+	b.SetEntities(append(b.Entities(), Entity{ID: 12, Pos: mgl32.Vec3{8, 5, 8}}))
+	if err := b.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := DecodeLevel(a.worldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = DecodeLevel(b.worldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := LevelDiff{
+		Chunks: []ChunkDiff{{X: 1, Z: 0, OldMask: 1, NewMask: 1, Blocks: []BlockChange{{Pos: cube.Pos{20, 2, 5}, OldID: 1, NewID: 4}}}},
+		Tiles: []TileChange{{
+			Pos: cube.Pos{3, 5, 3},
+			Old: map[string]interface{}{"id": "Sign", "Text1": "old", "Text2": "", "Text3": "", "Text4": ""},
+			New: map[string]interface{}{"id": "Sign", "Text1": "new", "Text2": "", "Text3": "", "Text4": ""},
+		}},
+		Entities: []EntityChange{{Added: true, Entity: Entity{ID: 12, Pos: mgl32.Vec3{8, 5, 8}, Data: map[string]interface{}{}}}},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Fatalf("got diff\n%#v\nexpected\n%#v", d, want)
+	}
+
+	summary := "chunk 1, 0: 1 blocks changed\ntile [3 5 3]: changed Sign\nentity 12 at [8 5 8]: added\n"
+	if s := d.String(); s != summary {
+		t.Errorf("got summary\n%v\nexpected\n%v", s, summary)
+	}
+
+	encoded, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	block := decoded["chunks"].([]interface{})[0].(map[string]interface{})["blocks"].([]interface{})[0].(map[string]interface{})
+	if block["old_id"] != 1.0 || block["new_id"] != 4.0 {
+		t.Errorf("got block change %v in JSON", block)
+	}
+	if tile := decoded["tiles"].([]interface{})[0].(map[string]interface{}); tile["new"].(map[string]interface{})["Text1"] != "new" {
+		t.Errorf("got tile change %v in JSON", tile)
+	}
+	if e := decoded["entities"].([]interface{})[0].(map[string]interface{}); e["added"] != true || e["entity"].(map[string]interface{})["ID"] != 12.0 {
+		t.Errorf("got entity change %v in JSON", e)
+	}
+	if _, ok := decoded["header"]; ok {
+		t.Error("unchanged header was encoded")
+	}
+}
+
+// TestDiffEmptyChunk tests that chunks with an empty chunk file are compared as if they were entirely air, and that
+// identical levels have no differences.
+func TestDiffEmptyChunk(t *testing.T) {
+	a, b := newTestLevel(t, nil), newTestLevel(t, nil)
+	if d, err := Diff(a, b); err != nil || !d.Empty() {
+		t.Fatalf("got diff %v, %v for identical levels", d, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(b.worldPath, chunkFilePath(0, 1)), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := DecodeLevel(b.worldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Chunks) != 1 {
+		t.Fatalf("got %v changed chunks, expected 1", len(d.Chunks))
+	}
+	// The preset fills Y 0 to 3 with stone, which is gone in all 256 columns.
+	if c := d.Chunks[0]; c.X != 0 || c.Z != 1 || c.OldMask != 1 || c.NewMask != 0 || len(c.Blocks) != 1024 {
+		t.Errorf("got chunk %v, %v with masks %b and %b and %v changed blocks", c.X, c.Z, c.OldMask, c.NewMask, len(c.Blocks))
+	}
+	if _, err := Diff(b, a); err != nil {
+		t.Errorf("empty chunk as the old chunk: %v", err)
+	}
+}
@@ -0,0 +1,449 @@
+package pmf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/go-gl/mathgl/mgl32"
+	"gopkg.in/yaml.v2"
+	"io"
+	"os"
+	"path"
+)
+
+// Dumps of levels written by WriteJSON consist of records, each with a type field holding one of the following types.
+// The level record always comes first, and every sub chunk record comes after the chunk record of its chunk.
+const (
+	dumpLevelType    = "level"
+	dumpChunkType    = "chunk"
+	dumpSubChunkType = "subChunk"
+	dumpTileType     = "tile"
+	dumpEntityType   = "entity"
+	dumpUpdateType   = "update"
+)
+
+// dumpLevel is the record holding the header fields of a level.
+type dumpLevel struct {
+	Type    string     `json:"type"`
+	Version uint8      `json:"version"`
+	Name    string     `json:"name"`
+	Seed    uint32     `json:"seed"`
+	Time    uint32     `json:"time"`
+	Spawn   [3]float32 `json:"spawn"`
+	Width   uint8      `json:"width"`
+	Height  uint8      `json:"height"`
+}
+
+// dumpChunk is the record of a chunk that has a chunk file, with its location mapping.
+type dumpChunk struct {
+	Type            string `json:"type"`
+	X               int    `json:"x"`
+	Z               int    `json:"z"`
+	LocationMapping uint16 `json:"locationMapping"`
+}
+
+// dumpSubChunk is the record of a sub chunk. IDs and Meta hold a value for every block, indexed by
+// y + z*16 + x*256 with positions relative to the sub chunk. Spare holds the 8 bytes of every column that PocketMine
+// Alpha never writes, indexed by i + z*8 + x*128, so that they are kept as they are.
+type dumpSubChunk struct {
+	Type  string `json:"type"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+	Z     int    `json:"z"`
+	IDs   []int  `json:"ids"`
+	Meta  []int  `json:"meta"`
+	Spare []int  `json:"spare"`
+}
+
+// dumpTile is the record of a tile, holding its tiles.yml representation.
+type dumpTile struct {
+	Type string                 `json:"type"`
+	Tile map[string]interface{} `json:"tile"`
+}
+
+// dumpEntity is the record of an entity, holding its entities.yml representation.
+type dumpEntity struct {
+	Type   string                 `json:"type"`
+	Entity map[string]interface{} `json:"entity"`
+}
+
+// dumpUpdate is the record of a scheduled block update, holding its bupdates.yml representation.
+type dumpUpdate struct {
+	Type   string      `json:"type"`
+	Update interface{} `json:"update"`
+}
+
+// WriteJSON writes the whole level as readable JSON, so that it can be edited, searched and compared with standard
+// tools: the header, every chunk with its location mapping, the block IDs, metadata and spare bytes of every sub chunk, and
+// the tiles, entities and scheduled block updates. The level is written as a list of records, one per line. If
+// ndjson is true, the records are written as newline delimited JSON, and otherwise as a JSON array. ImportJSON reads
+// both forms back.
+func (p *Level) WriteJSON(w io.Writer, ndjson bool) error {
+	bw := bufio.NewWriter(w)
+	first := true
+	write := func(record interface{}) error {
+		b, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if !ndjson {
+			if first {
+				_ = bw.WriteByte('[')
+			} else {
+				_ = bw.WriteByte(',')
+			}
+			_ = bw.WriteByte('\n')
+		}
+		first = false
+		_, _ = bw.Write(b)
+		if ndjson {
+			_ = bw.WriteByte('\n')
+		}
+		return nil
+	}
+
+	if err := write(dumpLevel{
+		Type:    dumpLevelType,
+		Version: p.Version,
+		Name:    p.Name,
+		Seed:    p.Seed,
+		Time:    p.Time,
+		Spawn:   p.Spawn,
+		Width:   p.Width,
+		Height:  p.Height,
+	}); err != nil {
+		return err
+	}
+	for z := 0; z < int(p.Width); z++ {
+		for x := 0; x < int(p.Width); x++ {
+			c, err := p.Chunk(x, z)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := write(dumpChunk{Type: dumpChunkType, X: x, Z: z, LocationMapping: p.locationMappings[getIndex(x, z)]}); err != nil {
+				return err
+			}
+			for y := uint8(0); y < p.Height; y++ {
+				if sub, ok := c.subChunks[y]; ok {
+					if err := write(dumpSub(x, int(y), z, sub)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	for _, t := range p.Tiles() {
+		data := TileData(t)
+		pos := t.Position()
+		data["x"], data["y"], data["z"] = pos.X(), pos.Y(), pos.Z()
+		if err := write(dumpTile{Type: dumpTileType, Tile: data}); err != nil {
+			return fmt.Errorf("error encoding tile at %v: %w", pos, err)
+		}
+	}
+	for _, e := range p.entities {
+		if err := write(dumpEntity{Type: dumpEntityType, Entity: stringKeys(encodeEntity(e)).(map[string]interface{})}); err != nil {
+			return fmt.Errorf("error encoding entity: %w", err)
+		}
+	}
+	updates, err := p.blockUpdates()
+	if err != nil {
+		return err
+	}
+	for _, u := range updates {
+		if err := write(dumpUpdate{Type: dumpUpdateType, Update: stringKeys(u)}); err != nil {
+			return fmt.Errorf("error encoding block update: %w", err)
+		}
+	}
+	if !ndjson {
+		_, _ = bw.WriteString("\n]\n")
+	}
+	return bw.Flush()
+}
+
+// dumpSub creates the record of a sub chunk.
+func dumpSub(x, y, z int, sub []byte) dumpSubChunk {
+	d := dumpSubChunk{
+		Type: dumpSubChunkType, X: x, Y: y, Z: z,
+		IDs:   make([]int, 4096),
+		Meta:  make([]int, 4096),
+		Spare: make([]int, 2048),
+	}
+	for column := 0; column < 256; column++ {
+		// Columns in a sub chunk are stored by Z and then X, while the records are ordered by X and then Z.
+		i, off := (column&0xf)<<4|column>>4, column*32
+		for y := 0; y < 16; y++ {
+			d.IDs[i*16+y] = int(sub[off+y])
+			d.Meta[i*16+y] = int(sub[off+16+y>>1] >> (uint(y&1) * 4) & 0xf)
+		}
+		for j := 0; j < 8; j++ {
+			d.Spare[i*8+j] = int(sub[off+24+j])
+		}
+	}
+	return d
+}
+
+// undumpSub turns the record of a sub chunk back into a sub chunk.
+func undumpSub(d dumpSubChunk) ([]byte, error) {
+	if len(d.IDs) != 4096 || len(d.Meta) != 4096 || len(d.Spare) != 2048 {
+		return nil, fmt.Errorf("expected 4096 IDs and metadata values and 2048 spare bytes")
+	}
+	sub := make([]byte, subChunkSize)
+	for column := 0; column < 256; column++ {
+		i, off := (column&0xf)<<4|column>>4, column*32
+		for y := 0; y < 16; y++ {
+			id, meta := d.IDs[i*16+y], d.Meta[i*16+y]
+			if id < 0 || id > 255 || meta < 0 || meta > 15 {
+				return nil, fmt.Errorf("invalid block %v:%v", id, meta)
+			}
+			sub[off+y] = byte(id)
+			sub[off+16+y>>1] |= byte(meta) << (uint(y&1) * 4)
+		}
+		for j := 0; j < 8; j++ {
+			spare := d.Spare[i*8+j]
+			if spare < 0 || spare > 255 {
+				return nil, fmt.Errorf("invalid spare byte %v", spare)
+			}
+			sub[off+24+j] = byte(spare)
+		}
+	}
+	return sub, nil
+}
+
+// blockUpdates reads the scheduled block updates from the bupdates.yml file of the level. A missing file has no
+// updates.
+func (p *Level) blockUpdates() ([]interface{}, error) {
+	b, err := readFileLimited(path.Join(p.worldPath, "bupdates.yml"), maxYAMLFileSize)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var updates []interface{}
+	if err := yaml.Unmarshal(b, &updates); err != nil {
+		return nil, fmt.Errorf("error decoding bupdates.yml: %w", err)
+	}
+	return updates, nil
+}
+
+// ImportJSON reads a level written by WriteJSON, either as a JSON array or as newline delimited JSON, and writes it
+// as a binary PMF world to a folder. Location mappings are recalculated from the sub chunks present, so they don't
+// have to be changed when adding or removing sub chunks by hand.
+func ImportJSON(r io.Reader, folderPath string) (*Level, error) {
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+	dec.UseNumber()
+	if first, err := firstByte(br); err != nil {
+		return nil, err
+	} else if first == '[' {
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	var p *Level
+	var updates []interface{}
+	for i := 0; ; i++ {
+		var raw json.RawMessage
+		if !dec.More() {
+			break
+		}
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("record %v: %w", i, err)
+		}
+		var record struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return nil, fmt.Errorf("record %v: %w", i, err)
+		}
+		if p == nil && record.Type != dumpLevelType {
+			return nil, fmt.Errorf("record %v: expected level record first, got %q", i, record.Type)
+		}
+
+		var err error
+		switch record.Type {
+		case dumpLevelType:
+			if p != nil {
+				return nil, fmt.Errorf("record %v: duplicate level record", i)
+			}
+			p, err = importLevel(raw, folderPath)
+		case dumpChunkType:
+			err = p.importChunk(raw)
+		case dumpSubChunkType:
+			err = p.importSubChunk(raw)
+		case dumpTileType:
+			err = p.importTile(raw)
+		case dumpEntityType:
+			err = p.importEntity(raw)
+		case dumpUpdateType:
+			var d dumpUpdate
+			if err = unmarshalYAMLJSON(raw, &d); err == nil {
+				updates = append(updates, d.Update)
+			}
+		default:
+			err = fmt.Errorf("unknown record type %q", record.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %v: %w", i, err)
+		}
+	}
+	if p == nil {
+		return nil, fmt.Errorf("no level record")
+	}
+
+	if err := os.MkdirAll(path.Join(folderPath, "chunks"), 0755); err != nil {
+		return nil, err
+	}
+	if updates == nil {
+		updates = []interface{}{}
+	}
+	b, err := yaml.Marshal(updates)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path.Join(folderPath, "bupdates.yml"), b, 0644); err != nil {
+		return nil, err
+	}
+	if err := p.Save(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// firstByte returns the first byte of a reader that is not white space, without consuming it.
+func firstByte(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, r.UnreadByte()
+		}
+	}
+}
+
+// importLevel creates an empty level from a level record.
+func importLevel(raw json.RawMessage, folderPath string) (*Level, error) {
+	var d dumpLevel
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return nil, err
+	}
+	if d.Width > maxWidth {
+		return nil, fmt.Errorf("width %v exceeds the maximum of %v", d.Width, maxWidth)
+	}
+	if d.Height > maxHeight {
+		return nil, fmt.Errorf("height %v exceeds the maximum of %v", d.Height, maxHeight)
+	}
+	return &Level{
+		Version:          d.Version,
+		Name:             d.Name,
+		Seed:             d.Seed,
+		Time:             d.Time,
+		Spawn:            mgl32.Vec3(d.Spawn),
+		Width:            d.Width,
+		Height:           d.Height,
+		chunkCache:       make(map[int]*Chunk),
+		locationMappings: make(map[int]uint16, int(d.Width)*int(d.Width)),
+		worldPath:        folderPath,
+		tiles:            make(map[cube.Pos]Tile),
+	}, nil
+}
+
+// importChunk adds an empty chunk to the level from a chunk record.
+func (p *Level) importChunk(raw json.RawMessage) error {
+	var d dumpChunk
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return err
+	}
+	_, err := p.importedChunk(d.X, d.Z)
+	return err
+}
+
+// importSubChunk adds a sub chunk to the level from a sub chunk record.
+func (p *Level) importSubChunk(raw json.RawMessage) error {
+	var d dumpSubChunk
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return err
+	}
+	if d.Y < 0 || d.Y >= int(p.Height) {
+		return fmt.Errorf("sub chunk %v is outside of the world", d.Y)
+	}
+	c, err := p.importedChunk(d.X, d.Z)
+	if err != nil {
+		return err
+	}
+	sub, err := undumpSub(d)
+	if err != nil {
+		return fmt.Errorf("sub chunk %v, %v, %v: %w", d.X, d.Y, d.Z, err)
+	}
+	c.subChunks[uint8(d.Y)] = sub
+	c.modified = true
+	return nil
+}
+
+// importedChunk returns the imported chunk at a position, creating it if it was not imported yet.
+func (p *Level) importedChunk(x, z int) (*Chunk, error) {
+	if x < 0 || z < 0 || x >= int(p.Width) || z >= int(p.Width) {
+		return nil, fmt.Errorf("chunk %v, %v is outside of the world", x, z)
+	}
+	c, ok := p.chunkCache[getIndex(x, z)]
+	if !ok {
+		c = NewEmptyChunk()
+		p.chunkCache[getIndex(x, z)] = c
+	}
+	return c, nil
+}
+
+// importTile adds a tile to the level from a tile record.
+func (p *Level) importTile(raw json.RawMessage) error {
+	var d dumpTile
+	if err := unmarshalYAMLJSON(raw, &d); err != nil {
+		return err
+	}
+	t, err := decodeTile(d.Tile)
+	if err != nil {
+		return err
+	}
+	if _, ok := p.tiles[t.Position()]; ok {
+		return fmt.Errorf("duplicate tile at %v", t.Position())
+	}
+	p.tiles[t.Position()] = t
+	return nil
+}
+
+// importEntity adds an entity to the level from an entity record.
+func (p *Level) importEntity(raw json.RawMessage) error {
+	var d dumpEntity
+	if err := unmarshalYAMLJSON(raw, &d); err != nil {
+		return err
+	}
+	e, err := decodeEntity(d.Entity)
+	if err != nil {
+		return err
+	}
+	p.entities = append(p.entities, e)
+	return nil
+}
+
+// unmarshalYAMLJSON decodes JSON into a value the same way its YAML equivalent would be decoded, so that tiles,
+// entities and block updates read from JSON have the same types as those read from their YAML files.
+func unmarshalYAMLJSON(raw json.RawMessage, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var data interface{}
+	if err := dec.Decode(&data); err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(b, v)
+}
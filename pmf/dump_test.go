@@ -0,0 +1,88 @@
+package pmf
+
+import (
+	"bytes"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestJSONRoundTrip tests that levels imported from their JSON dump are the same as the level dumped.
+func TestJSONRoundTrip(t *testing.T) {
+	example := func(t *testing.T) *Level {
+		p, err := DecodeLevel(filepath.Join("..", "example"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	tests := []struct {
+		name   string
+		level  func(t *testing.T) *Level
+		ndjson bool
+	}{
+		{name: "example", level: example},
+		{name: "example ndjson", ndjson: true, level: example},
+		{name: "edited level", ndjson: true, level: func(t *testing.T) *Level {
+			p := newMoveTestLevel(t)
+			p.SetTile(ChestTile{Pos: cube.Pos{1, 4, 1}, Items: []Item{{ID: 264, Count: 3, Slot: 4}}})
+			if err := p.Save(); err != nil {
+				t.Fatal(err)
+			}
+			return p
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := test.level(t)
+			dump := &bytes.Buffer{}
+			if err := p.WriteJSON(dump, test.ndjson); err != nil {
+				t.Fatal(err)
+			}
+			imported, err := ImportJSON(bytes.NewReader(dump.Bytes()), t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := Diff(p, imported)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !d.Empty() {
+				t.Errorf("imported level differs from the level dumped:\n%v", d)
+			}
+			again := &bytes.Buffer{}
+			if err := imported.WriteJSON(again, test.ndjson); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(dump.Bytes(), again.Bytes()) {
+				t.Errorf("dump of the imported level differs from the dump it was imported from")
+			}
+		})
+	}
+}
+
+// TestImportJSONInvalid tests that dumps with missing, duplicate or unknown records are refused.
+func TestImportJSONInvalid(t *testing.T) {
+	level := `{"type":"level","version":0,"name":"test","width":1,"height":8}`
+	tile := `{"type":"tile","tile":{"id":"Sign","x":1,"y":2,"z":3,"Text1":"","Text2":"","Text3":"","Text4":""}}`
+	tests := []struct {
+		name string
+		dump string
+	}{
+		{name: "empty", dump: ""},
+		{name: "empty array", dump: "[]"},
+		{name: "chunk first", dump: `{"type":"chunk","x":0,"z":0}` + "\n" + level},
+		{name: "duplicate level", dump: level + "\n" + level},
+		{name: "unknown record", dump: level + "\n" + `{"type":"player"}`},
+		{name: "duplicate tile", dump: strings.Join([]string{level, tile, tile}, "\n")},
+		{name: "chunk outside of the level", dump: level + "\n" + `{"type":"chunk","x":3,"z":0}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ImportJSON(strings.NewReader(test.dump), t.TempDir()); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
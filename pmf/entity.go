@@ -0,0 +1,282 @@
+package pmf
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/entity/physics"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/go-gl/mathgl/mgl64"
+	"gopkg.in/yaml.v2"
+	"math"
+	"os"
+	"path"
+)
+
+// Entity is an entity stored in the entities.yml file of a PMF world, such as a painting.
+type Entity struct {
+	// ID is the legacy numeric ID of the entity, for example 83 for paintings.
+	ID int
+	// Pos is the position of the entity.
+	Pos mgl32.Vec3
+	// Motion is the velocity of the entity.
+	Motion mgl32.Vec3
+	// Rotation is the yaw and pitch of the entity.
+	Rotation mgl32.Vec2
+	// Data holds all other fields of the entity, keyed by their name in entities.yml.
+	Data map[string]interface{}
+}
+
+// MarshalJSON encodes the entity to JSON, with all nested maps in its data keyed by strings.
+func (e Entity) MarshalJSON() ([]byte, error) {
+	type entity Entity
+	e.Data, _ = stringKeys(e.Data).(map[string]interface{})
+	return json.Marshal(entity(e))
+}
+
+// MovedEntities returns the entities of the level inside the area converted with the options passed, moved by the
+// Offset of the options to their position in the converted world. These are the entities that a conversion writes,
+// so entities that it does not support may be written to the converted world separately.
+func (p *Level) MovedEntities(opts ConvertOptions) []Entity {
+	area := p.convertArea(opts)
+	offsetX, offsetZ := float32(opts.Offset.X()<<4), float32(opts.Offset.Z()<<4)
+
+	var entities []Entity
+	for _, e := range p.entities {
+		pos := cube.Pos{int(math.Floor(float64(e.Pos.X()))), int(math.Floor(float64(e.Pos.Y()))), int(math.Floor(float64(e.Pos.Z())))}
+		if !area.Contains(pos) {
+			continue
+		}
+		e.Pos = e.Pos.Add(mgl32.Vec3{offsetX, 0, offsetZ})
+
+		// Paintings also hold the position of the block they hang on.
+		data := make(map[string]interface{}, len(e.Data))
+		for k, v := range e.Data {
+			data[k] = v
+		}
+		if x, ok := yamlInt(data["TileX"]); ok {
+			data["TileX"] = x + int(opts.Offset.X())<<4
+		}
+		if z, ok := yamlInt(data["TileZ"]); ok {
+			data["TileZ"] = z + int(opts.Offset.Z())<<4
+		}
+		e.Data = data
+		entities = append(entities, e)
+	}
+	return entities
+}
+
+// entityIdentifiers holds the identifier of the modern entity for every legacy entity ID that is converted. Modern
+// versions still use the same numeric IDs for these entities.
+var entityIdentifiers = map[int]string{
+	10: "minecraft:chicken",
+	11: "minecraft:cow",
+	12: "minecraft:pig",
+	13: "minecraft:sheep",
+	32: "minecraft:zombie",
+	33: "minecraft:creeper",
+	34: "minecraft:skeleton",
+	35: "minecraft:spider",
+	36: "minecraft:zombie_pigman",
+	83: "minecraft:painting",
+}
+
+// entityData is an entity converted to the NBT data of its modern version. It only implements world.SaveableEntity
+// so that it can be written to a provider. It is not registered with world.RegisterEntity, as that would keep users of
+// this package from registering their own paintings and mobs, so providers do not load it back.
+type entityData struct {
+	identifier string
+	data       map[string]interface{}
+}
+
+// Close does nothing, as the entity is never added to a world.
+func (e entityData) Close() error { return nil }
+
+// Name returns the identifier of the entity.
+func (e entityData) Name() string { return e.identifier }
+
+// EncodeEntity returns the identifier of the entity.
+func (e entityData) EncodeEntity() string { return e.identifier }
+
+// AABB returns an empty AABB.
+func (e entityData) AABB() physics.AABB { return physics.AABB{} }
+
+// Position returns the position of the entity, or the zero vector if its data holds no valid position.
+func (e entityData) Position() mgl64.Vec3 {
+	var pos mgl64.Vec3
+	nbtVec(e.data["Pos"], pos[:])
+	return pos
+}
+
+// Rotation returns the yaw and pitch of the entity, or zero if its data holds no valid rotation.
+func (e entityData) Rotation() (yaw, pitch float64) {
+	var rot [2]float64
+	nbtVec(e.data["Rotation"], rot[:])
+	return rot[0], rot[1]
+}
+
+// World returns nil, as the entity is never added to a world.
+func (e entityData) World() *world.World { return nil }
+
+// EncodeNBT returns the NBT data of the entity.
+func (e entityData) EncodeNBT() map[string]interface{} { return e.data }
+
+// DecodeNBT returns an entity with the same identifier and the NBT data passed.
+func (e entityData) DecodeNBT(data map[string]interface{}) interface{} {
+	return entityData{identifier: e.identifier, data: data}
+}
+
+// nbtVec reads an NBT list of floats into the slice passed. Converted entities hold a []float32, while those decoded
+// from NBT hold a []interface{}. The slice is left untouched if the value is neither or does not have the length of
+// the slice.
+func nbtVec(v interface{}, dst []float64) {
+	values := make([]float64, len(dst))
+	switch l := v.(type) {
+	case []float32:
+		if len(l) != len(dst) {
+			return
+		}
+		for i, f := range l {
+			values[i] = float64(f)
+		}
+	case []interface{}:
+		if len(l) != len(dst) {
+			return
+		}
+		for i, f := range l {
+			f, ok := f.(float32)
+			if !ok {
+				return
+			}
+			values[i] = float64(f)
+		}
+	default:
+		return
+	}
+	copy(dst, values)
+}
+
+// convertEntity converts an entity, already moved to its position in the converted world, to its modern version
+// with a unique ID. False is returned if the entity is not supported. Mobs only keep their position, motion and
+// rotation, and paintings also keep their motive and the direction they face, which modern versions still number
+// the same way.
+func convertEntity(e Entity, uniqueID int64) (entityData, bool) {
+	identifier, ok := entityIdentifiers[e.ID]
+	if !ok {
+		return entityData{}, false
+	}
+	data := map[string]interface{}{
+		"Pos":      []float32{e.Pos[0], e.Pos[1], e.Pos[2]},
+		"Motion":   []float32{e.Motion[0], e.Motion[1], e.Motion[2]},
+		"Rotation": []float32{e.Rotation[0], e.Rotation[1]},
+		"UniqueID": uniqueID,
+	}
+	if e.ID == 83 {
+		motive, _ := e.Data["Motive"].(string)
+		direction, _ := yamlInt(e.Data["Direction"])
+		if motive == "" || direction < 0 || direction > 3 {
+			return entityData{}, false
+		}
+		data["Motive"], data["Direction"] = motive, byte(direction)
+	}
+	return entityData{identifier: identifier, data: data}, true
+}
+
+// SetEntities replaces all entities of the level with the entities passed. SaveEntities must be called to write
+// them to the entities.yml file.
+func (p *Level) SetEntities(entities []Entity) {
+	p.entities = entities
+}
+
+// SaveEntities writes the entities of the level to its entities.yml file.
+func (p *Level) SaveEntities() error {
+	b, err := encodeEntities(p.entities)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(p.worldPath, "entities.yml"), b, 0644)
+}
+
+// decodeEntities decodes the contents of an entities.yml file.
+func decodeEntities(b []byte) ([]Entity, error) {
+	var raw []map[string]interface{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+
+	entities := make([]Entity, 0, len(raw))
+	for i, data := range raw {
+		e, err := decodeEntity(data)
+		if err != nil {
+			return nil, fmt.Errorf("entity %v: %w", i, err)
+		}
+		entities = append(entities, e)
+	}
+	return entities, nil
+}
+
+// decodeEntity decodes a single entity from its YAML representation.
+func decodeEntity(data map[string]interface{}) (Entity, error) {
+	var ok bool
+	e := Entity{Data: make(map[string]interface{})}
+	if e.ID, ok = yamlInt(data["id"]); !ok {
+		return e, fmt.Errorf("invalid id %v", data["id"])
+	}
+	if err := yamlVec(data["Pos"], e.Pos[:]); err != nil {
+		return e, fmt.Errorf("invalid Pos: %w", err)
+	}
+	if err := yamlVec(data["Motion"], e.Motion[:]); err != nil {
+		return e, fmt.Errorf("invalid Motion: %w", err)
+	}
+	if err := yamlVec(data["Rotation"], e.Rotation[:]); err != nil {
+		return e, fmt.Errorf("invalid Rotation: %w", err)
+	}
+	for k, v := range data {
+		switch k {
+		case "id", "Pos", "Motion", "Rotation":
+		default:
+			e.Data[k] = v
+		}
+	}
+	return e, nil
+}
+
+// yamlVec decodes a YAML list of numbers into the slice passed. A missing list leaves the slice untouched.
+func yamlVec(v interface{}, dst []float32) error {
+	if v == nil {
+		return nil
+	}
+	l, ok := v.([]interface{})
+	if !ok || len(l) != len(dst) {
+		return fmt.Errorf("expected a list of %v numbers", len(dst))
+	}
+	for i, n := range l {
+		if dst[i], ok = yamlFloat(n); !ok {
+			return fmt.Errorf("invalid number %v", n)
+		}
+	}
+	return nil
+}
+
+// encodeEntities encodes entities to the contents of an entities.yml file.
+func encodeEntities(entities []Entity) ([]byte, error) {
+	raw := make([]map[string]interface{}, 0, len(entities))
+	for _, e := range entities {
+		raw = append(raw, encodeEntity(e))
+	}
+	return yaml.Marshal(raw)
+}
+
+// encodeEntity encodes a single entity to its YAML representation.
+func encodeEntity(e Entity) map[string]interface{} {
+	data := make(map[string]interface{}, len(e.Data)+4)
+	for k, v := range e.Data {
+		data[k] = v
+	}
+	data["id"] = e.ID
+	data["Pos"] = []float32{e.Pos[0], e.Pos[1], e.Pos[2]}
+	data["Motion"] = []float32{e.Motion[0], e.Motion[1], e.Motion[2]}
+	data["Rotation"] = []float32{e.Rotation[0], e.Rotation[1]}
+	return data
+}
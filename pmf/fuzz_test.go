@@ -0,0 +1,97 @@
+package pmf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// exampleFile reads a file from the example world, failing the test if it cannot be read.
+func exampleFile(f *testing.F, name string) []byte {
+	b, err := os.ReadFile(filepath.Join("..", "example", name))
+	if err != nil {
+		f.Fatal(err)
+	}
+	return b
+}
+
+// FuzzDecodeLevel fuzzes the level.pmf header decoder.
+func FuzzDecodeLevel(f *testing.F) {
+	f.Add(exampleFile(f, "level.pmf"))
+	f.Add([]byte("PMF\x01\x00\x00"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		p, err := decodeLevel(b)
+		if err != nil {
+			return
+		}
+		if p.Width > maxWidth || p.Height > maxHeight {
+			t.Fatalf("decoded level with invalid dimensions %vx%v", p.Width, p.Height)
+		}
+	})
+}
+
+// FuzzDecodeChunk fuzzes the chunk decompression and sub chunk splitting, as well as reading every block of the
+// chunks that decode successfully.
+func FuzzDecodeChunk(f *testing.F) {
+	f.Add(exampleFile(f, "chunks/0.0.pmc"), uint16(0x3f), uint8(8))
+	f.Add(exampleFile(f, "chunks/8.8.pmc"), uint16(0x7f), uint8(8))
+
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	_, _ = w.Write(make([]byte, subChunkSize))
+	_ = w.Close()
+	f.Add(buf.Bytes(), uint16(0x05), uint8(4))
+
+	f.Fuzz(func(t *testing.T, b []byte, mask uint16, height uint8) {
+		if height > maxHeight {
+			return
+		}
+		c, err := decodeChunk(b, mask, height)
+		if err != nil {
+			return
+		}
+		for y, sub := range c.subChunks {
+			if y >= height || len(sub) != subChunkSize {
+				t.Fatalf("invalid sub chunk %v of %v bytes", y, len(sub))
+			}
+		}
+		for x := 0; x < 16; x++ {
+			for z := 0; z < 16; z++ {
+				for y := 0; y < 128; y++ {
+					_, _, _ = c.Block(cube.Pos{x, y, z})
+				}
+			}
+		}
+	})
+}
+
+// FuzzDecodeTiles fuzzes the tiles.yml decoder.
+func FuzzDecodeTiles(f *testing.F) {
+	f.Add(exampleFile(f, "tiles.yml"))
+	f.Add([]byte("- {id: Sign, x: 1, \"y\": 2, z: 3, Text1: 5}"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		tiles, _, err := decodeTiles(b)
+		if err != nil {
+			return
+		}
+		l := make([]Tile, 0, len(tiles))
+		for _, tile := range tiles {
+			l = append(l, tile)
+		}
+		if _, err := encodeTiles(l); err != nil {
+			t.Fatalf("error encoding decoded tiles: %v", err)
+		}
+	})
+}
+
+// FuzzDecodeEntities fuzzes the entities.yml decoder.
+func FuzzDecodeEntities(f *testing.F) {
+	f.Add(exampleFile(f, "entities.yml"))
+	f.Add([]byte("- {id: 83, Pos: [1, 2, 3]}"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, _ = decodeEntities(b)
+	})
+}
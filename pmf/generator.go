@@ -0,0 +1,210 @@
+package pmf
+
+import (
+	"fmt"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"strconv"
+	"strings"
+)
+
+// Generator generates the blocks of chunks that are missing from a level. Many old maps lack some chunk files or
+// have empty ones, and the level's generator, if set, fills these in when they are loaded.
+type Generator interface {
+	// GenerateChunk generates the blocks of the chunk at the chunk X and Z passed into an empty chunk. The chunk uses
+	// world block positions, so a block in the chunk is at (x<<4)+localX, y, (z<<4)+localZ.
+	GenerateChunk(x, z int, c *Chunk)
+}
+
+// Layer is a layer of blocks of a flat world, as found in superflat presets.
+type Layer struct {
+	// ID is the legacy block ID of the layer.
+	ID byte
+	// Meta is the metadata value of the blocks in the layer.
+	Meta byte
+	// Count is the amount of blocks the layer is thick.
+	Count int
+}
+
+// FlatGenerator is a Generator that generates flat terrain made up of layers of blocks.
+type FlatGenerator struct {
+	// Layers holds the layers of the terrain from the bottom up.
+	Layers []Layer
+}
+
+// GenerateChunk generates the layers of the flat generator in a chunk. Layers above the height of the chunk are cut
+// off.
+func (g FlatGenerator) GenerateChunk(x, z int, c *Chunk) {
+	y := 0
+	for _, layer := range g.Layers {
+		for i := 0; i < layer.Count && y < 128; i++ {
+			if layer.ID != 0 || layer.Meta != 0 {
+				fillLayer(c, x, z, y, layer.ID, layer.Meta)
+			}
+			y++
+		}
+	}
+}
+
+const (
+	// PresetClassicFlat is the superflat preset of the classic flat world: a layer of bedrock, two layers of dirt and
+	// a layer of grass.
+	PresetClassicFlat = "2;7,2x3,2;1;"
+	// PresetSuperflat is the default preset of the superflat generator of PocketMine Alpha: bedrock, 59 layers of
+	// stone, three layers of dirt and grass.
+	PresetSuperflat = "2;7,59x1,3x3,2;1;"
+	// PresetVoid is a superflat preset without any layers, which generates a world without any blocks.
+	PresetVoid = "2;;1;"
+)
+
+// ParsePreset parses a superflat preset, such as "2;7,59x1,3x3,2;1;", into a FlatGenerator. The layers of a preset
+// are separated by commas and written as [count x]id[:meta], from the bottom up. A preset holding only the layers
+// is accepted as well. The biome and options of the preset are ignored.
+func ParsePreset(preset string) (FlatGenerator, error) {
+	layers := preset
+	if parts := strings.Split(preset, ";"); len(parts) > 1 {
+		layers = parts[1]
+	}
+	var g FlatGenerator
+	for _, s := range strings.Split(layers, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		layer, err := parseLayer(s)
+		if err != nil {
+			return FlatGenerator{}, fmt.Errorf("invalid layer %q: %w", s, err)
+		}
+		g.Layers = append(g.Layers, layer)
+	}
+	return g, nil
+}
+
+// parseLayer parses a single layer of a superflat preset.
+func parseLayer(s string) (Layer, error) {
+	layer := Layer{Count: 1}
+	if i := strings.Index(s, "x"); i != -1 {
+		count, err := strconv.Atoi(s[:i])
+		if err != nil || count < 0 {
+			return Layer{}, fmt.Errorf("invalid count %q", s[:i])
+		}
+		layer.Count, s = count, s[i+1:]
+	}
+	if i := strings.Index(s, ":"); i != -1 {
+		meta, err := strconv.ParseUint(s[i+1:], 10, 4)
+		if err != nil {
+			return Layer{}, fmt.Errorf("invalid metadata %q", s[i+1:])
+		}
+		layer.Meta, s = byte(meta), s[:i]
+	}
+	id, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return Layer{}, fmt.Errorf("invalid block id %q", s)
+	}
+	layer.ID = byte(id)
+	return layer, nil
+}
+
+// fillLayer sets every block of a chunk at a Y level to the block passed. Y levels outside of the chunk are ignored.
+func fillLayer(c *Chunk, chunkX, chunkZ, y int, id, meta byte) {
+	if y < 0 || y > 127 {
+		return
+	}
+	for x := chunkX << 4; x < (chunkX<<4)+16; x++ {
+		for z := chunkZ << 4; z < (chunkZ<<4)+16; z++ {
+			pos := cube.Pos{x, y, z}
+			_ = c.SetBlockID(pos, id)
+			_ = c.SetBlockMeta(pos, meta)
+		}
+	}
+}
+
+const (
+	// normalWorldHeight is the base height of the terrain of the normal generator.
+	normalWorldHeight = 65
+	// normalWaterHeight is the height up to which the normal generator fills the terrain with water.
+	normalWaterHeight = 63
+)
+
+// NormalGenerator is a Generator that generates hilly terrain from a seed, following the terrain of the normal
+// generator of PocketMine Alpha: stone with a few blocks of dirt and grass on top, patches of gravel, beaches and
+// lakes up to Y 63, and a rough bedrock floor. Ores, trees and grass are not generated.
+type NormalGenerator struct {
+	seed                               uint32
+	hills, patches, patchesSmall, base *simplexNoise
+}
+
+// NewNormalGenerator returns a NormalGenerator that generates terrain from the seed passed. Usually the seed of the
+// level is used, so that the terrain depends only on the world.
+func NewNormalGenerator(seed uint32) *NormalGenerator {
+	r := newRandom(seed)
+	return &NormalGenerator{
+		seed:         seed,
+		hills:        newSimplexNoise(r, 3),
+		patches:      newSimplexNoise(r, 2),
+		patchesSmall: newSimplexNoise(r, 2),
+		base:         newSimplexNoise(r, 16),
+	}
+}
+
+// GenerateChunk generates the terrain of a chunk from the seed of the normal generator.
+func (g *NormalGenerator) GenerateChunk(chunkX, chunkZ int, c *Chunk) {
+	r := newRandom(0xdeadbeef ^ uint32(chunkX<<8) ^ uint32(chunkZ) ^ g.seed)
+	for z := chunkZ << 4; z < (chunkZ<<4)+16; z++ {
+		for x := chunkX << 4; x < (chunkX<<4)+16; x++ {
+			fx, fz := float64(x), float64(z)
+			hills := g.hills.noise2D(fx, fz, 0.11, 12, true)
+			patches := g.patches.noise2D(fx, fz, 0.03, 16, true)
+			patchesSmall := g.patchesSmall.noise2D(fx, fz, 0.5, 4, true)
+			base := g.base.noise2D(fx, fz, 0.7, 16, true)
+			if base < 0 {
+				base *= 0.5
+			}
+			height := int(normalWorldHeight + hills*14 + base*7)
+
+			for y := 0; y < 128; y++ {
+				id := normalBlock(r, y, height-y, patches, patchesSmall)
+				if id != 0 {
+					_ = c.SetBlockID(cube.Pos{x, y, z}, id)
+				}
+			}
+		}
+	}
+}
+
+// normalBlock returns the ID of the block the normal generator places at a Y level, with diff the distance from the
+// Y level to the surface of the column.
+func normalBlock(r *random, y, diff int, patches, patchesSmall float64) byte {
+	switch {
+	case y <= 4 && (y == 0 || r.nextFloat() < 0.75):
+		return 7 // Bedrock.
+	case diff > 2:
+		return 1 // Stone.
+	case diff > 0:
+		return patchBlock(patches, 3)
+	case y <= normalWaterHeight:
+		if diff != 0 {
+			return 9 // Still water.
+		}
+		if normalWaterHeight-y <= 1 || patchesSmall < -0.45 {
+			return 12 // Sand.
+		}
+		if patchesSmall > 0.3 {
+			return 13 // Gravel.
+		}
+		return 3 // Dirt.
+	case diff == 0:
+		return patchBlock(patches, 2)
+	}
+	return 0
+}
+
+// patchBlock returns stone or gravel for columns in a patch, or the block passed otherwise.
+func patchBlock(patches float64, id byte) byte {
+	if patches > 0.7 {
+		return 1 // Stone.
+	}
+	if patches < -0.8 {
+		return 13 // Gravel.
+	}
+	return id
+}
@@ -0,0 +1,167 @@
+package pmf
+
+import (
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestParsePreset tests parsing the named presets, custom layer strings and invalid presets.
+func TestParsePreset(t *testing.T) {
+	tests := []struct {
+		name   string
+		preset string
+		layers []Layer
+		ok     bool
+	}{
+		{name: "classic flat", preset: PresetClassicFlat, layers: []Layer{{ID: 7, Count: 1}, {ID: 3, Count: 2}, {ID: 2, Count: 1}}, ok: true},
+		{name: "superflat", preset: PresetSuperflat, layers: []Layer{{ID: 7, Count: 1}, {ID: 1, Count: 59}, {ID: 3, Count: 3}, {ID: 2, Count: 1}}, ok: true},
+		{name: "void", preset: PresetVoid, ok: true},
+		{name: "layers only", preset: "7,3x3,2", layers: []Layer{{ID: 7, Count: 1}, {ID: 3, Count: 3}, {ID: 2, Count: 1}}, ok: true},
+		{name: "metadata", preset: "2;7,4x35:14;1;", layers: []Layer{{ID: 7, Count: 1}, {ID: 35, Meta: 14, Count: 4}}, ok: true},
+		{name: "spaces and empty layers", preset: " 7 ,, 2x1 ", layers: []Layer{{ID: 7, Count: 1}, {ID: 1, Count: 2}}, ok: true},
+		{name: "empty", preset: "", ok: true},
+		{name: "invalid id", preset: "2;7,abc;1;"},
+		{name: "id too large", preset: "256"},
+		{name: "invalid count", preset: "ax1"},
+		{name: "negative count", preset: "-1x1"},
+		{name: "metadata too large", preset: "35:16"},
+		{name: "invalid metadata", preset: "35:red"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, err := ParsePreset(test.preset)
+			if !test.ok {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", g)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(g.Layers, test.layers) {
+				t.Errorf("got layers %v, expected %v", g.Layers, test.layers)
+			}
+		})
+	}
+}
+
+// TestFlatGenerator tests that the layers of a flat generator are generated from the bottom up and cut off at the
+// top of the chunk.
+func TestFlatGenerator(t *testing.T) {
+	g := FlatGenerator{Layers: []Layer{{ID: 7, Count: 1}, {ID: 35, Meta: 14, Count: 2}, {Count: 3}, {ID: 1, Count: 200}}}
+	c := NewEmptyChunk()
+	g.GenerateChunk(1, 2, c)
+	tests := []struct {
+		y        int
+		id, meta byte
+	}{
+		{y: 0, id: 7},
+		{y: 1, id: 35, meta: 14},
+		{y: 2, id: 35, meta: 14},
+		{y: 3},
+		{y: 5},
+		{y: 6, id: 1},
+		{y: 127, id: 1},
+	}
+	for _, test := range tests {
+		for _, pos := range []cube.Pos{{16, test.y, 32}, {31, test.y, 47}} {
+			id, _ := c.BlockID(pos)
+			meta, _ := c.BlockMeta(pos)
+			if id != test.id || meta != test.meta {
+				t.Errorf("got %v:%v at %v, expected %v:%v", id, meta, pos, test.id, test.meta)
+			}
+		}
+	}
+}
+
+// TestNormalGenerator tests that the normal generator generates the same terrain for the same seed, with a bedrock
+// floor and a surface around the base height of the terrain.
+func TestNormalGenerator(t *testing.T) {
+	generate := func(seed uint32) *Chunk {
+		c := NewEmptyChunk()
+		NewNormalGenerator(seed).GenerateChunk(3, 5, c)
+		return c
+	}
+	a, b, other := generate(1234), generate(1234), generate(4321)
+	if !reflect.DeepEqual(a.subChunks, b.subChunks) {
+		t.Error("the same seed generated different terrain")
+	}
+	if reflect.DeepEqual(a.subChunks, other.subChunks) {
+		t.Error("different seeds generated the same terrain")
+	}
+	for x := 48; x < 64; x++ {
+		for z := 80; z < 96; z++ {
+			if id, _ := a.BlockID(cube.Pos{x, 0, z}); id != 7 {
+				t.Fatalf("got block %v at the bottom of column %v, %v, expected bedrock", id, x, z)
+			}
+			top := 0
+			for y := 127; y > 0; y-- {
+				if id, _ := a.BlockID(cube.Pos{x, y, z}); id != 0 {
+					top = y
+					break
+				}
+			}
+			if top < 30 || top > 100 {
+				t.Errorf("column %v, %v has its surface at %v, expected it around %v", x, z, top, normalWorldHeight)
+			}
+		}
+	}
+}
+
+// TestMissingChunks tests that chunks without a chunk file or with an empty one are generated by the generator of a
+// level, and fail to load without one.
+func TestMissingChunks(t *testing.T) {
+	tests := []struct {
+		name string
+		// remove replaces the chunk file passed.
+		remove    func(path string) error
+		generator Generator
+		ok        bool
+	}{
+		{name: "missing", remove: os.Remove, generator: FlatGenerator{Layers: []Layer{{ID: 3, Count: 2}}}, ok: true},
+		{name: "empty", remove: func(path string) error {
+			return os.WriteFile(path, nil, 0644)
+		}, generator: FlatGenerator{Layers: []Layer{{ID: 3, Count: 2}}}, ok: true},
+		{name: "missing without generator", remove: os.Remove},
+		{name: "empty without generator", remove: func(path string) error {
+			return os.WriteFile(path, nil, 0644)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestLevel(t, nil)
+			if err := test.remove(filepath.Join(p.worldPath, chunkFilePath(1, 0))); err != nil {
+				t.Fatal(err)
+			}
+			p, err := DecodeLevel(p.worldPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.Generator = test.generator
+
+			c, err := p.Chunk(1, 0)
+			if !test.ok {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for y, want := range []byte{3, 3, 0, 0} {
+				if id, _ := c.BlockID(cube.Pos{20, y, 5}); id != want {
+					t.Errorf("got block %v at Y %v, expected %v", id, y, want)
+				}
+			}
+			// Other chunks are still loaded from their chunk files.
+			if id, _ := p.BlockID(cube.Pos{5, 3, 5}); id != 1 {
+				t.Errorf("got block %v in a chunk loaded from disk, expected stone", id)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package pmf
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+)
+
+// HeightFilter changes which blocks are counted when looking for the highest block of a column. Filters may be
+// combined using a bitwise OR.
+type HeightFilter uint8
+
+const (
+	// IgnoreLeaves ignores leaves, so that the ground below trees is found.
+	IgnoreLeaves HeightFilter = 1 << iota
+	// IgnoreLiquids ignores water and lava, so that the bottom of lakes and seas is found.
+	IgnoreLiquids
+	// IgnoreNonSolid ignores blocks that players walk through, such as torches, flowers, tall grass, signs and rails,
+	// as well as thin snow layers, so that the ground they stand on is found.
+	IgnoreNonSolid
+)
+
+// nonSolidBlocks holds the IDs of the blocks ignored by IgnoreNonSolid.
+var nonSolidBlocks = map[byte]bool{
+	6:   true, // Saplings.
+	27:  true, // Powered rails.
+	28:  true, // Detector rails.
+	30:  true, // Cobwebs.
+	31:  true, // Tall grass.
+	32:  true, // Dead bushes.
+	37:  true, // Dandelions.
+	38:  true, // Roses and other flowers.
+	39:  true, // Brown mushrooms.
+	40:  true, // Red mushrooms.
+	50:  true, // Torches.
+	51:  true, // Fire.
+	55:  true, // Redstone wire.
+	59:  true, // Wheat.
+	63:  true, // Standing signs.
+	65:  true, // Ladders.
+	66:  true, // Rails.
+	68:  true, // Wall signs.
+	69:  true, // Levers.
+	70:  true, // Stone pressure plates.
+	72:  true, // Wooden pressure plates.
+	75:  true, // Unlit redstone torches.
+	76:  true, // Redstone torches.
+	77:  true, // Stone buttons.
+	78:  true, // Snow layers.
+	83:  true, // Sugar cane.
+	104: true, // Pumpkin stems.
+	105: true, // Melon stems.
+	106: true, // Vines.
+	141: true, // Carrots.
+	142: true, // Potatoes.
+	143: true, // Wooden buttons.
+}
+
+// ignores checks if the filter ignores blocks with the ID passed. Air is always ignored.
+func (f HeightFilter) ignores(id byte) bool {
+	switch id {
+	case 0:
+		return true
+	case 18:
+		return f&IgnoreLeaves != 0
+	case 8, 9, 10, 11:
+		return f&IgnoreLiquids != 0
+	}
+	return f&IgnoreNonSolid != 0 && nonSolidBlocks[id]
+}
+
+// HeightMap holds the Y of the highest block in every column of a chunk, or -1 for columns without any blocks.
+type HeightMap [256]int16
+
+// At returns the Y of the highest block in the column at an X and Z relative to the chunk, or -1 if the column has
+// no blocks.
+func (h HeightMap) At(x, z int) int {
+	return int(h[(x&0xf)|(z&0xf)<<4])
+}
+
+// HeightMap returns the height map of the chunk, counting only the blocks not ignored by the filter passed. Height
+// maps are cached until a block ID in the chunk is changed.
+func (c *Chunk) HeightMap(filter HeightFilter) HeightMap {
+	if h, ok := c.heightMaps[filter]; ok {
+		return h
+	}
+	var h HeightMap
+	for column := range h {
+		h[column] = -1
+		// Columns in a sub chunk are stored by Z and then X, just like in the height map.
+	search:
+		for y := maxHeight - 1; y >= 0; y-- {
+			sub, ok := c.subChunks[uint8(y)]
+			if !ok {
+				continue
+			}
+			for i := 15; i >= 0; i-- {
+				if !filter.ignores(sub[column<<5+i]) {
+					h[column] = int16(y<<4 + i)
+					break search
+				}
+			}
+		}
+	}
+	if c.heightMaps == nil {
+		c.heightMaps = make(map[HeightFilter]HeightMap)
+	}
+	c.heightMaps[filter] = h
+	return h
+}
+
+// HighestBlock returns the Y of the highest block in the column at an X and Z in the level, counting only the blocks
+// not ignored by the filter passed. If the column has no blocks or its chunk has no chunk file, -1 is returned. The
+// height maps of chunks are cached, so looking up many columns of the same chunk is cheap.
+func (p *Level) HighestBlock(x, z int, filter HeightFilter) (int, error) {
+	c, err := p.Chunk(x>>4, z>>4)
+	if os.IsNotExist(err) {
+		return -1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return c.HeightMap(filter).At(x, z), nil
+}
+
+// RenderHeightMap renders the heights of the highest blocks in the level as a 16-bit grayscale image, with one pixel
+// per column. The height of a column is spread over the full range of grey values, so that the bottom of the world
+// is black and the top is white. Columns without any blocks, as well as chunks without a chunk file, are black.
+func (p *Level) RenderHeightMap(filter HeightFilter) (*image.Gray16, error) {
+	size := int(p.Width) << 4
+	img := image.NewGray16(image.Rect(0, 0, size, size))
+	top := int(p.Height) << 4
+	if top == 0 {
+		return img, nil
+	}
+	for chunkX := 0; chunkX < int(p.Width); chunkX++ {
+		for chunkZ := 0; chunkZ < int(p.Width); chunkZ++ {
+			c, err := p.chunkOrEmpty(chunkX, chunkZ)
+			if err != nil {
+				return nil, err
+			}
+			h := c.HeightMap(filter)
+			for x := 0; x < 16; x++ {
+				for z := 0; z < 16; z++ {
+					y := h.At(x, z) + 1
+					img.SetGray16(chunkX<<4+x, chunkZ<<4+z, color.Gray16{Y: uint16(y * 0xffff / top)})
+				}
+			}
+		}
+	}
+	return img, nil
+}
+
+// WriteHeightMapPNG renders the height map of the level using RenderHeightMap and writes it to the writer passed as a
+// 16-bit grayscale PNG.
+func (p *Level) WriteHeightMapPNG(w io.Writer, filter HeightFilter) error {
+	img, err := p.RenderHeightMap(filter)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
@@ -0,0 +1,137 @@
+package pmf
+
+import (
+	"bytes"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestHighestBlock tests that the highest block of a column skips the blocks ignored by every filter.
+func TestHighestBlock(t *testing.T) {
+	// The test level has stone up to Y 3. The first column has water, leaves and a torch on top of it, and the others
+	// hold a single block on the stone.
+	blocks := map[cube.Pos][2]byte{
+		{5, 4, 5}: {9, 0}, {5, 5, 5}: {9, 0}, {5, 6, 5}: {18, 0}, {5, 7, 5}: {50, 0},
+		{6, 4, 5}: {31, 1}, {7, 4, 5}: {38, 0}, {8, 4, 5}: {78, 0}, {9, 4, 5}: {63, 0}, {10, 4, 5}: {66, 0},
+		{11, 4, 5}: {12, 0}, {12, 4, 5}: {171, 0},
+	}
+	p := newTestLevel(t, blocks)
+	tests := []struct {
+		name   string
+		filter HeightFilter
+		// heights holds the height expected for the columns at X 5 to 12.
+		heights [8]int
+	}{
+		{name: "no filter", heights: [8]int{7, 4, 4, 4, 4, 4, 4, 4}},
+		{name: "leaves", filter: IgnoreLeaves, heights: [8]int{7, 4, 4, 4, 4, 4, 4, 4}},
+		{name: "liquids", filter: IgnoreLiquids, heights: [8]int{7, 4, 4, 4, 4, 4, 4, 4}},
+		{name: "non-solid", filter: IgnoreNonSolid, heights: [8]int{6, 3, 3, 3, 3, 3, 4, 4}},
+		{name: "leaves and non-solid", filter: IgnoreLeaves | IgnoreNonSolid, heights: [8]int{5, 3, 3, 3, 3, 3, 4, 4}},
+		{name: "all", filter: IgnoreLeaves | IgnoreLiquids | IgnoreNonSolid, heights: [8]int{3, 3, 3, 3, 3, 3, 4, 4}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, want := range test.heights {
+				if y, err := p.HighestBlock(5+i, 5, test.filter); err != nil {
+					t.Fatal(err)
+				} else if y != want {
+					t.Errorf("got height %v at X %v, expected %v", y, 5+i, want)
+				}
+			}
+		})
+	}
+
+	if err := os.Remove(filepath.Join(p.worldPath, chunkFilePath(1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	p, err := DecodeLevel(p.worldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y, err := p.HighestBlock(20, 20, 0); err != nil || y != -1 {
+		t.Errorf("got height %v, %v in a chunk without a chunk file, expected -1", y, err)
+	}
+}
+
+// TestHeightMapCache tests that cached height maps are cleared when a block ID in their chunk changes.
+func TestHeightMapCache(t *testing.T) {
+	p := newTestLevel(t, nil)
+	steps := []struct {
+		name string
+		pos  cube.Pos
+		id   byte
+		// height and other are the heights expected afterwards at X 5 and 6.
+		height, other int
+	}{
+		{name: "placed", pos: cube.Pos{5, 40, 5}, id: 1, height: 40, other: 3},
+		{name: "placed below", pos: cube.Pos{5, 20, 5}, id: 1, height: 40, other: 3},
+		{name: "removed", pos: cube.Pos{5, 40, 5}, height: 20, other: 3},
+		{name: "non-solid", pos: cube.Pos{6, 4, 5}, id: 50, height: 20, other: 4},
+	}
+	for _, filter := range []HeightFilter{0, IgnoreLeaves} {
+		if y, _ := p.HighestBlock(5, 5, filter); y != 3 {
+			t.Fatalf("got height %v before changing blocks, expected 3", y)
+		}
+	}
+	for _, step := range steps {
+		if err := p.SetBlockID(step.pos, step.id); err != nil {
+			t.Fatal(err)
+		}
+		for _, filter := range []HeightFilter{0, IgnoreLeaves} {
+			if y, _ := p.HighestBlock(5, 5, filter); y != step.height {
+				t.Errorf("%v: got height %v with filter %v, expected %v", step.name, y, filter, step.height)
+			}
+			if y, _ := p.HighestBlock(6, 5, filter); y != step.other {
+				t.Errorf("%v: got height %v next to it with filter %v, expected %v", step.name, y, filter, step.other)
+			}
+		}
+	}
+}
+
+// TestWriteHeightMapPNG tests that height maps are written as 16-bit grayscale PNGs with the heights spread over the
+// full range of grey values.
+func TestWriteHeightMapPNG(t *testing.T) {
+	p := newTestLevel(t, map[cube.Pos][2]byte{{5, 7, 5}: {1, 0}, {6, 127, 5}: {1, 0}, {7, 4, 5}: {50, 0}})
+	if err := os.Remove(filepath.Join(p.worldPath, chunkFilePath(1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	delete(p.chunkCache, getIndex(1, 1))
+	p.Generator = nil
+
+	buf := &bytes.Buffer{}
+	if err := p.WriteHeightMapPNG(buf, IgnoreNonSolid); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, ok := decoded.(*image.Gray16)
+	if !ok {
+		t.Fatalf("got a %T, expected a 16-bit grayscale image", decoded)
+	}
+	if img.Bounds() != image.Rect(0, 0, 32, 32) {
+		t.Fatalf("got bounds %v, expected 32x32", img.Bounds())
+	}
+	tests := []struct {
+		x, z int
+		grey uint16
+	}{
+		// A column with its highest block at Y 3 is 4 of 128 blocks high.
+		{x: 10, z: 10, grey: 4 * 0xffff / 128},
+		{x: 5, z: 5, grey: 8 * 0xffff / 128},
+		{x: 6, z: 5, grey: 0xffff},
+		{x: 7, z: 5, grey: 4 * 0xffff / 128},
+		// Chunks without a chunk file are black.
+		{x: 20, z: 20},
+	}
+	for _, test := range tests {
+		if g := img.Gray16At(test.x, test.z).Y; g != test.grey {
+			t.Errorf("got grey %v at %v, %v, expected %v", g, test.x, test.z, test.grey)
+		}
+	}
+}
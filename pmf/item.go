@@ -0,0 +1,63 @@
+package pmf
+
+// oldItem represents an item with a legacy ID and damage value.
+type oldItem struct {
+	id     int16
+	damage int16
+}
+
+// newItem represents an item with a name and metadata value.
+type newItem struct {
+	name string
+	meta int16
+}
+
+// variantItems holds the legacy IDs of items whose damage value selects a variant, such as dyes and spawn eggs,
+// rather than counting the uses of the item.
+var variantItems = map[int16]bool{}
+
+func init() {
+	for old := range itemConversion {
+		if old.damage != 0 {
+			variantItems[old.id] = true
+		}
+	}
+}
+
+// LookupItem translates a legacy item ID and damage value, as found in chests, player inventories and dropped item
+// entities, to the name and metadata value of the modern item. Items with an ID below 256 are block items and are
+// looked up in the block conversion table of the newest game version, keeping the damage value as metadata. Items
+// without variants, such as tools and armour, keep their damage value as well, as it holds how worn they are. False
+// is returned if the ID is unknown, or if the damage value is not one of the variants of an item that has them.
+func LookupItem(id, damage int) (name string, meta int16, ok bool) {
+	return lookupItem(GameVersionUnknown, id, damage)
+}
+
+// lookupItem translates a legacy item like LookupItem, looking up block items in the conversion table of a game
+// version.
+func lookupItem(v GameVersion, id, damage int) (name string, meta int16, ok bool) {
+	if id <= 0 || id > 0x7fff || damage < 0 || damage > 0x7fff {
+		return "", 0, false
+	}
+	if id < 256 {
+		b, ok := v.block(byte(id), 0)
+		if !ok {
+			return "", 0, false
+		}
+		return b.name, int16(damage), true
+	}
+	if i, ok := itemConversion[oldItem{id: int16(id), damage: int16(damage)}]; ok {
+		return i.name, i.meta, true
+	}
+	i, ok := itemConversion[oldItem{id: int16(id)}]
+	if !ok || variantItems[int16(id)] {
+		return "", 0, false
+	}
+	return i.name, int16(damage), true
+}
+
+// Name returns the name and metadata value of the modern item that the item converts to. False is returned if the
+// item ID is unknown.
+func (i Item) Name() (name string, meta int16, ok bool) {
+	return LookupItem(i.ID, i.Damage)
+}
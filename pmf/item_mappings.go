@@ -0,0 +1,152 @@
+package pmf
+
+// This file contains a mapping for id+damage => name+meta for the items with legacy IDs from 256 up that MCPE 0.x
+// had. It was written by hand and is not generated from any item list, so items missing from it are unknown. Block
+// items, with IDs below 256, are looked up in the block conversion table instead.
+
+// itemConversion holds a map that allows translating a PE item ID+damage to a modern item name and metadata value.
+var itemConversion = map[oldItem]newItem{
+	{id: 0x100, damage: 0x0}:  {name: "minecraft:iron_shovel", meta: 0x0},
+	{id: 0x101, damage: 0x0}:  {name: "minecraft:iron_pickaxe", meta: 0x0},
+	{id: 0x102, damage: 0x0}:  {name: "minecraft:iron_axe", meta: 0x0},
+	{id: 0x103, damage: 0x0}:  {name: "minecraft:flint_and_steel", meta: 0x0},
+	{id: 0x104, damage: 0x0}:  {name: "minecraft:apple", meta: 0x0},
+	{id: 0x105, damage: 0x0}:  {name: "minecraft:bow", meta: 0x0},
+	{id: 0x106, damage: 0x0}:  {name: "minecraft:arrow", meta: 0x0},
+	{id: 0x107, damage: 0x0}:  {name: "minecraft:coal", meta: 0x0},
+	{id: 0x107, damage: 0x1}:  {name: "minecraft:charcoal", meta: 0x0},
+	{id: 0x108, damage: 0x0}:  {name: "minecraft:diamond", meta: 0x0},
+	{id: 0x109, damage: 0x0}:  {name: "minecraft:iron_ingot", meta: 0x0},
+	{id: 0x10a, damage: 0x0}:  {name: "minecraft:gold_ingot", meta: 0x0},
+	{id: 0x10b, damage: 0x0}:  {name: "minecraft:iron_sword", meta: 0x0},
+	{id: 0x10c, damage: 0x0}:  {name: "minecraft:wooden_sword", meta: 0x0},
+	{id: 0x10d, damage: 0x0}:  {name: "minecraft:wooden_shovel", meta: 0x0},
+	{id: 0x10e, damage: 0x0}:  {name: "minecraft:wooden_pickaxe", meta: 0x0},
+	{id: 0x10f, damage: 0x0}:  {name: "minecraft:wooden_axe", meta: 0x0},
+	{id: 0x110, damage: 0x0}:  {name: "minecraft:stone_sword", meta: 0x0},
+	{id: 0x111, damage: 0x0}:  {name: "minecraft:stone_shovel", meta: 0x0},
+	{id: 0x112, damage: 0x0}:  {name: "minecraft:stone_pickaxe", meta: 0x0},
+	{id: 0x113, damage: 0x0}:  {name: "minecraft:stone_axe", meta: 0x0},
+	{id: 0x114, damage: 0x0}:  {name: "minecraft:diamond_sword", meta: 0x0},
+	{id: 0x115, damage: 0x0}:  {name: "minecraft:diamond_shovel", meta: 0x0},
+	{id: 0x116, damage: 0x0}:  {name: "minecraft:diamond_pickaxe", meta: 0x0},
+	{id: 0x117, damage: 0x0}:  {name: "minecraft:diamond_axe", meta: 0x0},
+	{id: 0x118, damage: 0x0}:  {name: "minecraft:stick", meta: 0x0},
+	{id: 0x119, damage: 0x0}:  {name: "minecraft:bowl", meta: 0x0},
+	{id: 0x11a, damage: 0x0}:  {name: "minecraft:mushroom_stew", meta: 0x0},
+	{id: 0x11b, damage: 0x0}:  {name: "minecraft:golden_sword", meta: 0x0},
+	{id: 0x11c, damage: 0x0}:  {name: "minecraft:golden_shovel", meta: 0x0},
+	{id: 0x11d, damage: 0x0}:  {name: "minecraft:golden_pickaxe", meta: 0x0},
+	{id: 0x11e, damage: 0x0}:  {name: "minecraft:golden_axe", meta: 0x0},
+	{id: 0x11f, damage: 0x0}:  {name: "minecraft:string", meta: 0x0},
+	{id: 0x120, damage: 0x0}:  {name: "minecraft:feather", meta: 0x0},
+	{id: 0x121, damage: 0x0}:  {name: "minecraft:gunpowder", meta: 0x0},
+	{id: 0x122, damage: 0x0}:  {name: "minecraft:wooden_hoe", meta: 0x0},
+	{id: 0x123, damage: 0x0}:  {name: "minecraft:stone_hoe", meta: 0x0},
+	{id: 0x124, damage: 0x0}:  {name: "minecraft:iron_hoe", meta: 0x0},
+	{id: 0x125, damage: 0x0}:  {name: "minecraft:diamond_hoe", meta: 0x0},
+	{id: 0x126, damage: 0x0}:  {name: "minecraft:golden_hoe", meta: 0x0},
+	{id: 0x127, damage: 0x0}:  {name: "minecraft:wheat_seeds", meta: 0x0},
+	{id: 0x128, damage: 0x0}:  {name: "minecraft:wheat", meta: 0x0},
+	{id: 0x129, damage: 0x0}:  {name: "minecraft:bread", meta: 0x0},
+	{id: 0x12a, damage: 0x0}:  {name: "minecraft:leather_helmet", meta: 0x0},
+	{id: 0x12b, damage: 0x0}:  {name: "minecraft:leather_chestplate", meta: 0x0},
+	{id: 0x12c, damage: 0x0}:  {name: "minecraft:leather_leggings", meta: 0x0},
+	{id: 0x12d, damage: 0x0}:  {name: "minecraft:leather_boots", meta: 0x0},
+	{id: 0x12e, damage: 0x0}:  {name: "minecraft:chainmail_helmet", meta: 0x0},
+	{id: 0x12f, damage: 0x0}:  {name: "minecraft:chainmail_chestplate", meta: 0x0},
+	{id: 0x130, damage: 0x0}:  {name: "minecraft:chainmail_leggings", meta: 0x0},
+	{id: 0x131, damage: 0x0}:  {name: "minecraft:chainmail_boots", meta: 0x0},
+	{id: 0x132, damage: 0x0}:  {name: "minecraft:iron_helmet", meta: 0x0},
+	{id: 0x133, damage: 0x0}:  {name: "minecraft:iron_chestplate", meta: 0x0},
+	{id: 0x134, damage: 0x0}:  {name: "minecraft:iron_leggings", meta: 0x0},
+	{id: 0x135, damage: 0x0}:  {name: "minecraft:iron_boots", meta: 0x0},
+	{id: 0x136, damage: 0x0}:  {name: "minecraft:diamond_helmet", meta: 0x0},
+	{id: 0x137, damage: 0x0}:  {name: "minecraft:diamond_chestplate", meta: 0x0},
+	{id: 0x138, damage: 0x0}:  {name: "minecraft:diamond_leggings", meta: 0x0},
+	{id: 0x139, damage: 0x0}:  {name: "minecraft:diamond_boots", meta: 0x0},
+	{id: 0x13a, damage: 0x0}:  {name: "minecraft:golden_helmet", meta: 0x0},
+	{id: 0x13b, damage: 0x0}:  {name: "minecraft:golden_chestplate", meta: 0x0},
+	{id: 0x13c, damage: 0x0}:  {name: "minecraft:golden_leggings", meta: 0x0},
+	{id: 0x13d, damage: 0x0}:  {name: "minecraft:golden_boots", meta: 0x0},
+	{id: 0x13e, damage: 0x0}:  {name: "minecraft:flint", meta: 0x0},
+	{id: 0x13f, damage: 0x0}:  {name: "minecraft:porkchop", meta: 0x0},
+	{id: 0x140, damage: 0x0}:  {name: "minecraft:cooked_porkchop", meta: 0x0},
+	{id: 0x141, damage: 0x0}:  {name: "minecraft:painting", meta: 0x0},
+	{id: 0x142, damage: 0x0}:  {name: "minecraft:golden_apple", meta: 0x0},
+	{id: 0x143, damage: 0x0}:  {name: "minecraft:oak_sign", meta: 0x0},
+	{id: 0x144, damage: 0x0}:  {name: "minecraft:wooden_door", meta: 0x0},
+	{id: 0x145, damage: 0x0}:  {name: "minecraft:bucket", meta: 0x0},
+	{id: 0x145, damage: 0x1}:  {name: "minecraft:milk_bucket", meta: 0x0},
+	{id: 0x145, damage: 0x8}:  {name: "minecraft:water_bucket", meta: 0x0},
+	{id: 0x145, damage: 0xa}:  {name: "minecraft:lava_bucket", meta: 0x0},
+	{id: 0x148, damage: 0x0}:  {name: "minecraft:minecart", meta: 0x0},
+	{id: 0x149, damage: 0x0}:  {name: "minecraft:saddle", meta: 0x0},
+	{id: 0x14a, damage: 0x0}:  {name: "minecraft:iron_door", meta: 0x0},
+	{id: 0x14b, damage: 0x0}:  {name: "minecraft:redstone", meta: 0x0},
+	{id: 0x14c, damage: 0x0}:  {name: "minecraft:snowball", meta: 0x0},
+	{id: 0x14e, damage: 0x0}:  {name: "minecraft:leather", meta: 0x0},
+	{id: 0x150, damage: 0x0}:  {name: "minecraft:brick", meta: 0x0},
+	{id: 0x151, damage: 0x0}:  {name: "minecraft:clay_ball", meta: 0x0},
+	{id: 0x152, damage: 0x0}:  {name: "minecraft:sugar_cane", meta: 0x0},
+	{id: 0x153, damage: 0x0}:  {name: "minecraft:paper", meta: 0x0},
+	{id: 0x154, damage: 0x0}:  {name: "minecraft:book", meta: 0x0},
+	{id: 0x155, damage: 0x0}:  {name: "minecraft:slime_ball", meta: 0x0},
+	{id: 0x158, damage: 0x0}:  {name: "minecraft:egg", meta: 0x0},
+	{id: 0x159, damage: 0x0}:  {name: "minecraft:compass", meta: 0x0},
+	{id: 0x15a, damage: 0x0}:  {name: "minecraft:fishing_rod", meta: 0x0},
+	{id: 0x15b, damage: 0x0}:  {name: "minecraft:clock", meta: 0x0},
+	{id: 0x15c, damage: 0x0}:  {name: "minecraft:glowstone_dust", meta: 0x0},
+	{id: 0x15d, damage: 0x0}:  {name: "minecraft:cod", meta: 0x0},
+	{id: 0x15e, damage: 0x0}:  {name: "minecraft:cooked_cod", meta: 0x0},
+	{id: 0x15f, damage: 0x0}:  {name: "minecraft:ink_sac", meta: 0x0},
+	{id: 0x15f, damage: 0x1}:  {name: "minecraft:red_dye", meta: 0x0},
+	{id: 0x15f, damage: 0x2}:  {name: "minecraft:green_dye", meta: 0x0},
+	{id: 0x15f, damage: 0x3}:  {name: "minecraft:cocoa_beans", meta: 0x0},
+	{id: 0x15f, damage: 0x4}:  {name: "minecraft:lapis_lazuli", meta: 0x0},
+	{id: 0x15f, damage: 0x5}:  {name: "minecraft:purple_dye", meta: 0x0},
+	{id: 0x15f, damage: 0x6}:  {name: "minecraft:cyan_dye", meta: 0x0},
+	{id: 0x15f, damage: 0x7}:  {name: "minecraft:light_gray_dye", meta: 0x0},
+	{id: 0x15f, damage: 0x8}:  {name: "minecraft:gray_dye", meta: 0x0},
+	{id: 0x15f, damage: 0x9}:  {name: "minecraft:pink_dye", meta: 0x0},
+	{id: 0x15f, damage: 0xa}:  {name: "minecraft:lime_dye", meta: 0x0},
+	{id: 0x15f, damage: 0xb}:  {name: "minecraft:yellow_dye", meta: 0x0},
+	{id: 0x15f, damage: 0xc}:  {name: "minecraft:light_blue_dye", meta: 0x0},
+	{id: 0x15f, damage: 0xd}:  {name: "minecraft:magenta_dye", meta: 0x0},
+	{id: 0x15f, damage: 0xe}:  {name: "minecraft:orange_dye", meta: 0x0},
+	{id: 0x15f, damage: 0xf}:  {name: "minecraft:bone_meal", meta: 0x0},
+	{id: 0x160, damage: 0x0}:  {name: "minecraft:bone", meta: 0x0},
+	{id: 0x161, damage: 0x0}:  {name: "minecraft:sugar", meta: 0x0},
+	{id: 0x162, damage: 0x0}:  {name: "minecraft:cake", meta: 0x0},
+	{id: 0x163, damage: 0x0}:  {name: "minecraft:bed", meta: 0xe},
+	{id: 0x165, damage: 0x0}:  {name: "minecraft:cookie", meta: 0x0},
+	{id: 0x167, damage: 0x0}:  {name: "minecraft:shears", meta: 0x0},
+	{id: 0x168, damage: 0x0}:  {name: "minecraft:melon_slice", meta: 0x0},
+	{id: 0x169, damage: 0x0}:  {name: "minecraft:pumpkin_seeds", meta: 0x0},
+	{id: 0x16a, damage: 0x0}:  {name: "minecraft:melon_seeds", meta: 0x0},
+	{id: 0x16b, damage: 0x0}:  {name: "minecraft:beef", meta: 0x0},
+	{id: 0x16c, damage: 0x0}:  {name: "minecraft:cooked_beef", meta: 0x0},
+	{id: 0x16d, damage: 0x0}:  {name: "minecraft:chicken", meta: 0x0},
+	{id: 0x16e, damage: 0x0}:  {name: "minecraft:cooked_chicken", meta: 0x0},
+	{id: 0x17f, damage: 0x0}:  {name: "minecraft:spawn_egg", meta: 0x0},
+	{id: 0x17f, damage: 0xa}:  {name: "minecraft:chicken_spawn_egg", meta: 0x0},
+	{id: 0x17f, damage: 0xb}:  {name: "minecraft:cow_spawn_egg", meta: 0x0},
+	{id: 0x17f, damage: 0xc}:  {name: "minecraft:pig_spawn_egg", meta: 0x0},
+	{id: 0x17f, damage: 0xd}:  {name: "minecraft:sheep_spawn_egg", meta: 0x0},
+	{id: 0x17f, damage: 0x20}: {name: "minecraft:zombie_spawn_egg", meta: 0x0},
+	{id: 0x17f, damage: 0x21}: {name: "minecraft:creeper_spawn_egg", meta: 0x0},
+	{id: 0x17f, damage: 0x22}: {name: "minecraft:skeleton_spawn_egg", meta: 0x0},
+	{id: 0x17f, damage: 0x23}: {name: "minecraft:spider_spawn_egg", meta: 0x0},
+	{id: 0x17f, damage: 0x24}: {name: "minecraft:zombie_pigman_spawn_egg", meta: 0x0},
+	{id: 0x184, damage: 0x0}:  {name: "minecraft:emerald", meta: 0x0},
+	{id: 0x187, damage: 0x0}:  {name: "minecraft:carrot", meta: 0x0},
+	{id: 0x188, damage: 0x0}:  {name: "minecraft:potato", meta: 0x0},
+	{id: 0x189, damage: 0x0}:  {name: "minecraft:baked_potato", meta: 0x0},
+	{id: 0x190, damage: 0x0}:  {name: "minecraft:pumpkin_pie", meta: 0x0},
+	{id: 0x195, damage: 0x0}:  {name: "minecraft:netherbrick", meta: 0x0},
+	{id: 0x196, damage: 0x0}:  {name: "minecraft:quartz", meta: 0x0},
+	{id: 0x1c8, damage: 0x0}:  {name: "minecraft:camera", meta: 0x0},
+	{id: 0x1c9, damage: 0x0}:  {name: "minecraft:beetroot", meta: 0x0},
+	{id: 0x1ca, damage: 0x0}:  {name: "minecraft:beetroot_seeds", meta: 0x0},
+	{id: 0x1cb, damage: 0x0}:  {name: "minecraft:beetroot_soup", meta: 0x0},
+}
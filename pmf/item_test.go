@@ -0,0 +1,42 @@
+package pmf
+
+import "testing"
+
+// TestLookupItem tests translating legacy item IDs and damage values of all kinds of items.
+func TestLookupItem(t *testing.T) {
+	tests := []struct {
+		name       string
+		id, damage int
+		want       string
+		meta       int16
+		ok         bool
+	}{
+		{name: "pickaxe", id: 257, want: "minecraft:iron_pickaxe", ok: true},
+		{name: "worn pickaxe", id: 257, damage: 50, want: "minecraft:iron_pickaxe", meta: 50, ok: true},
+		{name: "sword", id: 276, want: "minecraft:diamond_sword", ok: true},
+		{name: "helmet", id: 298, want: "minecraft:leather_helmet", ok: true},
+		{name: "worn chestplate", id: 307, damage: 12, want: "minecraft:iron_chestplate", meta: 12, ok: true},
+		{name: "bread", id: 297, want: "minecraft:bread", ok: true},
+		{name: "cooked porkchop", id: 320, want: "minecraft:cooked_porkchop", ok: true},
+		{name: "milk bucket", id: 325, damage: 1, want: "minecraft:milk_bucket", ok: true},
+		{name: "ink sac", id: 351, want: "minecraft:ink_sac", ok: true},
+		{name: "red dye", id: 351, damage: 1, want: "minecraft:red_dye", ok: true},
+		{name: "bone meal", id: 351, damage: 15, want: "minecraft:bone_meal", ok: true},
+		{name: "unknown dye", id: 351, damage: 16},
+		{name: "pig spawn egg", id: 383, damage: 12, want: "minecraft:pig_spawn_egg", ok: true},
+		{name: "zombie pigman spawn egg", id: 383, damage: 36, want: "minecraft:zombie_pigman_spawn_egg", ok: true},
+		{name: "unknown spawn egg", id: 383, damage: 99},
+		{name: "block item", id: 5, damage: 2, want: "minecraft:planks", meta: 2, ok: true},
+		{name: "unknown item", id: 5000},
+		{name: "air", id: 0},
+		{name: "negative damage", id: 257, damage: -1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name, meta, ok := LookupItem(test.id, test.damage)
+			if ok != test.ok || name != test.want || meta != test.meta {
+				t.Errorf("got %v:%v (%v), expected %v:%v (%v)", name, meta, ok, test.want, test.meta, test.ok)
+			}
+		})
+	}
+}
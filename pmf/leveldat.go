@@ -0,0 +1,124 @@
+package pmf
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"os"
+	"path/filepath"
+)
+
+// voidFlatWorldLayers is the flat world layer preset of a void world: a single layer of air.
+const voidFlatWorldLayers = `{"biome_id":1,"block_layers":[{"block_name":"minecraft:air","count":1}],"encoding_version":5,"structure_options":null}`
+
+// ConvertWorld converts the PMF level to the world in the directory passed, creating it if it doesn't exist yet, in
+// the same way as ConvertContext. Unlike ConvertContext, it also applies VoidGenerator and LimitedWorld, which are
+// stored in the level.dat of the world: the provider overwrites the level.dat when it is closed, so these settings are
+// written after closing it.
+func (p *Level) ConvertWorld(ctx context.Context, dir string, opts ConvertOptions) error {
+	return convertWorld(dir, func(prov *mcdb.Provider) error {
+		return p.convert(ctx, prov, opts)
+	}, p, opts)
+}
+
+// ConvertBatchWorld converts several levels into the world in the directory passed, creating it if it doesn't exist
+// yet, in the same way as ConvertBatch. VoidGenerator and LimitedWorld are applied like in Level.ConvertWorld, and
+// are taken from the options of the first level, like the name, spawn and time of the world. An error is returned if
+// they are set for any of the other levels.
+func ConvertBatchWorld(ctx context.Context, dir string, levels []BatchLevel, cfg Checkpoint) error {
+	if len(levels) == 0 {
+		return nil
+	}
+	for i, l := range levels[1:] {
+		if err := checkProviderOptions(l.Options); err != nil {
+			return fmt.Errorf("level %v: %w", i+1, err)
+		}
+	}
+	return convertWorld(dir, func(prov *mcdb.Provider) error {
+		return convertBatch(ctx, prov, levels, cfg)
+	}, levels[0].Level, levels[0].Options)
+}
+
+// checkProviderOptions returns an error if any of the options that a provider cannot store is set.
+func checkProviderOptions(opts ConvertOptions) error {
+	if opts.VoidGenerator || opts.LimitedWorld {
+		return fmt.Errorf("VoidGenerator and LimitedWorld are stored in the level.dat, which the provider cannot write: use ConvertWorld or ConvertBatchWorld")
+	}
+	return nil
+}
+
+// convertWorld opens a provider for the world in the directory passed, calls convert with it and closes it. If the
+// conversion succeeded, the settings of the options passed that are stored in the level.dat are written afterwards,
+// using the level passed.
+func convertWorld(dir string, convert func(prov *mcdb.Provider) error, p *Level, opts ConvertOptions) error {
+	prov, err := mcdb.New(dir)
+	if err != nil {
+		return err
+	}
+	err = convert(prov)
+	if closeErr := prov.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return p.writeLevelDat(dir, opts)
+}
+
+// writeLevelDat writes the settings from the conversion options passed that the provider cannot store itself to the
+// level.dat of the converted world in the directory passed, such as the world generator. It must be called after
+// the provider has been closed, as closing the provider overwrites the level.dat.
+func (p *Level) writeLevelDat(dir string, opts ConvertOptions) error {
+	if !opts.VoidGenerator && !opts.LimitedWorld {
+		return nil
+	}
+	return editLevelDat(dir, func(data map[string]interface{}) {
+		if opts.VoidGenerator {
+			// Generator 2 is the flat generator, which with a single layer of air generates nothing at all.
+			data["Generator"] = int32(2)
+			data["FlatWorldLayers"] = voidFlatWorldLayers
+		}
+		if opts.LimitedWorld {
+			// Generator 0 is the "Old" generator, the only one that respects the limited world size.
+			area := p.convertArea(opts)
+			data["Generator"] = int32(0)
+			data["LimitedWorldOriginX"] = int32(area.Min.X()) + opts.Offset.X()<<4
+			data["LimitedWorldOriginY"] = int32(p.Spawn.Y())
+			data["LimitedWorldOriginZ"] = int32(area.Min.Z()) + opts.Offset.Z()<<4
+			data["limitedWorldWidth"] = int32(area.Max.X() - area.Min.X() + 1)
+			data["limitedWorldDepth"] = int32(area.Max.Z() - area.Min.Z() + 1)
+		}
+	})
+}
+
+// editLevelDat decodes the level.dat in the directory passed, calls f with its data and writes the data back.
+func editLevelDat(dir string, f func(data map[string]interface{})) error {
+	levelDatPath := filepath.Join(dir, "level.dat")
+	b, err := os.ReadFile(levelDatPath)
+	if err != nil {
+		return err
+	}
+	// The first 8 bytes are the version and the length of the NBT data.
+	if len(b) < 8 {
+		return fmt.Errorf("level.dat has no data")
+	}
+	var data map[string]interface{}
+	if err := nbt.UnmarshalEncoding(b[8:], &data, nbt.LittleEndian); err != nil {
+		return fmt.Errorf("error decoding level.dat NBT: %w", err)
+	}
+
+	f(data)
+
+	nbtData, err := nbt.MarshalEncoding(data, nbt.LittleEndian)
+	if err != nil {
+		return fmt.Errorf("error encoding level.dat NBT: %w", err)
+	}
+	buf := bytes.NewBuffer(nil)
+	buf.Write(b[:4])
+	_ = binary.Write(buf, binary.LittleEndian, int32(len(nbtData)))
+	buf.Write(nbtData)
+	return os.WriteFile(levelDatPath, buf.Bytes(), 0644)
+}
@@ -0,0 +1,184 @@
+package pmf
+
+import (
+	"context"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readLevelDat decodes the level.dat of the world in the directory passed.
+func readLevelDat(t *testing.T, dir string) map[string]interface{} {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, "level.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := nbt.UnmarshalEncoding(b[8:], &data, nbt.LittleEndian); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// openWorld opens a provider for the world in the directory passed, which is closed when the test finishes.
+func openWorld(t *testing.T, dir string) *mcdb.Provider {
+	t.Helper()
+	prov, err := mcdb.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = prov.Close()
+	})
+	return prov
+}
+
+// TestConvertWorldVoid tests that ConvertWorld writes empty chunks in the void margin around the level and sets the
+// generator of the world to a void generator.
+func TestConvertWorldVoid(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ConvertOptions
+		// chunks holds chunks in the converted world and whether they should exist.
+		chunks    map[world.ChunkPos]bool
+		generator int32
+		layers    string
+	}{
+		{name: "default", chunks: map[world.ChunkPos]bool{{0, 0}: true, {-1, 0}: false, {2, 2}: false}, generator: 1},
+		{name: "void chunks", opts: ConvertOptions{VoidChunks: true, VoidMargin: 2}, chunks: map[world.ChunkPos]bool{
+			{-2, -2}: true, {3, 3}: true, {-1, 1}: true, {-3, 0}: false, {4, 0}: false,
+		}, generator: 1},
+		{name: "void generator", opts: ConvertOptions{VoidChunks: true, VoidMargin: 1, VoidGenerator: true}, chunks: map[world.ChunkPos]bool{
+			{-1, -1}: true, {2, 2}: true, {-2, 0}: false,
+		}, generator: 2, layers: voidFlatWorldLayers},
+		{name: "offset", opts: ConvertOptions{VoidChunks: true, VoidMargin: 1, VoidGenerator: true, Offset: world.ChunkPos{4, -3}}, chunks: map[world.ChunkPos]bool{
+			{4, -3}: true, {3, -4}: true, {6, -1}: true, {0, 0}: false, {2, -3}: false,
+		}, generator: 2, layers: voidFlatWorldLayers},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := newTestLevel(t, nil).ConvertWorld(context.Background(), dir, test.opts); err != nil {
+				t.Fatal(err)
+			}
+			data := readLevelDat(t, dir)
+			if g := data["Generator"]; g != test.generator {
+				t.Errorf("got generator %v, expected %v", g, test.generator)
+			}
+			if layers, _ := data["FlatWorldLayers"].(string); layers != test.layers {
+				t.Errorf("got flat world layers %q, expected %q", layers, test.layers)
+			}
+
+			prov := openWorld(t, dir)
+			for pos, want := range test.chunks {
+				if _, ok, err := prov.LoadChunk(pos); err != nil {
+					t.Fatal(err)
+				} else if ok != want {
+					t.Errorf("chunk %v exists: %v, expected %v", pos, ok, want)
+				}
+			}
+		})
+	}
+}
+
+// TestConvertProviderOptions tests that converting to a provider with options that only a level.dat can hold fails
+// instead of leaving them out silently.
+func TestConvertProviderOptions(t *testing.T) {
+	for _, opts := range []ConvertOptions{{VoidGenerator: true}, {LimitedWorld: true}} {
+		p := newTestLevel(t, nil)
+		if err := p.ConvertContext(context.Background(), openTestProvider(t), opts); err == nil {
+			t.Errorf("converting with %+v did not fail", opts)
+		}
+		if err := ConvertBatch(context.Background(), openTestProvider(t), []BatchLevel{{Level: p, Options: opts}}, Checkpoint{}); err == nil {
+			t.Errorf("converting a batch with %+v did not fail", opts)
+		}
+		levels := []BatchLevel{{Level: p}, {Level: newTestLevel(t, nil), Options: opts}}
+		levels[1].Options.Offset = world.ChunkPos{2, 0}
+		if err := ConvertBatchWorld(context.Background(), t.TempDir(), levels, Checkpoint{}); err == nil {
+			t.Errorf("converting a batch world with %+v for the second level did not fail", opts)
+		}
+	}
+}
+
+// TestConvertWorldBorder tests that the border wall is built right outside the converted area, and that limited
+// worlds are given the size and position of the area in the converted world.
+func TestConvertWorldBorder(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ConvertOptions
+		// blocks holds the names of blocks expected at positions in the converted world.
+		blocks map[cube.Pos]string
+		// limited holds the limited world settings expected in the level.dat, or nil if the world is not limited.
+		limited map[string]interface{}
+	}{
+		{name: "barrier", opts: ConvertOptions{BorderWall: BorderWallBarrier}, blocks: map[cube.Pos]string{
+			{-1, 0, 5}:    "minecraft:barrier",
+			{32, 100, 5}:  "minecraft:barrier",
+			{5, 255, -1}:  "minecraft:barrier",
+			{-1, 4, -1}:   "minecraft:barrier",
+			{32, 4, 32}:   "minecraft:barrier",
+			{0, 3, 0}:     "minecraft:stone",
+			{31, 4, 31}:   "minecraft:air",
+			{-2, 4, 5}:    "minecraft:air",
+			{-1, 4, -2}:   "minecraft:air",
+			{33, 4, 33}:   "minecraft:air",
+			{-16, 4, -16}: "minecraft:air",
+		}},
+		{name: "limited world at offset", opts: ConvertOptions{BorderWall: BorderWallInvisibleBedrock, LimitedWorld: true, Offset: world.ChunkPos{1, -2}}, blocks: map[cube.Pos]string{
+			{15, 50, -20}: "minecraft:invisibleBedrock",
+			{48, 0, -32}:  "minecraft:invisibleBedrock",
+			{30, 4, -33}:  "minecraft:invisibleBedrock",
+			{30, 4, 0}:    "minecraft:invisibleBedrock",
+			{16, 3, -32}:  "minecraft:stone",
+			{-1, 4, 5}:    "minecraft:air",
+		}, limited: map[string]interface{}{
+			"Generator": int32(0), "LimitedWorldOriginX": int32(16), "LimitedWorldOriginY": int32(5), "LimitedWorldOriginZ": int32(-32),
+			"limitedWorldWidth": int32(32), "limitedWorldDepth": int32(32),
+		}},
+		{name: "limited world of crop", opts: ConvertOptions{BorderWall: BorderWallBarrier, LimitedWorld: true, Offset: world.ChunkPos{1, 0}, Crop: &Box{Min: cube.Pos{4, 0, 4}, Max: cube.Pos{19, 127, 27}}}, blocks: map[cube.Pos]string{
+			{19, 4, 10}: "minecraft:barrier",
+			{36, 4, 10}: "minecraft:barrier",
+			{25, 4, 3}:  "minecraft:barrier",
+			{25, 4, 28}: "minecraft:barrier",
+			{20, 3, 4}:  "minecraft:stone",
+			{18, 3, 10}: "minecraft:air",
+		}, limited: map[string]interface{}{
+			"Generator": int32(0), "LimitedWorldOriginX": int32(20), "LimitedWorldOriginY": int32(5), "LimitedWorldOriginZ": int32(4),
+			"limitedWorldWidth": int32(16), "limitedWorldDepth": int32(24),
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := newTestLevel(t, nil).ConvertWorld(context.Background(), dir, test.opts); err != nil {
+				t.Fatal(err)
+			}
+			data := readLevelDat(t, dir)
+			if test.limited == nil && data["Generator"] != int32(1) {
+				t.Errorf("got generator %v, expected the default generator", data["Generator"])
+			}
+			for k, v := range test.limited {
+				if data[k] != v {
+					t.Errorf("got %v %v, expected %v", k, data[k], v)
+				}
+			}
+
+			prov := openWorld(t, dir)
+			for pos, want := range test.blocks {
+				if name := blockNameAt(t, prov, pos); name != want {
+					t.Errorf("got %v at %v, expected %v", name, pos, want)
+				}
+			}
+		})
+	}
+
+	opts := ConvertOptions{VoidGenerator: true, LimitedWorld: true}
+	if err := newTestLevel(t, nil).ConvertWorld(context.Background(), t.TempDir(), opts); err == nil {
+		t.Error("converting with both a void generator and a limited world did not fail")
+	}
+}
@@ -0,0 +1,311 @@
+package pmf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Mesh is a triangle mesh of the blocks in a box of a level, with one material for every type of block. Faces hidden
+// by neighbouring blocks are left out. Vertex positions are relative to the minimum corner of the box, with one unit
+// per block.
+type Mesh struct {
+	// materials holds the materials of the mesh, sorted by name.
+	materials []*meshMaterial
+}
+
+// meshMaterial is a material of a mesh, along with the triangles that use it.
+type meshMaterial struct {
+	name   string
+	colour color.RGBA
+	// positions and normals hold three floats for every vertex.
+	positions, normals []float32
+	// indices holds three vertex indices for every triangle.
+	indices []uint32
+}
+
+// meshFaces holds the normal and the four corners of each of the six faces of a block, in counter-clockwise order
+// when looking at the face from outside the block.
+var meshFaces = [6]struct {
+	normal  [3]int
+	corners [4][3]int
+}{
+	{normal: [3]int{0, 1, 0}, corners: [4][3]int{{0, 1, 0}, {0, 1, 1}, {1, 1, 1}, {1, 1, 0}}},
+	{normal: [3]int{0, -1, 0}, corners: [4][3]int{{0, 0, 0}, {1, 0, 0}, {1, 0, 1}, {0, 0, 1}}},
+	{normal: [3]int{1, 0, 0}, corners: [4][3]int{{1, 0, 0}, {1, 1, 0}, {1, 1, 1}, {1, 0, 1}}},
+	{normal: [3]int{-1, 0, 0}, corners: [4][3]int{{0, 0, 0}, {0, 0, 1}, {0, 1, 1}, {0, 1, 0}}},
+	{normal: [3]int{0, 0, 1}, corners: [4][3]int{{0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1}}},
+	{normal: [3]int{0, 0, -1}, corners: [4][3]int{{0, 0, 0}, {0, 1, 0}, {1, 1, 0}, {1, 0, 0}}},
+}
+
+// Mesh builds a triangle mesh of the blocks of the level inside a box, read through Chunk.BlockID and
+// Chunk.BlockMeta. If the box is nil, the whole level is meshed.
+func (p *Level) Mesh(box *Box) (*Mesh, error) {
+	r, err := p.readRegion(box)
+	if err != nil {
+		return nil, err
+	}
+	materials := make(map[[2]byte]*meshMaterial)
+	for x := 0; x < r.sizeX; x++ {
+		for z := 0; z < r.sizeZ; z++ {
+			for y := 0; y < r.sizeY; y++ {
+				id, meta := r.block(x, y, z)
+				if id == 0 {
+					continue
+				}
+				key := [2]byte{id, colourMeta(id, meta)}
+				m, ok := materials[key]
+				for _, face := range meshFaces {
+					if neighbour, _ := r.block(x+face.normal[0], y+face.normal[1], z+face.normal[2]); faceHidden(id, neighbour) {
+						continue
+					}
+					if !ok {
+						m = &meshMaterial{name: materialName(key[0], key[1])}
+						m.colour, _ = BlockColour(key[0], key[1])
+						materials[key], ok = m, true
+					}
+					m.addFace(x, y, z, face.normal, face.corners)
+				}
+			}
+		}
+	}
+
+	mesh := &Mesh{}
+	for _, m := range materials {
+		mesh.materials = append(mesh.materials, m)
+	}
+	sort.Slice(mesh.materials, func(i, j int) bool {
+		return mesh.materials[i].name < mesh.materials[j].name
+	})
+	return mesh, nil
+}
+
+// materialName returns the name of the material of a block: the modern name of the block, followed by the metadata
+// value if the colour of the block depends on it and by the legacy block ID.
+func materialName(id, meta byte) string {
+	b, ok := GameVersionUnknown.block(id, meta)
+	if !ok {
+		return fmt.Sprintf("block_%v_%v", id, meta)
+	}
+	name := strings.TrimPrefix(b.name, "minecraft:")
+	if colourMeta(id, 0xf) != 0 {
+		name = fmt.Sprintf("%v_%v", name, meta)
+	}
+	// Different legacy IDs may convert to the same block, so the ID is always kept in the name.
+	return fmt.Sprintf("%v_%v", name, id)
+}
+
+// addFace adds the two triangles of a block face to the material.
+func (m *meshMaterial) addFace(x, y, z int, normal [3]int, corners [4][3]int) {
+	first := uint32(len(m.positions) / 3)
+	for _, c := range corners {
+		m.positions = append(m.positions, float32(x+c[0]), float32(y+c[1]), float32(z+c[2]))
+		m.normals = append(m.normals, float32(normal[0]), float32(normal[1]), float32(normal[2]))
+	}
+	m.indices = append(m.indices, first, first+1, first+2, first, first+2, first+3)
+}
+
+// Triangles returns the amount of triangles in the mesh.
+func (m *Mesh) Triangles() int {
+	n := 0
+	for _, mat := range m.materials {
+		n += len(mat.indices) / 3
+	}
+	return n
+}
+
+// WriteOBJ writes the mesh as a Wavefront OBJ file to obj and its materials as an MTL file to mtl. The OBJ file
+// refers to the MTL file by the name passed, so the MTL file should be saved under that name next to it.
+func (m *Mesh) WriteOBJ(obj, mtl io.Writer, mtlName string) error {
+	w := bufio.NewWriter(obj)
+	_, _ = fmt.Fprintf(w, "mtllib %v\n", mtlName)
+	for _, face := range meshFaces {
+		_, _ = fmt.Fprintf(w, "vn %v %v %v\n", face.normal[0], face.normal[1], face.normal[2])
+	}
+	offset := 1
+	for _, mat := range m.materials {
+		_, _ = fmt.Fprintf(w, "o %v\nusemtl %v\n", mat.name, mat.name)
+		for i := 0; i < len(mat.positions); i += 3 {
+			_, _ = fmt.Fprintf(w, "v %v %v %v\n", mat.positions[i], mat.positions[i+1], mat.positions[i+2])
+		}
+		for i := 0; i < len(mat.indices); i += 3 {
+			normal := normalIndex(mat.normals[mat.indices[i]*3:])
+			_, _ = fmt.Fprintf(w, "f %v//%v %v//%v %v//%v\n",
+				int(mat.indices[i])+offset, normal, int(mat.indices[i+1])+offset, normal, int(mat.indices[i+2])+offset, normal)
+		}
+		offset += len(mat.positions) / 3
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	w = bufio.NewWriter(mtl)
+	for _, mat := range m.materials {
+		_, _ = fmt.Fprintf(w, "newmtl %v\nKd %.4f %.4f %.4f\n", mat.name,
+			float64(mat.colour.R)/255, float64(mat.colour.G)/255, float64(mat.colour.B)/255)
+		_, _ = fmt.Fprintf(w, "Ka 0 0 0\nKs 0 0 0\nd 1\nillum 1\n\n")
+	}
+	return w.Flush()
+}
+
+// normalIndex returns the 1-based index of the OBJ normal written for the normal at the start of the slice passed.
+func normalIndex(normal []float32) int {
+	for i, face := range meshFaces {
+		if float32(face.normal[0]) == normal[0] && float32(face.normal[1]) == normal[1] && float32(face.normal[2]) == normal[2] {
+			return i + 1
+		}
+	}
+	return 1
+}
+
+// WriteGLB writes the mesh as a binary glTF 2.0 file, with a primitive and a flat coloured material for every block
+// type.
+func (m *Mesh) WriteGLB(w io.Writer) error {
+	type accessor struct {
+		BufferView    int       `json:"bufferView"`
+		ComponentType int       `json:"componentType"`
+		Count         int       `json:"count"`
+		Type          string    `json:"type"`
+		Min           []float32 `json:"min,omitempty"`
+		Max           []float32 `json:"max,omitempty"`
+	}
+	type bufferView struct {
+		Buffer     int `json:"buffer"`
+		ByteOffset int `json:"byteOffset"`
+		ByteLength int `json:"byteLength"`
+		Target     int `json:"target"`
+	}
+	type primitive struct {
+		Attributes map[string]int `json:"attributes"`
+		Indices    int            `json:"indices"`
+		Material   int            `json:"material"`
+	}
+	type material struct {
+		Name string                 `json:"name"`
+		PBR  map[string]interface{} `json:"pbrMetallicRoughness"`
+	}
+	const (
+		float32Type   = 5126
+		uint32Type    = 5125
+		arrayBuffer   = 34962
+		elementBuffer = 34963
+		glbMagic      = 0x46546c67
+		glbVersion    = 2
+		jsonChunk     = 0x4e4f534a
+		binChunk      = 0x004e4942
+	)
+
+	bin := &bytes.Buffer{}
+	var accessors []accessor
+	var views []bufferView
+	var primitives []primitive
+	var materials []material
+	addView := func(data interface{}, target int) int {
+		offset := bin.Len()
+		_ = binary.Write(bin, binary.LittleEndian, data)
+		views = append(views, bufferView{ByteOffset: offset, ByteLength: bin.Len() - offset, Target: target})
+		return len(views) - 1
+	}
+	for i, mat := range m.materials {
+		min, max := boundsOf(mat.positions)
+		accessors = append(accessors, accessor{
+			BufferView: addView(mat.positions, arrayBuffer), ComponentType: float32Type,
+			Count: len(mat.positions) / 3, Type: "VEC3", Min: min, Max: max,
+		})
+		accessors = append(accessors, accessor{
+			BufferView: addView(mat.normals, arrayBuffer), ComponentType: float32Type,
+			Count: len(mat.normals) / 3, Type: "VEC3",
+		})
+		accessors = append(accessors, accessor{
+			BufferView: addView(mat.indices, elementBuffer), ComponentType: uint32Type,
+			Count: len(mat.indices), Type: "SCALAR",
+		})
+		n := len(accessors)
+		primitives = append(primitives, primitive{
+			Attributes: map[string]int{"POSITION": n - 3, "NORMAL": n - 2},
+			Indices:    n - 1,
+			Material:   i,
+		})
+		materials = append(materials, material{Name: mat.name, PBR: map[string]interface{}{
+			"baseColorFactor": []float64{srgbToLinear(mat.colour.R), srgbToLinear(mat.colour.G), srgbToLinear(mat.colour.B), 1},
+			"metallicFactor":  0,
+			"roughnessFactor": 1,
+		}})
+	}
+
+	doc := map[string]interface{}{
+		"asset":       map[string]string{"version": "2.0", "generator": "pmf"},
+		"scene":       0,
+		"scenes":      []map[string][]int{{"nodes": {0}}},
+		"nodes":       []map[string]int{{"mesh": 0}},
+		"meshes":      []map[string]interface{}{{"primitives": primitives}},
+		"materials":   materials,
+		"accessors":   accessors,
+		"bufferViews": views,
+		"buffers":     []map[string]int{{"byteLength": bin.Len()}},
+	}
+	if len(primitives) == 0 {
+		// glTF does not allow meshes without primitives, so an empty mesh is written as an empty scene.
+		delete(doc, "meshes")
+		doc["nodes"] = []map[string]int{{}}
+		delete(doc, "accessors")
+		delete(doc, "bufferViews")
+		delete(doc, "buffers")
+		delete(doc, "materials")
+	}
+	jsonData, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	// Both chunks must be padded to four bytes: the JSON chunk with spaces and the binary chunk with zeroes.
+	for len(jsonData)%4 != 0 {
+		jsonData = append(jsonData, ' ')
+	}
+	for bin.Len()%4 != 0 {
+		bin.WriteByte(0)
+	}
+
+	out := &bytes.Buffer{}
+	length := 12 + 8 + len(jsonData)
+	if bin.Len() > 0 {
+		length += 8 + bin.Len()
+	}
+	_ = binary.Write(out, binary.LittleEndian, []uint32{glbMagic, glbVersion, uint32(length)})
+	_ = binary.Write(out, binary.LittleEndian, []uint32{uint32(len(jsonData)), jsonChunk})
+	out.Write(jsonData)
+	if bin.Len() > 0 {
+		_ = binary.Write(out, binary.LittleEndian, []uint32{uint32(bin.Len()), binChunk})
+		out.Write(bin.Bytes())
+	}
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+// boundsOf returns the minimum and maximum of a list of three component vectors.
+func boundsOf(v []float32) ([]float32, []float32) {
+	min := []float32{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+	max := []float32{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+	for i := 0; i < len(v); i += 3 {
+		for j := 0; j < 3; j++ {
+			min[j] = float32(math.Min(float64(min[j]), float64(v[i+j])))
+			max[j] = float32(math.Max(float64(max[j]), float64(v[i+j])))
+		}
+	}
+	return min, max
+}
+
+// srgbToLinear converts an sRGB colour component to a linear colour factor, as glTF material colours are linear.
+func srgbToLinear(c uint8) float64 {
+	v := float64(c) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
@@ -0,0 +1,170 @@
+package pmf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"strings"
+	"testing"
+)
+
+// newMeshTestMesh meshes a region of a stone block with a gold block next to it along X.
+func newMeshTestMesh(t *testing.T) *Mesh {
+	t.Helper()
+	p := newTestLevel(t, map[cube.Pos][2]byte{{6, 3, 5}: {41, 0}})
+	m, err := p.Mesh(&Box{Min: cube.Pos{5, 3, 5}, Max: cube.Pos{6, 3, 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// TestMesh tests that the faces between two blocks are left out of their mesh, and that all others are meshed with
+// the material of their block.
+func TestMesh(t *testing.T) {
+	m := newMeshTestMesh(t)
+	if n := m.Triangles(); n != 20 {
+		t.Errorf("got %v triangles, expected 20 for the 10 visible faces", n)
+	}
+	tests := []struct {
+		material string
+		// hidden is the normal of the face that is hidden by the other block.
+		hidden [3]float32
+	}{
+		{material: "gold_block_41", hidden: [3]float32{-1, 0, 0}},
+		{material: "stone_1", hidden: [3]float32{1, 0, 0}},
+	}
+	if len(m.materials) != len(tests) {
+		t.Fatalf("got %v materials, expected %v", len(m.materials), len(tests))
+	}
+	for i, test := range tests {
+		mat := m.materials[i]
+		if mat.name != test.material {
+			t.Errorf("got material %v, expected %v", mat.name, test.material)
+		}
+		if len(mat.indices) != 30 || len(mat.positions) != 60 || len(mat.normals) != 60 {
+			t.Errorf("material %v has %v indices and %v positions, expected 5 faces", mat.name, len(mat.indices), len(mat.positions)/3)
+		}
+		for j := 0; j < len(mat.normals); j += 3 {
+			if [3]float32{mat.normals[j], mat.normals[j+1], mat.normals[j+2]} == test.hidden {
+				t.Errorf("material %v has a face with normal %v, which is hidden", mat.name, test.hidden)
+				break
+			}
+		}
+	}
+}
+
+// TestMeshWriteOBJ tests that the OBJ file of a mesh holds every face and refers to the materials written to the MTL
+// file.
+func TestMeshWriteOBJ(t *testing.T) {
+	obj, mtl := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := newMeshTestMesh(t).WriteOBJ(obj, mtl, "test.mtl"); err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, line := range strings.Split(obj.String(), "\n") {
+		counts[strings.SplitN(line, " ", 2)[0]]++
+	}
+	want := map[string]int{"mtllib": 1, "vn": 6, "o": 2, "usemtl": 2, "v": 40, "f": 20}
+	for k, n := range want {
+		if counts[k] != n {
+			t.Errorf("got %v %q lines, expected %v", counts[k], k, n)
+		}
+	}
+	for _, name := range []string{"gold_block_41", "stone_1"} {
+		if !strings.Contains(obj.String(), "usemtl "+name+"\n") || !strings.Contains(mtl.String(), "newmtl "+name+"\n") {
+			t.Errorf("material %v is missing from the OBJ or MTL file", name)
+		}
+	}
+}
+
+// TestMeshWriteGLB tests that the binary glTF file of a mesh has a valid header and a JSON chunk whose buffer views
+// and accessors match the binary chunk, also for an empty mesh.
+func TestMeshWriteGLB(t *testing.T) {
+	empty, err := newTestLevel(t, nil).Mesh(&Box{Min: cube.Pos{0, 10, 0}, Max: cube.Pos{3, 12, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		mesh       *Mesh
+		primitives int
+	}{
+		{name: "two blocks", mesh: newMeshTestMesh(t), primitives: 2},
+		{name: "empty", mesh: empty},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := test.mesh.WriteGLB(buf); err != nil {
+				t.Fatal(err)
+			}
+			b := buf.Bytes()
+			var header [5]uint32
+			if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, &header); err != nil {
+				t.Fatal(err)
+			}
+			if header[0] != 0x46546c67 || header[1] != 2 || int(header[2]) != len(b) {
+				t.Fatalf("got header % x for a file of %v bytes", header[:3], len(b))
+			}
+			if header[4] != 0x4e4f534a || header[3]%4 != 0 {
+				t.Fatalf("got JSON chunk of type %#x and length %v", header[4], header[3])
+			}
+			var doc struct {
+				Meshes []struct {
+					Primitives []json.RawMessage `json:"primitives"`
+				} `json:"meshes"`
+				Accessors []struct {
+					BufferView int    `json:"bufferView"`
+					Count      int    `json:"count"`
+					Type       string `json:"type"`
+				} `json:"accessors"`
+				BufferViews []struct {
+					ByteOffset int `json:"byteOffset"`
+					ByteLength int `json:"byteLength"`
+				} `json:"bufferViews"`
+				Buffers []struct {
+					ByteLength int `json:"byteLength"`
+				} `json:"buffers"`
+			}
+			jsonEnd := 20 + int(header[3])
+			if err := json.Unmarshal(b[20:jsonEnd], &doc); err != nil {
+				t.Fatal(err)
+			}
+			if test.primitives == 0 {
+				if len(doc.Meshes) != 0 || len(doc.Buffers) != 0 || jsonEnd != len(b) {
+					t.Errorf("empty mesh has %v meshes, %v buffers and %v bytes after the JSON chunk", len(doc.Meshes), len(doc.Buffers), len(b)-jsonEnd)
+				}
+				return
+			}
+			if len(doc.Meshes) != 1 || len(doc.Meshes[0].Primitives) != test.primitives {
+				t.Fatalf("got meshes %+v, expected one with %v primitives", doc.Meshes, test.primitives)
+			}
+
+			var binHeader [2]uint32
+			if err := binary.Read(bytes.NewReader(b[jsonEnd:]), binary.LittleEndian, &binHeader); err != nil {
+				t.Fatal(err)
+			}
+			if binHeader[1] != 0x004e4942 || jsonEnd+8+int(binHeader[0]) != len(b) || len(doc.Buffers) != 1 || doc.Buffers[0].ByteLength != int(binHeader[0]) {
+				t.Fatalf("got binary chunk of type %#x and length %v, and buffers %+v", binHeader[1], binHeader[0], doc.Buffers)
+			}
+			offset := 0
+			for i, view := range doc.BufferViews {
+				if view.ByteOffset != offset {
+					t.Errorf("buffer view %v starts at %v, expected %v", i, view.ByteOffset, offset)
+				}
+				offset += view.ByteLength
+			}
+			if offset != doc.Buffers[0].ByteLength {
+				t.Errorf("buffer views hold %v bytes, expected %v", offset, doc.Buffers[0].ByteLength)
+			}
+			components := map[string]int{"VEC3": 3, "SCALAR": 1}
+			for i, a := range doc.Accessors {
+				if size := a.Count * components[a.Type] * 4; size != doc.BufferViews[a.BufferView].ByteLength {
+					t.Errorf("accessor %v holds %v bytes, but its buffer view holds %v", i, size, doc.BufferViews[a.BufferView].ByteLength)
+				}
+			}
+		})
+	}
+}
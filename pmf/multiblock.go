@@ -0,0 +1,161 @@
+package pmf
+
+import (
+	"fmt"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+)
+
+// multiBlockFixer fixes the state of a block that is part of a structure made up of multiple blocks, such as a door.
+// The conversion table converts every ID and metadata value on its own, but legacy versions split the state of these
+// blocks over all of their parts, so the neighbours of the block are needed to find its full state. If the block has
+// no valid partner, false is returned and the block is converted as is.
+type multiBlockFixer func(l *Level, pos cube.Pos, id, meta byte) (newBlock, bool)
+
+// multiBlockFixers holds the multiBlockFixer of every legacy block ID that is part of a multi block structure.
+var multiBlockFixers = map[byte]multiBlockFixer{
+	26:  fixBed,
+	64:  fixDoor, // Wooden door.
+	71:  fixDoor, // Iron door.
+	175: fixDoublePlant,
+	193: fixDoor, // Spruce door.
+	194: fixDoor, // Birch door.
+	195: fixDoor, // Jungle door.
+	196: fixDoor, // Acacia door.
+	197: fixDoor, // Dark oak door.
+}
+
+// fixMultiBlocks fixes the state of all multi block structures at the positions passed in a converted chunk, and
+// returns the block entities that these blocks need.
+func (c *converter) fixMultiBlocks(ch *chunk.Chunk, positions []cube.Pos) ([]map[string]interface{}, error) {
+	var blockEntities []map[string]interface{}
+	for _, pos := range positions {
+		id, err := c.level.BlockID(pos)
+		if err != nil {
+			return nil, err
+		}
+		meta, err := c.level.BlockMeta(pos)
+		if err != nil {
+			return nil, err
+		}
+		if id == 26 {
+			// Modern beds are coloured through their block entity. Legacy beds were always red.
+			blockEntities = append(blockEntities, map[string]interface{}{
+				"id":    "Bed",
+				"color": byte(14),
+				"x":     int32(pos.X()),
+				"y":     int32(pos.Y()),
+				"z":     int32(pos.Z()),
+			})
+		}
+
+		b, ok := multiBlockFixers[id](c.level, pos, id, meta)
+		if !ok {
+			continue
+		}
+		rid, ok := chunk.StateToRuntimeID(b.name, b.properties)
+		if !ok {
+			return nil, fmt.Errorf("could not find runtime id for state: %v, %v", b.name, b.properties)
+		}
+		ch.SetRuntimeID(uint8(pos.X()), int16(pos.Y()), uint8(pos.Z()), 0, rid)
+	}
+	return blockEntities, nil
+}
+
+// fixDoor fixes the state of a door half. Legacy doors store their direction and whether they are open only in the
+// lower half, and their hinge side only in the upper half, which is marked by the 0x8 bit. Modern doors hold the full
+// state in both halves.
+func fixDoor(l *Level, pos cube.Pos, id, meta byte) (newBlock, bool) {
+	lower, upper := meta, meta
+	if meta&0x8 != 0 {
+		partner, ok := partnerMeta(l, pos.Side(cube.FaceDown), id)
+		if !ok || partner&0x8 != 0 {
+			return newBlock{}, false
+		}
+		lower = partner
+	} else {
+		partner, ok := partnerMeta(l, pos.Side(cube.FaceUp), id)
+		if !ok || partner&0x8 == 0 {
+			return newBlock{}, false
+		}
+		upper = partner
+	}
+	b, ok := lookupBlock(id, 0)
+	if !ok {
+		return newBlock{}, false
+	}
+	return newBlock{name: b.name, properties: map[string]interface{}{
+		"direction":       int32(lower & 0x3),
+		"open_bit":        (lower >> 2) & 0x1,
+		"door_hinge_bit":  upper & 0x1,
+		"upper_block_bit": (meta >> 3) & 0x1,
+	}}, true
+}
+
+// bedOffsets holds the offset from the foot to the head of a bed for each legacy bed direction.
+var bedOffsets = [4][2]int{{0, 1}, {-1, 0}, {0, -1}, {1, 0}}
+
+// fixBed fixes the state of a bed half. Legacy beds hold their direction in both halves, with the head marked by the
+// 0x8 bit, but halves of beds broken or edited with external tools often disagree. The direction of the foot is
+// used for both halves, and beds are never converted as occupied, as nobody is sleeping in them.
+func fixBed(l *Level, pos cube.Pos, id, meta byte) (newBlock, bool) {
+	head := meta&0x8 != 0
+	offset := bedOffsets[meta&0x3]
+	var partnerPos cube.Pos
+	if head {
+		partnerPos = cube.Pos{pos.X() - offset[0], pos.Y(), pos.Z() - offset[1]}
+	} else {
+		partnerPos = cube.Pos{pos.X() + offset[0], pos.Y(), pos.Z() + offset[1]}
+	}
+	partner, ok := partnerMeta(l, partnerPos, id)
+	if !ok || (partner&0x8 != 0) == head {
+		return newBlock{}, false
+	}
+	direction := meta & 0x3
+	if head {
+		direction = partner & 0x3
+	}
+	return newBlock{name: "minecraft:bed", properties: map[string]interface{}{
+		"direction":      int32(direction),
+		"head_piece_bit": boolByte(head),
+		"occupied_bit":   uint8(0),
+	}}, true
+}
+
+// doublePlantTypes holds the double_plant_type of every legacy double plant type.
+var doublePlantTypes = [...]string{"sunflower", "syringa", "grass", "fern", "rose", "paeonia"}
+
+// fixDoublePlant fixes the state of a double plant or tall flower half. Legacy double plants only store their type
+// in the lower half, and the upper half, marked by the 0x8 bit, has to take it from the block below.
+func fixDoublePlant(l *Level, pos cube.Pos, id, meta byte) (newBlock, bool) {
+	lower := meta
+	if meta&0x8 != 0 {
+		partner, ok := partnerMeta(l, pos.Side(cube.FaceDown), id)
+		if !ok || partner&0x8 != 0 {
+			return newBlock{}, false
+		}
+		lower = partner
+	}
+	plantType := doublePlantTypes[0]
+	if int(lower&0x7) < len(doublePlantTypes) {
+		plantType = doublePlantTypes[lower&0x7]
+	}
+	return newBlock{name: "minecraft:double_plant", properties: map[string]interface{}{
+		"double_plant_type": plantType,
+		"upper_block_bit":   (meta >> 3) & 0x1,
+	}}, true
+}
+
+// partnerMeta returns the metadata of the block at a position if it has the ID passed. False is returned if the
+// block has a different ID or is outside of the level.
+func partnerMeta(l *Level, pos cube.Pos, id byte) (byte, bool) {
+	partnerID, err := l.BlockID(pos)
+	if err != nil || partnerID != id {
+		return 0, false
+	}
+	meta, err := l.BlockMeta(pos)
+	if err != nil {
+		return 0, false
+	}
+	return meta, true
+}
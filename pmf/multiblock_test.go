@@ -0,0 +1,75 @@
+package pmf
+
+import (
+	"context"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"reflect"
+	"testing"
+)
+
+// TestFixMultiBlocks tests that the halves of doors, beds and double plants are converted with the state held by
+// both halves, and that halves without a partner are converted as they are.
+func TestFixMultiBlocks(t *testing.T) {
+	lower, upper := cube.Pos{5, 4, 5}, cube.Pos{5, 5, 5}
+	foot, head := cube.Pos{8, 4, 8}, cube.Pos{8, 4, 9}
+	tests := []struct {
+		name   string
+		blocks map[cube.Pos][2]byte
+		pos    cube.Pos
+		want   newBlock
+	}{
+		{name: "lower door", blocks: map[cube.Pos][2]byte{lower: {64, 1}, upper: {64, 9}}, pos: lower, want: newBlock{name: "minecraft:wooden_door", properties: map[string]interface{}{
+			"direction": int32(1), "open_bit": uint8(0), "door_hinge_bit": uint8(1), "upper_block_bit": uint8(0),
+		}}},
+		{name: "upper door", blocks: map[cube.Pos][2]byte{lower: {64, 1}, upper: {64, 9}}, pos: upper, want: newBlock{name: "minecraft:wooden_door", properties: map[string]interface{}{
+			"direction": int32(1), "open_bit": uint8(0), "door_hinge_bit": uint8(1), "upper_block_bit": uint8(1),
+		}}},
+		{name: "open upper iron door", blocks: map[cube.Pos][2]byte{lower: {71, 6}, upper: {71, 8}}, pos: upper, want: newBlock{name: "minecraft:iron_door", properties: map[string]interface{}{
+			"direction": int32(2), "open_bit": uint8(1), "door_hinge_bit": uint8(0), "upper_block_bit": uint8(1),
+		}}},
+		{name: "door of other type", blocks: map[cube.Pos][2]byte{lower: {71, 6}, upper: {64, 9}}, pos: upper},
+		{name: "two lower doors", blocks: map[cube.Pos][2]byte{lower: {64, 1}, upper: {64, 1}}, pos: lower},
+		{name: "bed foot", blocks: map[cube.Pos][2]byte{foot: {26, 0}, head: {26, 8}}, pos: foot, want: newBlock{name: "minecraft:bed", properties: map[string]interface{}{
+			"direction": int32(0), "head_piece_bit": uint8(0), "occupied_bit": uint8(0),
+		}}},
+		{name: "occupied bed head", blocks: map[cube.Pos][2]byte{foot: {26, 0}, head: {26, 12}}, pos: head, want: newBlock{name: "minecraft:bed", properties: map[string]interface{}{
+			"direction": int32(0), "head_piece_bit": uint8(1), "occupied_bit": uint8(0),
+		}}},
+		{name: "bed head facing away", blocks: map[cube.Pos][2]byte{foot: {26, 0}, head: {26, 10}}, pos: head},
+		{name: "bed foot without head", blocks: map[cube.Pos][2]byte{foot: {26, 1}}, pos: foot},
+		{name: "upper rose bush", blocks: map[cube.Pos][2]byte{lower: {175, 4}, upper: {175, 8}}, pos: upper, want: newBlock{name: "minecraft:double_plant", properties: map[string]interface{}{
+			"double_plant_type": "rose", "upper_block_bit": uint8(1),
+		}}},
+		{name: "unknown double plant", blocks: map[cube.Pos][2]byte{lower: {175, 7}, upper: {175, 8}}, pos: upper, want: newBlock{name: "minecraft:double_plant", properties: map[string]interface{}{
+			"double_plant_type": "sunflower", "upper_block_bit": uint8(1),
+		}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := test.want
+			if want.name == "" {
+				// Halves without a valid partner are converted using the conversion table.
+				b := test.blocks[test.pos]
+				want, _ = GameVersionUnknown.block(b[0], b[1])
+			}
+			prov := openTestProvider(t)
+			if err := newTestLevel(t, test.blocks).ConvertContext(context.Background(), prov, ConvertOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			ch, _, err := prov.LoadChunk(chunkPosOf(test.pos))
+			if err != nil {
+				t.Fatal(err)
+			}
+			name, properties, _ := chunk.RuntimeIDToState(ch.RuntimeID(uint8(test.pos.X()), int16(test.pos.Y()), uint8(test.pos.Z()), 0))
+			if name != want.name || !reflect.DeepEqual(properties, want.properties) {
+				t.Errorf("got %v %v, expected %v %v", name, properties, want.name, want.properties)
+			}
+			if test.blocks[test.pos][0] == 26 {
+				if data := blockEntityAt(t, prov, test.pos); data == nil || data["color"] != byte(14) {
+					t.Errorf("got bed block entity %v, expected a red bed", data)
+				}
+			}
+		})
+	}
+}
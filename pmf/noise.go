@@ -0,0 +1,129 @@
+package pmf
+
+import "math"
+
+// random is an xorshift random number generator, modelled after the one PocketMine uses to generate terrain.
+type random struct {
+	x, y, z, w uint32
+}
+
+// newRandom returns a random number generator seeded with the seed passed.
+func newRandom(seed uint32) *random {
+	r := &random{}
+	r.setSeed(seed)
+	return r
+}
+
+// setSeed resets the random number generator to the state of a seed.
+func (r *random) setSeed(seed uint32) {
+	r.x = 123456789 ^ seed
+	r.y = 362436069 ^ (seed<<17 | seed>>15)
+	r.z = 521288629 ^ (seed<<31 | seed>>1)
+	r.w = 88675123 ^ (seed<<18 | seed>>14)
+}
+
+// nextInt returns the next random non-negative 31-bit integer.
+func (r *random) nextInt() int {
+	t := r.x ^ (r.x << 11)
+	r.x, r.y, r.z = r.y, r.z, r.w
+	r.w = r.w ^ (r.w >> 19) ^ (t ^ (t >> 8))
+	return int(r.w & 0x7fffffff)
+}
+
+// nextBoundedInt returns the next random integer in the range [0, bound).
+func (r *random) nextBoundedInt(bound int) int {
+	return r.nextInt() % bound
+}
+
+// nextFloat returns the next random float in the range [0, 1].
+func (r *random) nextFloat() float64 {
+	return float64(r.nextInt()) / 0x7fffffff
+}
+
+// simplexGradients holds the gradients used for 2D simplex noise.
+var simplexGradients = [12][2]float64{
+	{1, 1}, {-1, 1}, {1, -1}, {-1, -1},
+	{1, 0}, {-1, 0}, {1, 0}, {-1, 0},
+	{0, 1}, {0, -1}, {0, 1}, {0, -1},
+}
+
+// simplexNoise is an octave simplex noise generator, like the NoiseGeneratorSimplex of PocketMine.
+type simplexNoise struct {
+	octaves          int
+	offsetX, offsetZ float64
+	perm             [512]int
+}
+
+// newSimplexNoise creates a simplex noise generator with a permutation table shuffled by the random passed.
+func newSimplexNoise(r *random, octaves int) *simplexNoise {
+	n := &simplexNoise{octaves: octaves}
+	n.offsetX = r.nextFloat() * 256
+	_ = r.nextFloat() * 256 // The Y offset, which is unused for 2D noise.
+	n.offsetZ = r.nextFloat() * 256
+	for i := 0; i < 256; i++ {
+		n.perm[i] = r.nextBoundedInt(256)
+	}
+	for i := 0; i < 256; i++ {
+		pos := r.nextBoundedInt(256-i) + i
+		n.perm[i], n.perm[pos] = n.perm[pos], n.perm[i]
+		n.perm[i+256] = n.perm[i]
+	}
+	return n
+}
+
+// noise2D returns the noise at a position, summing all octaves. Every octave multiplies the frequency and amplitude
+// of the previous one by the values passed. If normalised is true, the result is in the range [-1, 1].
+func (n *simplexNoise) noise2D(x, z, frequency, amplitude float64, normalised bool) float64 {
+	var result, max float64
+	freq, amp := 1.0, 1.0
+	for i := 0; i < n.octaves; i++ {
+		result += n.noise(x*freq, z*freq) * amp
+		max += amp
+		freq *= frequency
+		amp *= amplitude
+	}
+	if normalised {
+		result /= max
+	}
+	return result
+}
+
+// noise returns the 2D simplex noise of a single octave at a position.
+func (n *simplexNoise) noise(x, z float64) float64 {
+	const (
+		f2 = 0.36602540378443864676 // 0.5 * (sqrt(3) - 1)
+		g2 = 0.21132486540518711775 // (3 - sqrt(3)) / 6
+	)
+	x += n.offsetX
+	z += n.offsetZ
+
+	s := (x + z) * f2
+	i, j := math.Floor(x+s), math.Floor(z+s)
+	t := (i + j) * g2
+	x0, z0 := x-(i-t), z-(j-t)
+
+	i1, j1 := 0, 1
+	if x0 > z0 {
+		i1, j1 = 1, 0
+	}
+	x1, z1 := x0-float64(i1)+g2, z0-float64(j1)+g2
+	x2, z2 := x0-1+2*g2, z0-1+2*g2
+
+	ii, jj := int(i)&255, int(j)&255
+	gi0 := n.perm[ii+n.perm[jj]] % 12
+	gi1 := n.perm[ii+i1+n.perm[jj+j1]] % 12
+	gi2 := n.perm[ii+1+n.perm[jj+1]] % 12
+
+	return 70 * (corner(gi0, x0, z0) + corner(gi1, x1, z1) + corner(gi2, x2, z2))
+}
+
+// corner returns the contribution of a single simplex corner to the noise.
+func corner(gradient int, x, z float64) float64 {
+	t := 0.5 - x*x - z*z
+	if t < 0 {
+		return 0
+	}
+	t *= t
+	g := simplexGradients[gradient]
+	return t * t * (g[0]*x + g[1]*z)
+}
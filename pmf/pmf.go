@@ -2,18 +2,31 @@ package pmf
 
 import (
 	"bytes"
-	"compress/gzip"
+	"fmt"
 	"github.com/df-mc/dragonfly/server/block/cube"
 	"github.com/go-gl/mathgl/mgl32"
-	"gopkg.in/yaml.v2"
-	"io/ioutil"
-	"math"
+	"io"
 	"os"
 	"path"
 )
 
-// currentVersion is the current version of the PMF format.
-const currentVersion = 0x00
+const (
+	// currentVersion is the current version of the PMF format.
+	currentVersion = 0x00
+
+	// maxWidth is the maximum width of a PMF world in chunks. Chunk indices are only four bits per axis, so worlds
+	// can never be wider than 16 chunks.
+	maxWidth = 16
+	// maxHeight is the maximum height of a PMF world in sub chunks.
+	maxHeight = 8
+
+	// maxLevelFileSize is the maximum size of a level.pmf file that will be read.
+	maxLevelFileSize = 1 << 20
+	// maxChunkFileSize is the maximum size of a compressed chunk file that will be read.
+	maxChunkFileSize = 1 << 20
+	// maxYAMLFileSize is the maximum size of a tiles.yml or entities.yml file that will be read.
+	maxYAMLFileSize = 8 << 20
+)
 
 // Level is the decoded level.pmf file.
 type Level struct {
@@ -31,6 +44,13 @@ type Level struct {
 	Width uint8
 	// Height is the height of the world.
 	Height uint8
+	// GameVersion is the game version the world was saved by, which decides the table used to convert its blocks.
+	// It is GameVersionUnknown unless set manually or through DetectGameVersion, in which case the version is
+	// detected when it is first needed.
+	GameVersion GameVersion
+	// Generator generates the chunks of the world that have no chunk file or an empty one. If nil, loading these
+	// chunks fails.
+	Generator Generator
 
 	// chunkCache is a cache from chunk index to chunk.
 	chunkCache map[int]*Chunk
@@ -38,17 +58,37 @@ type Level struct {
 	locationMappings map[int]uint16
 	// worldPath is the path to the world.
 	worldPath string
-	// tiles contains a slice of all block entities in the world.
-	tiles []map[string]interface{}
+	// tiles contains all block entities in the world, indexed by their position.
+	tiles map[cube.Pos]Tile
+	// entities contains a slice of all entities in the world.
+	entities []Entity
+	// warnings holds the problems found while decoding the level that did not stop it from being decoded.
+	warnings []string
+	// detectedVersion is the game version detected for a level with an unknown game version, or GameVersionUnknown
+	// if it has not been detected yet.
+	detectedVersion GameVersion
 }
 
-// Block gets a block name and properties from a position.
+// Block gets a block name and properties from a position, using the conversion table of the level's game version,
+// which is the same table used to convert the level.
 func (p *Level) Block(pos cube.Pos) (string, map[string]interface{}, error) {
-	c, err := p.Chunk(pos.X()>>4, pos.Z()>>4)
+	version, err := p.gameVersion()
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := p.BlockID(pos)
 	if err != nil {
 		return "", nil, err
 	}
-	return c.Block(pos)
+	metadata, err := p.BlockMeta(pos)
+	if err != nil {
+		return "", nil, err
+	}
+	b, ok := version.block(id, metadata)
+	if !ok {
+		return "", nil, fmt.Errorf("unknown block %v:%v", id, metadata)
+	}
+	return b.name, b.properties, nil
 }
 
 // BlockMeta gets a block's metadata at a position.
@@ -69,141 +109,317 @@ func (p *Level) BlockID(pos cube.Pos) (byte, error) {
 	return c.BlockID(pos)
 }
 
+// SetBlockID sets the block ID at a position. If the chunk of the position has no chunk file, an empty chunk is
+// created for it. The change is written to disk by Save.
+func (p *Level) SetBlockID(pos cube.Pos, id byte) error {
+	c, err := p.writableChunk(pos.X()>>4, pos.Z()>>4)
+	if err != nil {
+		return err
+	}
+	old, err := c.BlockID(pos)
+	if err != nil {
+		return err
+	}
+	if err := c.SetBlockID(pos, id); err != nil {
+		return err
+	}
+	p.updateDetectedVersion(old, id)
+	return nil
+}
+
+// SetBlockMeta sets the block metadata at a position. If the chunk of the position has no chunk file, an empty chunk
+// is created for it. The change is written to disk by Save.
+func (p *Level) SetBlockMeta(pos cube.Pos, meta byte) error {
+	c, err := p.writableChunk(pos.X()>>4, pos.Z()>>4)
+	if err != nil {
+		return err
+	}
+	return c.SetBlockMeta(pos, meta)
+}
+
+// writableChunk returns the chunk at a chunk position like Chunk, but returns a new empty chunk if the chunk has no
+// chunk file, so that blocks can be placed in it.
+func (p *Level) writableChunk(x, z int) (*Chunk, error) {
+	c, err := p.Chunk(x, z)
+	if os.IsNotExist(err) {
+		c = NewEmptyChunk()
+		p.chunkCache[getIndex(x, z)] = c
+		return c, nil
+	}
+	return c, err
+}
+
 // Chunk gets a PMF chunk by its X and Z and returns a PMFChunk.
 func (p *Level) Chunk(x, z int) (*Chunk, error) {
+	if x < 0 || z < 0 || x >= int(p.Width) || z >= int(p.Width) {
+		return nil, fmt.Errorf("chunk %v, %v is outside of the world", x, z)
+	}
+
 	chunkIndex := getIndex(x, z)
 	if c, ok := p.chunkCache[chunkIndex]; ok {
 		return c, nil
 	}
 
-	b, err := os.ReadFile(path.Join(p.worldPath, chunkFilePath(x, z)))
-	if err != nil {
-		return nil, err
+	b, err := readFileLimited(path.Join(p.worldPath, chunkFilePath(x, z)), maxChunkFileSize)
+	if (os.IsNotExist(err) || (err == nil && len(b) == 0)) && p.Generator != nil {
+		c := NewEmptyChunk()
+		p.Generator.GenerateChunk(x, z, c)
+		p.chunkCache[chunkIndex] = c
+		p.locationMappings[chunkIndex] = c.mask()
+		return c, nil
 	}
-	r, err := gzip.NewReader(bytes.NewReader(b))
 	if err != nil {
 		return nil, err
 	}
-	result, err := ioutil.ReadAll(r)
+	c, err := decodeChunk(b, p.locationMappings[chunkIndex], p.Height)
 	if err != nil {
-		return nil, err
-	}
-	buf := bytes.NewBuffer(result)
-
-	info := p.locationMappings[chunkIndex]
-
-	subChunks := make(map[uint8][]byte)
-	for y := uint8(0); y < p.Height; y++ {
-		t := uint16(1 << y)
-
-		if (info & t) == t {
-			subChunks[y] = buf.Next(8192)
-		}
+		return nil, fmt.Errorf("error decoding chunk %v, %v: %w", x, z, err)
 	}
-
-	c := &Chunk{subChunks: subChunks}
 	p.chunkCache[chunkIndex] = c
 
 	return c, nil
 }
 
+// Warnings returns the problems found while decoding the level that did not stop it from being decoded, such as
+// several tiles at the same position in the tiles.yml file.
+func (p *Level) Warnings() []string {
+	return p.warnings
+}
+
+// Entities returns all entities stored in the entities.yml file of the level.
+func (p *Level) Entities() []Entity {
+	return p.entities
+}
+
 // Close closes the PMF level.
 func (p *Level) Close() {
 	p.chunkCache = nil
 	p.locationMappings = nil
 }
 
-// NewLevel creates a new PMF level from a path.
-func NewLevel(folderPath, levelName string, seed uint32, width, height byte, spawn mgl32.Vec3) (*Level, error) {
-	f, err := os.Create(path.Join(folderPath, "level.pmf"))
-	if err != nil {
-		return nil, err
-	}
-
-	buf := &bytes.Buffer{}
-	buf.Write(make([]byte, 5))    // Header.
-	buf.WriteByte(currentVersion) // Version (0x00).
-
-	writeString(buf, levelName)
-	writeUint32(buf, seed)
-	writeUint32(buf, 0) // Time.
-
-	writeFloat32(buf, spawn.X())
-	writeFloat32(buf, spawn.Y())
-	writeFloat32(buf, spawn.Z())
-
-	buf.WriteByte(width)  // Width.
-	buf.WriteByte(height) // Height.
-
-	writeUint16(buf, 0) // Extra data length.
-
-	count := int(math.Pow(float64(width), 2))
-	locationMappings := make(map[int]uint16, count)
-	for index := 0; index < count; index++ {
-		writeUint16(buf, 0) // Location mapping.
+// NewLevel creates a new PMF world in a folder. All width x width chunks of the world are generated by the generator
+// passed, or left empty if it is nil, and written to disk together with the level.pmf, tiles.yml, entities.yml and
+// bupdates.yml files, so that the world can be loaded by legacy servers right away.
+func NewLevel(folderPath, levelName string, seed uint32, width, height byte, spawn mgl32.Vec3, generator Generator) (*Level, error) {
+	if width > maxWidth {
+		return nil, fmt.Errorf("width %v exceeds the maximum of %v", width, maxWidth)
 	}
-
-	_, err = f.Write(buf.Bytes())
-	if err != nil {
-		return nil, err
+	if height > maxHeight {
+		return nil, fmt.Errorf("height %v exceeds the maximum of %v", height, maxHeight)
 	}
-	err = f.Close()
-	if err != nil {
+	if err := os.MkdirAll(path.Join(folderPath, "chunks"), 0755); err != nil {
 		return nil, err
 	}
 
-	return &Level{
+	p := &Level{
 		Version:          currentVersion,
 		Name:             levelName,
 		Seed:             seed,
 		Spawn:            spawn,
 		Width:            width,
 		Height:           height,
+		Generator:        generator,
 		chunkCache:       make(map[int]*Chunk),
-		locationMappings: locationMappings,
+		locationMappings: make(map[int]uint16, int(width)*int(width)),
 		worldPath:        folderPath,
-	}, nil
+		tiles:            make(map[cube.Pos]Tile),
+	}
+	for x := 0; x < int(width); x++ {
+		for z := 0; z < int(width); z++ {
+			c := NewEmptyChunk()
+			if generator != nil {
+				generator.GenerateChunk(x, z, c)
+			}
+			p.chunkCache[getIndex(x, z)] = c
+		}
+	}
+	if err := p.Save(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Save writes the level to its folder: the level.pmf file, the chunks that were created or changed, the tiles.yml and
+// entities.yml files, and an empty bupdates.yml file if the world does not have one yet. Chunks that were only read
+// are left alone, as are their location mappings.
+func (p *Level) Save() error {
+	for index, c := range p.chunkCache {
+		if !c.modified {
+			continue
+		}
+		if err := p.saveChunk(index&0xf, index>>4, c); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(path.Join(p.worldPath, "level.pmf"), p.encodeLevel(), 0644); err != nil {
+		return err
+	}
+	if err := p.SaveTiles(); err != nil {
+		return err
+	}
+	if err := p.SaveEntities(); err != nil {
+		return err
+	}
+
+	// Block updates scheduled by legacy servers are not read, so an existing file is left alone.
+	f, err := os.OpenFile(path.Join(p.worldPath, "bupdates.yml"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString("--- []\n...\n"); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// saveChunk writes a chunk to its chunk file and updates the location mapping of the chunk to the sub chunks in it.
+func (p *Level) saveChunk(x, z int, c *Chunk) error {
+	b, mask, err := c.encode(p.Height)
+	if err != nil {
+		return fmt.Errorf("error encoding chunk %v, %v: %w", x, z, err)
+	}
+	if err := os.MkdirAll(path.Join(p.worldPath, "chunks"), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path.Join(p.worldPath, chunkFilePath(x, z)), b, 0644); err != nil {
+		return err
+	}
+	p.locationMappings[getIndex(x, z)] = mask
+	c.modified = false
+	return nil
+}
+
+// encodeLevel encodes the header of the level to the contents of a level.pmf file.
+func (p *Level) encodeLevel() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("PMF")   // Magic.
+	buf.WriteByte(0x01)      // PMF file version.
+	buf.WriteByte(0x00)      // PMF file type (level).
+	buf.WriteByte(p.Version) // Version.
+
+	writeString(buf, p.Name)
+	writeUint32(buf, p.Seed)
+	writeUint32(buf, p.Time)
+
+	writeFloat32(buf, p.Spawn.X())
+	writeFloat32(buf, p.Spawn.Y())
+	writeFloat32(buf, p.Spawn.Z())
+
+	buf.WriteByte(p.Width)  // Width.
+	buf.WriteByte(p.Height) // Height.
+
+	writeUint16(buf, 0) // Extra data length.
+
+	// The location mappings are stored by Z and then X. PocketMine Alpha indexed them with getIndex, which only
+	// matches this order for worlds 16 chunks wide, the only width it created.
+	for z := 0; z < int(p.Width); z++ {
+		for x := 0; x < int(p.Width); x++ {
+			writeUint16(buf, p.locationMappings[getIndex(x, z)]) // Location mapping.
+		}
+	}
+	return buf.Bytes()
 }
 
 // DecodeLevel decodes a level.pmf file from its path and returns a Level.
 func DecodeLevel(folderPath string) (*Level, error) {
-	b, err := os.ReadFile(path.Join(folderPath, "level.pmf"))
+	b, err := readFileLimited(path.Join(folderPath, "level.pmf"), maxLevelFileSize)
 	if err != nil {
 		return nil, err
 	}
+	p, err := decodeLevel(b)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding level.pmf: %w", err)
+	}
+	p.worldPath = folderPath
 
-	buf := bytes.NewBuffer(b)
-
-	buf.Next(5) // Header.
+	b, err = readFileLimited(path.Join(folderPath, "tiles.yml"), maxYAMLFileSize)
+	if err != nil {
+		return nil, err
+	}
+	p.tiles, p.warnings, err = decodeTiles(b)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding tiles.yml: %w", err)
+	}
 
-	version, _ := buf.ReadByte()
-	name := readString(buf)
-	seed := readUint32(buf)
-	time := readUint32(buf)
-	spawnX := readFloat32(buf)
-	spawnY := readFloat32(buf)
-	spawnZ := readFloat32(buf)
-	width, _ := buf.ReadByte()
-	height, _ := buf.ReadByte()
+	b, err = readFileLimited(path.Join(folderPath, "entities.yml"), maxYAMLFileSize)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	p.entities, err = decodeEntities(b)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding entities.yml: %w", err)
+	}
+	return p, nil
+}
 
-	buf.Next(int(readUint16(buf))) // Read useless extra data.
+// decodeLevel decodes the header of a level.pmf file. The level returned has no world path, tiles or entities set.
+func decodeLevel(b []byte) (*Level, error) {
+	buf := bytes.NewBuffer(b)
 
-	locationMappings := make(map[int]uint16)
-	count := int(math.Pow(float64(width), 2))
-	for index := 0; index < count; index++ {
-		locationMappings[index] = readUint16(buf)
+	header, err := next(buf, 5)
+	if err != nil {
+		return nil, err
+	}
+	// Levels created by earlier versions of this package have a header of zeroes instead of the PMF magic. These are
+	// still accepted, and get the magic the next time they are saved.
+	if !bytes.Equal(header[:3], []byte("PMF")) && !bytes.Equal(header, make([]byte, 5)) {
+		return nil, fmt.Errorf("invalid magic %q", header[:3])
 	}
 
-	b, err = os.ReadFile(path.Join(folderPath, "tiles.yml"))
+	version, err := buf.ReadByte()
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	name, err := readString(buf)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := readUint32(buf)
+	if err != nil {
+		return nil, err
+	}
+	time, err := readUint32(buf)
 	if err != nil {
 		return nil, err
 	}
+	var spawn mgl32.Vec3
+	for i := range spawn {
+		if spawn[i], err = readFloat32(buf); err != nil {
+			return nil, err
+		}
+	}
+	dimensions, err := next(buf, 2)
+	if err != nil {
+		return nil, err
+	}
+	width, height := dimensions[0], dimensions[1]
+	if width > maxWidth {
+		return nil, fmt.Errorf("width %v exceeds the maximum of %v", width, maxWidth)
+	}
+	if height > maxHeight {
+		return nil, fmt.Errorf("height %v exceeds the maximum of %v", height, maxHeight)
+	}
 
-	var tiles []map[string]interface{}
-	err = yaml.Unmarshal(b, &tiles)
+	extraLength, err := readUint16(buf)
 	if err != nil {
 		return nil, err
 	}
+	if _, err = next(buf, int(extraLength)); err != nil { // Read useless extra data.
+		return nil, err
+	}
+
+	locationMappings := make(map[int]uint16)
+	for z := 0; z < int(width); z++ {
+		for x := 0; x < int(width); x++ {
+			if locationMappings[getIndex(x, z)], err = readUint16(buf); err != nil {
+				return nil, err
+			}
+		}
+	}
 
 	return &Level{
 		Version:          version,
@@ -212,10 +428,8 @@ func DecodeLevel(folderPath string) (*Level, error) {
 		Time:             time,
 		Width:            width,
 		Height:           height,
-		worldPath:        folderPath,
 		locationMappings: locationMappings,
-		tiles:            tiles,
-		Spawn:            mgl32.Vec3{spawnX, spawnY, spawnZ},
+		Spawn:            spawn,
 		chunkCache:       make(map[int]*Chunk),
-	}, err
+	}, nil
 }
@@ -0,0 +1,164 @@
+package pmf
+
+import (
+	"bytes"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/go-gl/mathgl/mgl32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDecodeLevelHeader tests that level.pmf files are accepted with the PMF magic or the header of zeroes written by
+// earlier versions of this package, and rejected with any other header.
+func TestDecodeLevelHeader(t *testing.T) {
+	level := &Level{Name: "test", Seed: 5, Spawn: mgl32.Vec3{1, 2, 3}, Width: 2, Height: 8, locationMappings: map[int]uint16{}}
+	encoded := level.encodeLevel()
+
+	tests := []struct {
+		name   string
+		header string
+		ok     bool
+	}{
+		{name: "magic", header: "PMF\x01\x00", ok: true},
+		{name: "zeroes", header: "\x00\x00\x00\x00\x00", ok: true},
+		{name: "invalid magic", header: "PMX\x01\x00"},
+		{name: "partial zeroes", header: "\x00\x00\x00\x01\x00"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := append([]byte(test.header), encoded[5:]...)
+			p, err := decodeLevel(b)
+			if !test.ok {
+				if err == nil {
+					t.Fatalf("expected an error for header %q", test.header)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Name != level.Name || p.Seed != level.Seed || p.Spawn != level.Spawn || p.Width != level.Width {
+				t.Fatalf("decoded %+v, expected %+v", p, level)
+			}
+		})
+	}
+}
+
+// TestExampleSpareBytes tests that PocketMine Alpha left the 8 bytes after the block IDs and metadata of every column
+// of the example world zero, even in the open air, so they hold no light or any other data.
+func TestExampleSpareBytes(t *testing.T) {
+	p, err := DecodeLevel(filepath.Join("..", "example"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < int(p.Width); x++ {
+		for z := 0; z < int(p.Width); z++ {
+			c, err := p.Chunk(x, z)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for y, sub := range c.subChunks {
+				for off := 24; off < subChunkSize; off += 32 {
+					if spare := sub[off : off+8]; !bytes.Equal(spare, make([]byte, 8)) {
+						t.Fatalf("column %v of sub chunk %v of chunk %v, %v holds % x", off>>5, y, x, z, spare)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestNewLevel tests that a level created by NewLevel can be decoded again, with the location mappings and chunks
+// that were generated and all files legacy servers expect.
+func TestNewLevel(t *testing.T) {
+	dir := t.TempDir()
+	gen := FlatGenerator{Layers: []Layer{{ID: 7, Count: 1}, {ID: 3, Count: 17}, {ID: 2, Count: 1}}}
+	if _, err := NewLevel(dir, "new", 42, 3, 8, mgl32.Vec3{24, 20, 24}, gen); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"level.pmf", "tiles.yml", "entities.yml", "bupdates.yml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%v was not written: %v", name, err)
+		}
+	}
+
+	p, err := DecodeLevel(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "new" || p.Seed != 42 || p.Width != 3 || p.Height != 8 || p.Spawn != (mgl32.Vec3{24, 20, 24}) {
+		t.Errorf("decoded header %v, %v, %v, %v, %v", p.Name, p.Seed, p.Width, p.Height, p.Spawn)
+	}
+	for x := 0; x < 3; x++ {
+		for z := 0; z < 3; z++ {
+			// The layers fill the first two sub chunks, so only those are written.
+			if m := p.locationMappings[getIndex(x, z)]; m != 0b11 {
+				t.Errorf("chunk %v, %v has location mapping %b, expected 11", x, z, m)
+			}
+			for y, want := range map[int]byte{0: 7, 1: 3, 17: 3, 18: 2, 19: 0} {
+				pos := cube.Pos{x<<4 + 5, y, z<<4 + 9}
+				if id, err := p.BlockID(pos); err != nil {
+					t.Fatal(err)
+				} else if id != want {
+					t.Errorf("got block %v at %v, expected %v", id, pos, want)
+				}
+			}
+		}
+	}
+	if len(p.Tiles()) != 0 || len(p.Entities()) != 0 {
+		t.Errorf("got %v tiles and %v entities in a new level", len(p.Tiles()), len(p.Entities()))
+	}
+}
+
+// TestSaveModified tests that Save only writes the chunks that were changed or created, and leaves the chunk files
+// and location mappings of chunks that were only read alone.
+func TestSaveModified(t *testing.T) {
+	dir := newTestLevel(t, nil).worldPath
+	// Replace a chunk file with a marker after loading it, so that it is noticed if Save writes it.
+	p, err := DecodeLevel(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Chunk(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	read := filepath.Join(dir, chunkFilePath(0, 1))
+	if err := os.WriteFile(read, []byte("marker"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(filepath.Join(dir, chunkFilePath(1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.SetBlockID(cube.Pos{20, 40, 20}, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(read); string(b) != "marker" {
+		t.Error("a chunk that was only read was written")
+	}
+	if after, _ := os.ReadFile(filepath.Join(dir, chunkFilePath(1, 1))); bytes.Equal(before, after) {
+		t.Error("the changed chunk was not written")
+	}
+	if m := p.locationMappings[getIndex(1, 1)]; m != 0b101 {
+		t.Errorf("got location mapping %b for the changed chunk, expected 101", m)
+	}
+
+	p, err = DecodeLevel(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := p.BlockID(cube.Pos{20, 40, 20}); id != 4 {
+		t.Errorf("got block %v after saving, expected cobblestone", id)
+	}
+	if id, _ := p.BlockID(cube.Pos{20, 3, 20}); id != 1 {
+		t.Errorf("got block %v below the changed block, expected stone", id)
+	}
+}
@@ -0,0 +1,77 @@
+package pmf
+
+import (
+	"fmt"
+	"github.com/df-mc/dragonfly/server/block/cube"
+)
+
+// region holds the block IDs and metadata of a box of a level, read into memory so that they can be looked up
+// quickly by renderers and exporters.
+type region struct {
+	box                 Box
+	sizeX, sizeY, sizeZ int
+	ids, metas          []byte
+}
+
+// readRegion reads the blocks of the level inside a box through Chunk.BlockID and Chunk.BlockMeta. If the box passed
+// is nil, the whole level is read. The box is cut off at the edges of the level, and chunks without a chunk file are
+// read as air.
+func (p *Level) readRegion(b *Box) (*region, error) {
+	box := p.Bounds()
+	if b != nil {
+		box = box.intersect(*b)
+	}
+	if box.Empty() {
+		return nil, fmt.Errorf("box %v is outside of the level", b)
+	}
+	r := &region{
+		box:   box,
+		sizeX: box.Max.X() - box.Min.X() + 1,
+		sizeY: box.Max.Y() - box.Min.Y() + 1,
+		sizeZ: box.Max.Z() - box.Min.Z() + 1,
+	}
+	r.ids = make([]byte, r.sizeX*r.sizeY*r.sizeZ)
+	r.metas = make([]byte, len(r.ids))
+
+	for chunkX := box.Min.X() >> 4; chunkX <= box.Max.X()>>4; chunkX++ {
+		for chunkZ := box.Min.Z() >> 4; chunkZ <= box.Max.Z()>>4; chunkZ++ {
+			c, err := p.chunkOrEmpty(chunkX, chunkZ)
+			if err != nil {
+				return nil, err
+			}
+			for x := maxInt(chunkX<<4, box.Min.X()); x <= minInt(chunkX<<4+15, box.Max.X()); x++ {
+				for z := maxInt(chunkZ<<4, box.Min.Z()); z <= minInt(chunkZ<<4+15, box.Max.Z()); z++ {
+					for y := box.Min.Y(); y <= box.Max.Y(); y++ {
+						pos := cube.Pos{x, y, z}
+						id, err := c.BlockID(pos)
+						if err != nil {
+							return nil, err
+						}
+						meta, err := c.BlockMeta(pos)
+						if err != nil {
+							return nil, err
+						}
+						i := r.index(x-box.Min.X(), y-box.Min.Y(), z-box.Min.Z())
+						r.ids[i], r.metas[i] = id, meta
+					}
+				}
+			}
+		}
+	}
+	return r, nil
+}
+
+// index returns the index of the block at a position relative to the minimum of the region.
+func (r *region) index(x, y, z int) int {
+	return (x*r.sizeZ+z)*r.sizeY + y
+}
+
+// block returns the ID and metadata of the block at a position relative to the minimum of the region. Positions
+// outside of the region are air.
+func (r *region) block(x, y, z int) (id, meta byte) {
+	if x < 0 || y < 0 || z < 0 || x >= r.sizeX || y >= r.sizeY || z >= r.sizeZ {
+		return 0, 0
+	}
+	i := r.index(x, y, z)
+	return r.ids[i], r.metas[i]
+}
@@ -0,0 +1,188 @@
+package pmf
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+// RenderOptions holds options that change the way a level is rendered by Level.RenderIsometric.
+type RenderOptions struct {
+	// Box, if not nil, is the box of blocks that is rendered. By default, the whole level is rendered.
+	Box *Box
+	// Rotation is the amount of quarter turns the level is rotated clockwise around the Y axis before rendering,
+	// giving the four view angles 0 to 3. By default, the level is viewed with X going to the bottom right and Z
+	// going to the bottom left of the image.
+	Rotation int
+	// Scale is the size of a block in the image: the top face of every block is 4*Scale pixels wide and 2*Scale
+	// pixels high. If it is 0 or lower, a scale of 2 is used.
+	Scale int
+}
+
+const (
+	// topShade, leftShade and rightShade are the brightness of the three faces of a block that are visible in an
+	// isometric image.
+	topShade   = 1.0
+	leftShade  = 0.8
+	rightShade = 0.62
+
+	// mapHighShade, mapFlatShade and mapLowShade are the brightness of blocks on a map that are higher than, as high
+	// as or lower than the block north of them.
+	mapHighShade = 1.0
+	mapFlatShade = 0.86
+	mapLowShade  = 0.71
+)
+
+// RenderIsometric renders the level, or the box of it in the options passed, as an isometric image with flat shaded
+// block faces coloured by BlockColour. Faces hidden by neighbouring blocks are left out.
+func (p *Level) RenderIsometric(opts RenderOptions) (*image.RGBA, error) {
+	r, err := p.readRegion(opts.Box)
+	if err != nil {
+		return nil, err
+	}
+	h := opts.Scale
+	if h <= 0 {
+		h = 2
+	}
+	rotation := ((opts.Rotation % 4) + 4) % 4
+	sizeU, sizeV := r.sizeX, r.sizeZ
+	if rotation%2 == 1 {
+		sizeU, sizeV = r.sizeZ, r.sizeX
+	}
+	// blockAt returns the block at a position in the rotated view.
+	blockAt := func(u, y, v int) (byte, byte) {
+		switch rotation {
+		case 1:
+			return r.block(v, y, r.sizeZ-1-u)
+		case 2:
+			return r.block(r.sizeX-1-u, y, r.sizeZ-1-v)
+		case 3:
+			return r.block(r.sizeX-1-v, y, u)
+		}
+		return r.block(u, y, v)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, (sizeU+sizeV)*2*h+1, (sizeU+sizeV)*h+r.sizeY*2*h+1))
+	// Blocks are drawn from the bottom up and from the back to the front, so that nearer blocks are drawn over
+	// blocks behind them.
+	for y := 0; y < r.sizeY; y++ {
+		for u := 0; u < sizeU; u++ {
+			for v := 0; v < sizeV; v++ {
+				id, meta := blockAt(u, y, v)
+				if id == 0 {
+					continue
+				}
+				c, _ := BlockColour(id, meta)
+				sx, sy := (u-v)*2*h+sizeV*2*h, (u+v)*h+(r.sizeY-1-y)*2*h
+
+				if above, _ := blockAt(u, y+1, v); !faceHidden(id, above) {
+					drawTopFace(img, sx, sy, h, shade(c, topShade))
+				}
+				if front, _ := blockAt(u, y, v+1); !faceHidden(id, front) {
+					drawSideFace(img, sx, sy, h, -1, shade(c, leftShade))
+				}
+				if front, _ := blockAt(u+1, y, v); !faceHidden(id, front) {
+					drawSideFace(img, sx, sy, h, 1, shade(c, rightShade))
+				}
+			}
+		}
+	}
+	return img, nil
+}
+
+// WriteIsometricPNG renders the level using RenderIsometric and writes the image to the writer passed as PNG.
+func (p *Level) WriteIsometricPNG(w io.Writer, opts RenderOptions) error {
+	img, err := p.RenderIsometric(opts)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// RenderMap renders a top-down map of the level, or the box of it passed, with one pixel per block. Every pixel has the
+// colour of the highest block in its column inside the box, so lowering the top of the box slices the level at that
+// height. Like maps in Minecraft, blocks are drawn brighter or darker if they are higher or lower than the block north
+// of them. The top left of the image is the minimum X and Z of the box.
+func (p *Level) RenderMap(box *Box) (*image.RGBA, error) {
+	r, err := p.readRegion(box)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, r.sizeX, r.sizeZ))
+	heights := make([]int, r.sizeX)
+	for z := 0; z < r.sizeZ; z++ {
+		for x := 0; x < r.sizeX; x++ {
+			y := r.sizeY - 1
+			for ; y >= 0; y-- {
+				if id, _ := r.block(x, y, z); id != 0 {
+					break
+				}
+			}
+			if y < 0 {
+				heights[x] = -1
+				continue
+			}
+			id, meta := r.block(x, y, z)
+			c, _ := BlockColour(id, meta)
+			brightness := mapFlatShade
+			if z > 0 && heights[x] >= 0 {
+				if y > heights[x] {
+					brightness = mapHighShade
+				} else if y < heights[x] {
+					brightness = mapLowShade
+				}
+			}
+			img.SetRGBA(x, z, shade(c, brightness))
+			heights[x] = y
+		}
+	}
+	return img, nil
+}
+
+// WriteMapPNG renders a map of the level using RenderMap and writes the image to the writer passed as PNG.
+func (p *Level) WriteMapPNG(w io.Writer, box *Box) error {
+	img, err := p.RenderMap(box)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// drawTopFace draws the top face of a block whose top corner is at sx, sy.
+func drawTopFace(img *image.RGBA, sx, sy, h int, c color.RGBA) {
+	for d := -2 * h; d < 2*h; d++ {
+		a := math.Abs(float64(d) + 0.5)
+		y0 := sy + int(math.Round(a/2))
+		y1 := sy + 2*h - int(math.Round(a/2))
+		for y := y0; y < y1; y++ {
+			img.SetRGBA(sx+d, y, c)
+		}
+	}
+}
+
+// drawSideFace draws the left (side -1) or right (side 1) face of a block whose top corner is at sx, sy.
+func drawSideFace(img *image.RGBA, sx, sy, h, side int, c color.RGBA) {
+	for i := 0; i < 2*h; i++ {
+		d := i
+		if side < 0 {
+			d = -i - 1
+		}
+		a := math.Abs(float64(d) + 0.5)
+		y0 := sy + 2*h - int(math.Round(a/2))
+		for y := y0; y < y0+2*h; y++ {
+			img.SetRGBA(sx+d, y, c)
+		}
+	}
+}
+
+// shade returns a colour darkened by the brightness factor passed.
+func shade(c color.RGBA, brightness float64) color.RGBA {
+	return color.RGBA{
+		R: uint8(float64(c.R) * brightness),
+		G: uint8(float64(c.G) * brightness),
+		B: uint8(float64(c.B) * brightness),
+		A: c.A,
+	}
+}
@@ -0,0 +1,151 @@
+package pmf
+
+import (
+	"bytes"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// TestRenderMap tests that maps show the highest block in every column of the box rendered, shaded by the height of
+// the block north of it, and that WriteMapPNG writes the same map as PNG.
+func TestRenderMap(t *testing.T) {
+	p := newTestLevel(t, map[cube.Pos][2]byte{{5, 6, 5}: {41, 0}, {20, 4, 9}: {35, 14}})
+	stone, _ := BlockColour(1, 0)
+	gold, _ := BlockColour(41, 0)
+	wool, _ := BlockColour(35, 14)
+	tests := []struct {
+		name   string
+		box    *Box
+		bounds image.Rectangle
+		// pixels holds the colours expected at pixels of the map.
+		pixels map[image.Point]color.RGBA
+	}{
+		{name: "whole level", bounds: image.Rect(0, 0, 32, 32), pixels: map[image.Point]color.RGBA{
+			{5, 5}:   shade(gold, mapHighShade),
+			{5, 6}:   shade(stone, mapLowShade),
+			{10, 10}: shade(stone, mapFlatShade),
+			{10, 0}:  shade(stone, mapFlatShade),
+			{20, 9}:  shade(wool, mapHighShade),
+		}},
+		{name: "slice", box: &Box{Max: cube.Pos{31, 4, 31}}, bounds: image.Rect(0, 0, 32, 32), pixels: map[image.Point]color.RGBA{
+			{5, 5}:  shade(stone, mapFlatShade),
+			{5, 6}:  shade(stone, mapFlatShade),
+			{20, 9}: shade(wool, mapHighShade),
+		}},
+		{name: "below the blocks", box: &Box{Min: cube.Pos{0, 10, 0}, Max: cube.Pos{31, 127, 31}}, bounds: image.Rect(0, 0, 32, 32), pixels: map[image.Point]color.RGBA{
+			{5, 5}:   {},
+			{10, 10}: {},
+		}},
+		{name: "crop", box: &Box{Min: cube.Pos{4, 0, 4}, Max: cube.Pos{7, 127, 9}}, bounds: image.Rect(0, 0, 4, 6), pixels: map[image.Point]color.RGBA{
+			{1, 1}: shade(gold, mapHighShade),
+			{1, 2}: shade(stone, mapLowShade),
+			{0, 0}: shade(stone, mapFlatShade),
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img, err := p.RenderMap(test.box)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img.Bounds() != test.bounds {
+				t.Fatalf("got bounds %v, expected %v", img.Bounds(), test.bounds)
+			}
+			for pt, want := range test.pixels {
+				if c := img.RGBAAt(pt.X, pt.Y); c != want {
+					t.Errorf("got colour %v at %v, expected %v", c, pt, want)
+				}
+			}
+
+			buf := &bytes.Buffer{}
+			if err := p.WriteMapPNG(buf, test.box); err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := png.Decode(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for pt := range test.pixels {
+				if c := color.RGBAModel.Convert(decoded.At(pt.X, pt.Y)); c != img.RGBAAt(pt.X, pt.Y) {
+					t.Errorf("PNG has a different colour at %v", pt)
+				}
+			}
+		})
+	}
+
+	if _, err := p.RenderMap(&Box{Min: cube.Pos{40, 0, 40}, Max: cube.Pos{50, 10, 50}}); err == nil {
+		t.Error("rendering a box outside of the level did not fail")
+	}
+}
+
+// TestRenderIsometric tests the size of isometric images of small boxes and the colours of the three faces of a block,
+// and that WriteIsometricPNG writes the same image as PNG.
+func TestRenderIsometric(t *testing.T) {
+	p := newTestLevel(t, map[cube.Pos][2]byte{{6, 3, 5}: {41, 0}})
+	stone, _ := BlockColour(1, 0)
+	gold, _ := BlockColour(41, 0)
+	tests := []struct {
+		name   string
+		opts   RenderOptions
+		bounds image.Rectangle
+		// pixels holds the colours expected at pixels of the image.
+		pixels map[image.Point]color.RGBA
+	}{
+		{name: "single block", opts: RenderOptions{Box: &Box{Min: cube.Pos{5, 3, 5}, Max: cube.Pos{5, 3, 5}}}, bounds: image.Rect(0, 0, 9, 9), pixels: map[image.Point]color.RGBA{
+			{4, 1}: shade(stone, topShade),
+			{3, 5}: shade(stone, leftShade),
+			{4, 5}: shade(stone, rightShade),
+			{0, 0}: {},
+			{8, 8}: {},
+		}},
+		{name: "scale", opts: RenderOptions{Box: &Box{Min: cube.Pos{5, 3, 5}, Max: cube.Pos{5, 3, 5}}, Scale: 4}, bounds: image.Rect(0, 0, 17, 17), pixels: map[image.Point]color.RGBA{
+			{8, 2}:  shade(stone, topShade),
+			{7, 10}: shade(stone, leftShade),
+			{8, 10}: shade(stone, rightShade),
+		}},
+		// Along X, the gold block is drawn in front on the right, and the right face of the stone block is hidden.
+		{name: "two blocks", opts: RenderOptions{Box: &Box{Min: cube.Pos{5, 3, 5}, Max: cube.Pos{6, 3, 5}}}, bounds: image.Rect(0, 0, 13, 11), pixels: map[image.Point]color.RGBA{
+			{4, 1}: shade(stone, topShade),
+			{8, 3}: shade(gold, topShade),
+			{7, 7}: shade(gold, leftShade),
+			{8, 7}: shade(gold, rightShade),
+		}},
+		// Turned twice, the gold block is at the back on the left instead.
+		{name: "rotated", opts: RenderOptions{Box: &Box{Min: cube.Pos{5, 3, 5}, Max: cube.Pos{6, 3, 5}}, Rotation: 2}, bounds: image.Rect(0, 0, 13, 11), pixels: map[image.Point]color.RGBA{
+			{4, 1}: shade(gold, topShade),
+			{8, 3}: shade(stone, topShade),
+			{8, 7}: shade(stone, rightShade),
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img, err := p.RenderIsometric(test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img.Bounds() != test.bounds {
+				t.Fatalf("got bounds %v, expected %v", img.Bounds(), test.bounds)
+			}
+			for pt, want := range test.pixels {
+				if c := img.RGBAAt(pt.X, pt.Y); c != want {
+					t.Errorf("got colour %v at %v, expected %v", c, pt, want)
+				}
+			}
+
+			buf := &bytes.Buffer{}
+			if err := p.WriteIsometricPNG(buf, test.opts); err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := png.Decode(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Bounds() != test.bounds {
+				t.Errorf("got PNG bounds %v, expected %v", decoded.Bounds(), test.bounds)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package pmf
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// windows1252 holds the characters of the bytes 0x80 to 0x9f in Windows-1252, the code page that legacy clients on
+// Windows wrote sign text in. All other bytes that are not part of valid UTF-8 are the same in Latin-1, which
+// includes the § of formatting codes. Bytes without a character are mapped to 0 and removed.
+var windows1252 = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+// fontSubstitutions holds the typographic punctuation that ends up on legacy signs through Windows-1252 bytes or
+// desktop editing tools, and the ASCII characters that replace it. The bitmap font of MCPE 0.x clients was made for
+// ASCII text and did not show these characters as modern fonts do, so they are replaced with the ASCII characters
+// they stand for, matching the plain text of the signs around them. Letters such as é and € are kept, as they have
+// no ASCII counterpart.
+var fontSubstitutions = map[rune]string{
+	'‚': ",",
+	'„': "\"",
+	'…': "...",
+	'‹': "<",
+	'›': ">",
+	'‘': "'",
+	'’': "'",
+	'“': "\"",
+	'”': "\"",
+	'•': "*",
+	'–': "-",
+	'—': "-",
+	'˜': "~",
+	'ˆ': "^",
+}
+
+// formattingCodes holds the characters that may follow a § to form a legacy formatting code: the colours 0 to f,
+// and obfuscated, bold, strikethrough, underlined, italic and reset.
+const formattingCodes = "0123456789abcdefklmnor"
+
+// NormaliseSignText normalises the four lines of text of a legacy sign. Bytes that are not valid UTF-8 are decoded as
+// Windows-1252, control characters are removed, non-breaking spaces and tabs become normal spaces, typographic quotes,
+// dashes and ellipses become their ASCII look-alikes, and the spaces that legacy clients used to pad lines with are
+// trimmed from the end of every line. Legacy § formatting codes are kept, unless stripFormatting is true, in which
+// case they are removed.
+func NormaliseSignText(text [4]string, stripFormatting bool) [4]string {
+	for i, line := range text {
+		text[i] = normaliseSignLine(line, stripFormatting)
+	}
+	return text
+}
+
+// normaliseSignLine normalises a single line of sign text as described in NormaliseSignText.
+func normaliseSignLine(line string, stripFormatting bool) string {
+	runes := make([]rune, 0, len(line))
+	for len(line) > 0 {
+		r, size := utf8.DecodeRuneInString(line)
+		if r == utf8.RuneError && size == 1 {
+			r = rune(line[0])
+			if r >= 0x80 && r < 0xa0 {
+				r = windows1252[r-0x80]
+			}
+		}
+		line = line[size:]
+
+		switch {
+		case r == '\t' || r == '\u00a0':
+			// A stray 0xa0 byte is decoded as a non-breaking space as well.
+			r = ' '
+		case r == 0 || unicode.IsControl(r):
+			continue
+		}
+		if sub, ok := fontSubstitutions[r]; ok {
+			runes = append(runes, []rune(sub)...)
+			continue
+		}
+		runes = append(runes, r)
+	}
+
+	b := &strings.Builder{}
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '§' && stripFormatting {
+			// Skip the code following the §. A § at the end of the line has no code and is dropped as well.
+			if i+1 < len(runes) && strings.ContainsRune(formattingCodes, unicode.ToLower(runes[i+1])) {
+				i++
+			}
+			continue
+		}
+		b.WriteRune(runes[i])
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// signText returns the text of a sign tile as written to the sign block entity of a converted world: the normalised
+// lines, rewritten by the options if they hold a rewrite function, joined with newlines. Empty lines at the end are
+// left out.
+func signText(t SignTile, opts ConvertOptions) string {
+	lines := NormaliseSignText(t.Text, opts.StripSignFormatting)
+	if opts.RewriteSignText != nil {
+		lines = opts.RewriteSignText(t.Pos, lines)
+	}
+	return strings.TrimRight(strings.Join(lines[:], "\n"), "\n")
+}
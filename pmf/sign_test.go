@@ -0,0 +1,75 @@
+package pmf
+
+import (
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"testing"
+)
+
+// TestNormaliseSignText tests the normalisation of single lines of legacy sign text, with and without formatting
+// codes stripped.
+func TestNormaliseSignText(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		kept     string
+		stripped string
+	}{
+		{name: "plain", line: "Welcome!", kept: "Welcome!", stripped: "Welcome!"},
+		{name: "padding", line: "  Spawn   ", kept: "  Spawn", stripped: "  Spawn"},
+		{name: "tab", line: "a\tb", kept: "a b", stripped: "a b"},
+		{name: "non-breaking space", line: "a\u00a0b\u00a0", kept: "a b", stripped: "a b"},
+		{name: "non-breaking space byte", line: "a\xa0b\xa0", kept: "a b", stripped: "a b"},
+		{name: "control characters", line: "\x00a\x01b\nc\x7f", kept: "abc", stripped: "abc"},
+		{name: "latin-1 byte", line: "caf\xe9", kept: "café", stripped: "café"},
+		{name: "utf-8", line: "café €5", kept: "café €5", stripped: "café €5"},
+		{name: "windows-1252 bytes", line: "\x80\x8a", kept: "€Š", stripped: "€Š"},
+		{name: "undefined windows-1252 byte", line: "a\x81b", kept: "ab", stripped: "ab"},
+		{name: "typographic quotes", line: "\x93Hi\x94 it\x92s", kept: "\"Hi\" it's", stripped: "\"Hi\" it's"},
+		{name: "typographic punctuation", line: "a – b — c…", kept: "a - b - c...", stripped: "a - b - c..."},
+		{name: "colour", line: "§cRed§r text", kept: "§cRed§r text", stripped: "Red text"},
+		{name: "colour byte", line: "\xa7aGreen", kept: "§aGreen", stripped: "Green"},
+		{name: "upper case code", line: "§LBold", kept: "§LBold", stripped: "Bold"},
+		{name: "invalid code", line: "§zz", kept: "§zz", stripped: "zz"},
+		{name: "trailing section sign", line: "end§", kept: "end§", stripped: "end"},
+		{name: "padding after code", line: "Hi§r  ", kept: "Hi§r", stripped: "Hi"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := NormaliseSignText([4]string{test.line}, false)[0]; got != test.kept {
+				t.Errorf("got %q with formatting kept, expected %q", got, test.kept)
+			}
+			if got := NormaliseSignText([4]string{test.line}, true)[0]; got != test.stripped {
+				t.Errorf("got %q with formatting stripped, expected %q", got, test.stripped)
+			}
+		})
+	}
+}
+
+// TestSignText tests the text written to converted signs, with the lines rewritten and empty lines at the end left
+// out.
+func TestSignText(t *testing.T) {
+	tests := []struct {
+		name string
+		text [4]string
+		opts ConvertOptions
+		want string
+	}{
+		{name: "four lines", text: [4]string{"a", "b", "c", "d"}, want: "a\nb\nc\nd"},
+		{name: "empty lines at the end", text: [4]string{"a", "", "c", "  "}, want: "a\n\nc"},
+		{name: "empty", want: ""},
+		{name: "stripped", text: [4]string{"§1Blue"}, opts: ConvertOptions{StripSignFormatting: true}, want: "Blue"},
+		{name: "rewritten", text: [4]string{"play.old.net  "}, opts: ConvertOptions{RewriteSignText: func(pos cube.Pos, lines [4]string) [4]string {
+			if lines[0] == "play.old.net" {
+				lines[0] = "play.new.net"
+			}
+			return lines
+		}}, want: "play.new.net"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := signText(SignTile{Text: test.text}, test.opts); got != test.want {
+				t.Errorf("got %q, expected %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,440 @@
+package pmf
+
+import (
+	"fmt"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"gopkg.in/yaml.v2"
+	"os"
+	"path"
+	"sort"
+)
+
+// Tile is a block entity stored in the tiles.yml file of a PMF world.
+type Tile interface {
+	// ID returns the ID of the tile as it is stored in tiles.yml, such as "Sign".
+	ID() string
+	// Position returns the position of the block the tile belongs to.
+	Position() cube.Pos
+	// EncodeTile encodes the tile to its tiles.yml representation. The ID and position are added separately and
+	// should not be included.
+	EncodeTile() map[string]interface{}
+	// DecodeTile decodes a tile at a position from its tiles.yml representation and returns it.
+	DecodeTile(pos cube.Pos, data map[string]interface{}) (Tile, error)
+}
+
+// tileRegistry holds all registered tiles, indexed by their ID.
+var tileRegistry = map[string]Tile{}
+
+// RegisterTile registers a tile so that tiles with its ID are decoded using it. Tiles with an ID that was not
+// registered are decoded as an UnknownTile.
+func RegisterTile(t Tile) {
+	tileRegistry[t.ID()] = t
+}
+
+func init() {
+	RegisterTile(SignTile{})
+	RegisterTile(ChestTile{})
+	RegisterTile(FurnaceTile{})
+	RegisterTile(NetherReactorTile{})
+}
+
+// Item is an item stack stored in the inventory of a tile, such as a chest.
+type Item struct {
+	// ID is the legacy numeric ID of the item.
+	ID int
+	// Damage is the damage or metadata value of the item.
+	Damage int
+	// Count is the amount of items in the stack.
+	Count int
+	// Slot is the inventory slot the item is in.
+	Slot int
+}
+
+// SignTile is the tile of a sign, holding its four lines of text.
+type SignTile struct {
+	// Pos is the position of the sign.
+	Pos cube.Pos
+	// Text holds the four lines of text on the sign.
+	Text [4]string
+}
+
+// ID returns the ID of the sign tile.
+func (SignTile) ID() string {
+	return "Sign"
+}
+
+// Position returns the position of the sign tile.
+func (s SignTile) Position() cube.Pos {
+	return s.Pos
+}
+
+// EncodeTile encodes the sign tile to its tiles.yml representation.
+func (s SignTile) EncodeTile() map[string]interface{} {
+	return map[string]interface{}{"Text1": s.Text[0], "Text2": s.Text[1], "Text3": s.Text[2], "Text4": s.Text[3]}
+}
+
+// DecodeTile decodes a sign tile from its tiles.yml representation.
+func (SignTile) DecodeTile(pos cube.Pos, data map[string]interface{}) (Tile, error) {
+	s := SignTile{Pos: pos}
+	for i := range s.Text {
+		key := fmt.Sprintf("Text%v", i+1)
+		text, ok := yamlString(data[key])
+		if !ok {
+			return nil, fmt.Errorf("invalid %v %v", key, data[key])
+		}
+		s.Text[i] = text
+	}
+	return s, nil
+}
+
+// ChestTile is the tile of a chest, holding its contents and the chest it is paired with, if any.
+type ChestTile struct {
+	// Pos is the position of the chest.
+	Pos cube.Pos
+	// Items holds the items in the chest.
+	Items []Item
+	// Paired specifies if the chest is paired with another chest to form a double chest.
+	Paired bool
+	// PairX and PairZ are the coordinates of the chest this chest is paired with.
+	PairX, PairZ int
+}
+
+// ID returns the ID of the chest tile.
+func (ChestTile) ID() string {
+	return "Chest"
+}
+
+// Position returns the position of the chest tile.
+func (c ChestTile) Position() cube.Pos {
+	return c.Pos
+}
+
+// EncodeTile encodes the chest tile to its tiles.yml representation.
+func (c ChestTile) EncodeTile() map[string]interface{} {
+	m := map[string]interface{}{"Items": encodeItems(c.Items)}
+	if c.Paired {
+		m["pairx"], m["pairz"] = c.PairX, c.PairZ
+	}
+	return m
+}
+
+// DecodeTile decodes a chest tile from its tiles.yml representation.
+func (ChestTile) DecodeTile(pos cube.Pos, data map[string]interface{}) (Tile, error) {
+	items, err := decodeItems(data["Items"])
+	if err != nil {
+		return nil, err
+	}
+	c := ChestTile{Pos: pos, Items: items}
+	if _, ok := data["pairx"]; ok {
+		c.Paired = true
+		if c.PairX, err = optionalInt(data, "pairx"); err != nil {
+			return nil, err
+		}
+		if c.PairZ, err = optionalInt(data, "pairz"); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// FurnaceTile is the tile of a furnace, holding its contents and smelting progress.
+type FurnaceTile struct {
+	// Pos is the position of the furnace.
+	Pos cube.Pos
+	// Items holds the items in the furnace.
+	Items []Item
+	// BurnTime is the amount of ticks the current fuel will keep burning for.
+	BurnTime int
+	// CookTime is the amount of ticks the current item has been smelting for.
+	CookTime int
+	// MaxTime is the total amount of ticks the current fuel burns for.
+	MaxTime int
+}
+
+// ID returns the ID of the furnace tile.
+func (FurnaceTile) ID() string {
+	return "Furnace"
+}
+
+// Position returns the position of the furnace tile.
+func (f FurnaceTile) Position() cube.Pos {
+	return f.Pos
+}
+
+// EncodeTile encodes the furnace tile to its tiles.yml representation.
+func (f FurnaceTile) EncodeTile() map[string]interface{} {
+	return map[string]interface{}{
+		"Items":    encodeItems(f.Items),
+		"BurnTime": f.BurnTime,
+		"CookTime": f.CookTime,
+		"MaxTime":  f.MaxTime,
+	}
+}
+
+// DecodeTile decodes a furnace tile from its tiles.yml representation.
+func (FurnaceTile) DecodeTile(pos cube.Pos, data map[string]interface{}) (Tile, error) {
+	items, err := decodeItems(data["Items"])
+	if err != nil {
+		return nil, err
+	}
+	f := FurnaceTile{Pos: pos, Items: items}
+	if f.BurnTime, err = optionalInt(data, "BurnTime"); err != nil {
+		return nil, err
+	}
+	if f.CookTime, err = optionalInt(data, "CookTime"); err != nil {
+		return nil, err
+	}
+	if f.MaxTime, err = optionalInt(data, "MaxTime"); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// NetherReactorTile is the tile of a nether reactor core, holding the state of the reactor.
+type NetherReactorTile struct {
+	// Pos is the position of the nether reactor core.
+	Pos cube.Pos
+	// IsInitialized specifies if the reactor has been activated.
+	IsInitialized bool
+	// HasFinished specifies if the reactor has finished its activation and burnt out.
+	HasFinished bool
+	// Progress is the amount of ticks the reactor has been active for.
+	Progress int
+}
+
+// ID returns the ID of the nether reactor tile.
+func (NetherReactorTile) ID() string {
+	return "NetherReactor"
+}
+
+// Position returns the position of the nether reactor tile.
+func (n NetherReactorTile) Position() cube.Pos {
+	return n.Pos
+}
+
+// EncodeTile encodes the nether reactor tile to its tiles.yml representation.
+func (n NetherReactorTile) EncodeTile() map[string]interface{} {
+	return map[string]interface{}{
+		"IsInitialized": int(boolByte(n.IsInitialized)),
+		"HasFinished":   int(boolByte(n.HasFinished)),
+		"Progress":      n.Progress,
+	}
+}
+
+// DecodeTile decodes a nether reactor tile from its tiles.yml representation.
+func (NetherReactorTile) DecodeTile(pos cube.Pos, data map[string]interface{}) (Tile, error) {
+	n := NetherReactorTile{Pos: pos}
+	initialised, err := optionalInt(data, "IsInitialized")
+	if err != nil {
+		return nil, err
+	}
+	finished, err := optionalInt(data, "HasFinished")
+	if err != nil {
+		return nil, err
+	}
+	if n.Progress, err = optionalInt(data, "Progress"); err != nil {
+		return nil, err
+	}
+	n.IsInitialized, n.HasFinished = initialised != 0, finished != 0
+	return n, nil
+}
+
+// UnknownTile is a tile with an ID that was not registered using RegisterTile. Its data is kept as is, so that it
+// may be written back to tiles.yml without loss.
+type UnknownTile struct {
+	// TileID is the ID of the tile.
+	TileID string
+	// Pos is the position of the tile.
+	Pos cube.Pos
+	// Data holds all fields of the tile other than its ID and position.
+	Data map[string]interface{}
+}
+
+// ID returns the ID of the unknown tile.
+func (u UnknownTile) ID() string {
+	return u.TileID
+}
+
+// Position returns the position of the unknown tile.
+func (u UnknownTile) Position() cube.Pos {
+	return u.Pos
+}
+
+// EncodeTile encodes the unknown tile to its tiles.yml representation.
+func (u UnknownTile) EncodeTile() map[string]interface{} {
+	m := make(map[string]interface{}, len(u.Data))
+	for k, v := range u.Data {
+		m[k] = v
+	}
+	return m
+}
+
+// DecodeTile decodes an unknown tile from its tiles.yml representation.
+func (u UnknownTile) DecodeTile(pos cube.Pos, data map[string]interface{}) (Tile, error) {
+	return UnknownTile{TileID: u.TileID, Pos: pos, Data: data}, nil
+}
+
+// Tiles returns all tiles in the level, sorted by their position.
+func (p *Level) Tiles() []Tile {
+	tiles := make([]Tile, 0, len(p.tiles))
+	for _, t := range p.tiles {
+		tiles = append(tiles, t)
+	}
+	sort.Slice(tiles, func(i, j int) bool {
+		return posLess(tiles[i].Position(), tiles[j].Position())
+	})
+	return tiles
+}
+
+// TileData returns the tiles.yml representation of a tile, including its ID but without its position. All nested maps
+// are keyed by strings, so that the result can be encoded to JSON.
+func TileData(t Tile) map[string]interface{} {
+	data := stringKeys(t.EncodeTile()).(map[string]interface{})
+	data["id"] = t.ID()
+	return data
+}
+
+// TileAt returns the tile at a position. If no tile is at the position, false is returned.
+func (p *Level) TileAt(pos cube.Pos) (Tile, bool) {
+	t, ok := p.tiles[pos]
+	return t, ok
+}
+
+// SetTile adds a tile to the level, replacing any tile already present at its position. Nil tiles are ignored.
+func (p *Level) SetTile(t Tile) {
+	if t == nil {
+		return
+	}
+	p.tiles[t.Position()] = t
+}
+
+// RemoveTile removes the tile at a position. False is returned if there was no tile at the position.
+func (p *Level) RemoveTile(pos cube.Pos) bool {
+	_, ok := p.tiles[pos]
+	delete(p.tiles, pos)
+	return ok
+}
+
+// SaveTiles writes all tiles of the level back to its tiles.yml file.
+func (p *Level) SaveTiles() error {
+	b, err := encodeTiles(p.Tiles())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(p.worldPath, "tiles.yml"), b, 0644)
+}
+
+// decodeTiles decodes the contents of a tiles.yml file. Every tile must have a string ID and integer coordinates. If
+// several tiles share a position, the last one is kept and a warning is returned for every tile it replaced.
+func decodeTiles(b []byte) (map[cube.Pos]Tile, []string, error) {
+	var raw []map[string]interface{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, nil, err
+	}
+
+	tiles := make(map[cube.Pos]Tile, len(raw))
+	var warnings []string
+	for i, data := range raw {
+		t, err := decodeTile(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tile %v: %w", i, err)
+		}
+		if old, ok := tiles[t.Position()]; ok {
+			warnings = append(warnings, fmt.Sprintf("tile %v: %v tile at %v replaces the %v tile before it", i, t.ID(), t.Position(), old.ID()))
+		}
+		tiles[t.Position()] = t
+	}
+	return tiles, warnings, nil
+}
+
+// decodeTile decodes a single tile from its tiles.yml representation using the registered tile with its ID.
+func decodeTile(data map[string]interface{}) (Tile, error) {
+	id, ok := data["id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid id %v", data["id"])
+	}
+	var pos cube.Pos
+	for i, k := range []string{"x", "y", "z"} {
+		if pos[i], ok = yamlInt(data[k]); !ok {
+			return nil, fmt.Errorf("invalid %v coordinate %v", k, data[k])
+		}
+	}
+
+	fields := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		switch k {
+		case "id", "x", "y", "z":
+		default:
+			fields[k] = v
+		}
+	}
+
+	t, ok := tileRegistry[id]
+	if !ok {
+		t = UnknownTile{TileID: id}
+	}
+	return t.DecodeTile(pos, fields)
+}
+
+// encodeTiles encodes a slice of tiles to the contents of a tiles.yml file.
+func encodeTiles(tiles []Tile) ([]byte, error) {
+	raw := make([]map[string]interface{}, 0, len(tiles))
+	for _, t := range tiles {
+		data := t.EncodeTile()
+		pos := t.Position()
+		data["id"], data["x"], data["y"], data["z"] = t.ID(), pos.X(), pos.Y(), pos.Z()
+		raw = append(raw, data)
+	}
+	return yaml.Marshal(raw)
+}
+
+// decodeItems decodes a list of items from the YAML representation of an inventory.
+func decodeItems(v interface{}) ([]Item, error) {
+	if v == nil {
+		return nil, nil
+	}
+	l, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid item list %v", v)
+	}
+	items := make([]Item, 0, len(l))
+	for _, entry := range l {
+		data, ok := entry.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid item %v", entry)
+		}
+		var it Item
+		for k, dst := range map[string]*int{"id": &it.ID, "Damage": &it.Damage, "Count": &it.Count, "Slot": &it.Slot} {
+			if data[k] == nil {
+				continue
+			}
+			if *dst, ok = yamlInt(data[k]); !ok {
+				return nil, fmt.Errorf("invalid item %v %v", k, data[k])
+			}
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// encodeItems encodes a list of items to their YAML representation.
+func encodeItems(items []Item) []interface{} {
+	l := make([]interface{}, 0, len(items))
+	for _, it := range items {
+		l = append(l, map[string]interface{}{"id": it.ID, "Damage": it.Damage, "Count": it.Count, "Slot": it.Slot})
+	}
+	return l
+}
+
+// optionalInt reads an int from YAML data. Missing keys result in 0, but values that aren't numbers are an error.
+func optionalInt(data map[string]interface{}, key string) (int, error) {
+	v, ok := data[key]
+	if !ok || v == nil {
+		return 0, nil
+	}
+	i, ok := yamlInt(v)
+	if !ok {
+		return 0, fmt.Errorf("invalid %v %v", key, v)
+	}
+	return i, nil
+}
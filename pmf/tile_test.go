@@ -0,0 +1,122 @@
+package pmf
+
+import (
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"reflect"
+	"testing"
+)
+
+// TestDecodeTiles tests decoding tiles.yml files, including files with invalid tiles and files with several tiles at
+// one position, of which the last is kept with a warning.
+func TestDecodeTiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		tiles    map[cube.Pos]Tile
+		warnings int
+		ok       bool
+	}{
+		{
+			name: "sign",
+			yaml: "- {id: Sign, x: 1, \"y\": 2, z: 3, Text1: a, Text2: b, Text3: '', Text4: 4}",
+			tiles: map[cube.Pos]Tile{
+				{1, 2, 3}: SignTile{Pos: cube.Pos{1, 2, 3}, Text: [4]string{"a", "b", "", "4"}},
+			},
+			ok: true,
+		},
+		{
+			name: "chest",
+			yaml: "- {id: Chest, x: 4, \"y\": 5, z: 6, pairx: 5, pairz: 6, Items: [{id: 264, Damage: 0, Count: 3, Slot: 2}]}",
+			tiles: map[cube.Pos]Tile{
+				{4, 5, 6}: ChestTile{Pos: cube.Pos{4, 5, 6}, Items: []Item{{ID: 264, Count: 3, Slot: 2}}, Paired: true, PairX: 5, PairZ: 6},
+			},
+			ok: true,
+		},
+		{
+			name: "duplicate",
+			yaml: "- {id: Sign, x: 1, \"y\": 2, z: 3, Text1: a, Text2: '', Text3: '', Text4: ''}\n" +
+				"- {id: Chest, x: 1, \"y\": 2, z: 3}",
+			tiles: map[cube.Pos]Tile{
+				{1, 2, 3}: ChestTile{Pos: cube.Pos{1, 2, 3}},
+			},
+			warnings: 1,
+			ok:       true,
+		},
+		{name: "missing coordinate", yaml: "- {id: Sign, x: 1, z: 3}"},
+		{name: "missing id", yaml: "- {x: 1, \"y\": 2, z: 3}"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tiles, warnings, err := decodeTiles([]byte(test.yaml))
+			if !test.ok {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", tiles)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tiles, test.tiles) {
+				t.Fatalf("decoded %v, expected %v", tiles, test.tiles)
+			}
+			if len(warnings) != test.warnings {
+				t.Errorf("got warnings %v, expected %v", warnings, test.warnings)
+			}
+		})
+	}
+}
+
+// TestSetTile tests adding, replacing and removing tiles of a level.
+func TestSetTile(t *testing.T) {
+	sign, chest := cube.Pos{1, 4, 1}, cube.Pos{2, 4, 1}
+	tests := []struct {
+		name string
+		edit func(p *Level)
+		// tiles holds the tiles expected at the positions of the sign and the chest afterwards.
+		tiles []Tile
+	}{
+		{name: "add", edit: func(p *Level) {
+			p.SetTile(SignTile{Pos: sign, Text: [4]string{"a"}})
+			p.SetTile(ChestTile{Pos: chest})
+		}, tiles: []Tile{SignTile{Pos: sign, Text: [4]string{"a"}}, ChestTile{Pos: chest}}},
+		{name: "replace", edit: func(p *Level) {
+			p.SetTile(SignTile{Pos: sign, Text: [4]string{"a"}})
+			p.SetTile(FurnaceTile{Pos: sign, BurnTime: 5})
+		}, tiles: []Tile{FurnaceTile{Pos: sign, BurnTime: 5}, nil}},
+		{name: "remove", edit: func(p *Level) {
+			p.SetTile(SignTile{Pos: sign})
+			p.SetTile(ChestTile{Pos: chest})
+			if !p.RemoveTile(sign) {
+				t.Error("removing the sign returned false")
+			}
+			if p.RemoveTile(sign) {
+				t.Error("removing the sign again returned true")
+			}
+		}, tiles: []Tile{nil, ChestTile{Pos: chest}}},
+		{name: "nil", edit: func(p *Level) {
+			p.SetTile(nil)
+		}, tiles: []Tile{nil, nil}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestLevel(t, nil)
+			test.edit(p)
+			for i, pos := range []cube.Pos{sign, chest} {
+				got, ok := p.TileAt(pos)
+				if ok != (test.tiles[i] != nil) || !reflect.DeepEqual(got, test.tiles[i]) {
+					t.Errorf("got tile %v at %v, expected %v", got, pos, test.tiles[i])
+				}
+			}
+			var want []Tile
+			for _, tile := range test.tiles {
+				if tile != nil {
+					want = append(want, tile)
+				}
+			}
+			if tiles := p.Tiles(); len(tiles) != len(want) || (len(want) > 0 && !reflect.DeepEqual(tiles, want)) {
+				t.Errorf("got tiles %v, expected %v", tiles, want)
+			}
+		})
+	}
+}
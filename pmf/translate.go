@@ -0,0 +1,211 @@
+package pmf
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSignLineLength is the maximum amount of characters legacy clients could show on a single line of a sign.
+// Formatting codes don't count towards it.
+const maxSignLineLength = 15
+
+// signCSVHeader is the header row of the CSV files written by WriteSignsCSV.
+var signCSVHeader = []string{"x", "y", "z", "line1", "line2", "line3", "line4"}
+
+// SignText is the text of the sign at a position, as exported for translation.
+type SignText struct {
+	Pos   cube.Pos  `json:"pos"`
+	Lines [4]string `json:"lines"`
+}
+
+// SignImportReport is the result of importing sign text into a level or a converted world.
+type SignImportReport struct {
+	// Imported is the amount of signs whose text was replaced.
+	Imported int
+	// MissingSigns holds the positions of sign text for which no sign was found.
+	MissingSigns []cube.Pos
+	// LongLines holds all lines imported that are too long to be shown on a sign by legacy clients.
+	LongLines []LongSignLine
+}
+
+// LongSignLine is a line of sign text that is longer than legacy clients could show on a sign.
+type LongSignLine struct {
+	// Pos is the position of the sign.
+	Pos cube.Pos
+	// Line is the number of the line, from 1 to 4.
+	Line int
+	// Text is the text of the line.
+	Text string
+	// Length is the amount of characters in the line, not counting formatting codes.
+	Length int
+}
+
+// String returns a human readable summary of the report, listing all missing signs and long lines.
+func (r SignImportReport) String() string {
+	b := &strings.Builder{}
+	_, _ = fmt.Fprintf(b, "signs: %v imported, %v missing\n", r.Imported, len(r.MissingSigns))
+	for _, pos := range r.MissingSigns {
+		_, _ = fmt.Fprintf(b, "%v: no sign found\n", pos)
+	}
+	for _, l := range r.LongLines {
+		_, _ = fmt.Fprintf(b, "%v line %v: %q is %v characters long, legacy signs only fit %v\n", l.Pos, l.Line, l.Text, l.Length, maxSignLineLength)
+	}
+	return b.String()
+}
+
+// Signs returns the position and text of every sign tile in the level, sorted by position, so that it can be
+// exported for translation.
+func (p *Level) Signs() []SignText {
+	var signs []SignText
+	for _, t := range p.Tiles() {
+		if s, ok := t.(SignTile); ok {
+			signs = append(signs, SignText{Pos: s.Pos, Lines: s.Text})
+		}
+	}
+	return signs
+}
+
+// WriteSignsCSV writes sign text to a CSV file, with a header row and a row holding the X, Y and Z of the sign
+// followed by its four lines for every sign.
+func WriteSignsCSV(w io.Writer, signs []SignText) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(signCSVHeader); err != nil {
+		return err
+	}
+	for _, s := range signs {
+		record := []string{strconv.Itoa(s.Pos.X()), strconv.Itoa(s.Pos.Y()), strconv.Itoa(s.Pos.Z())}
+		if err := cw.Write(append(record, s.Lines[:]...)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadSignsCSV reads sign text from a CSV file written by WriteSignsCSV.
+func ReadSignsCSV(r io.Reader) ([]SignText, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(signCSVHeader)
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var signs []SignText
+	for i, record := range records {
+		if i == 0 && record[0] == signCSVHeader[0] {
+			continue
+		}
+		var s SignText
+		for j := range s.Pos {
+			if s.Pos[j], err = strconv.Atoi(strings.TrimSpace(record[j])); err != nil {
+				return nil, fmt.Errorf("row %v: invalid %v coordinate %q", i+1, signCSVHeader[j], record[j])
+			}
+		}
+		copy(s.Lines[:], record[3:])
+		signs = append(signs, s)
+	}
+	return signs, nil
+}
+
+// WriteSignsJSON writes sign text as a JSON list of objects, each holding the position and lines of a sign.
+func WriteSignsJSON(w io.Writer, signs []SignText) error {
+	if signs == nil {
+		signs = []SignText{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(signs)
+}
+
+// ReadSignsJSON reads sign text written by WriteSignsJSON.
+func ReadSignsJSON(r io.Reader) ([]SignText, error) {
+	var signs []SignText
+	if err := json.NewDecoder(r).Decode(&signs); err != nil {
+		return nil, err
+	}
+	return signs, nil
+}
+
+// ImportSigns replaces the text of the sign tiles of the level with the sign text passed, for example after it was
+// translated. SaveTiles must be called to write the text to the tiles.yml file.
+func (p *Level) ImportSigns(signs []SignText) SignImportReport {
+	var r SignImportReport
+	for _, s := range signs {
+		if _, ok := p.tiles[s.Pos].(SignTile); !ok {
+			r.MissingSigns = append(r.MissingSigns, s.Pos)
+			continue
+		}
+		p.tiles[s.Pos] = SignTile{Pos: s.Pos, Text: s.Lines}
+		r.Imported++
+		r.LongLines = append(r.LongLines, longSignLines(s)...)
+	}
+	return r
+}
+
+// ImportConvertedSigns replaces the text of the sign block entities in a world converted from a level with the sign
+// text passed, for example after it was translated. Positions are those in the level, which are moved by the offset
+// in chunks that the level was converted with. The lines are normalised using NormaliseSignText, keeping formatting
+// codes.
+func ImportConvertedSigns(prov *mcdb.Provider, signs []SignText, offset world.ChunkPos) (SignImportReport, error) {
+	var r SignImportReport
+	byChunk := make(map[world.ChunkPos][]SignText)
+	var chunks []world.ChunkPos
+	for _, s := range signs {
+		pos := world.ChunkPos{int32(s.Pos.X()>>4) + offset.X(), int32(s.Pos.Z()>>4) + offset.Z()}
+		if _, ok := byChunk[pos]; !ok {
+			chunks = append(chunks, pos)
+		}
+		byChunk[pos] = append(byChunk[pos], s)
+	}
+
+	for _, pos := range chunks {
+		blockEntities, err := prov.LoadBlockNBT(pos)
+		if err != nil {
+			return r, fmt.Errorf("error loading block entities of chunk %v: %w", pos, err)
+		}
+		changed := false
+		for _, s := range byChunk[pos] {
+			x, z := int32(s.Pos.X())+offset.X()<<4, int32(s.Pos.Z())+offset.Z()<<4
+			found := false
+			for _, data := range blockEntities {
+				if data["id"] == "Sign" && data["x"] == x && data["y"] == int32(s.Pos.Y()) && data["z"] == z {
+					lines := NormaliseSignText(s.Lines, false)
+					data["Text"] = strings.TrimRight(strings.Join(lines[:], "\n"), "\n")
+					found, changed = true, true
+				}
+			}
+			if !found {
+				r.MissingSigns = append(r.MissingSigns, s.Pos)
+				continue
+			}
+			r.Imported++
+			r.LongLines = append(r.LongLines, longSignLines(s)...)
+		}
+		if changed {
+			if err := prov.SaveBlockNBT(pos, blockEntities); err != nil {
+				return r, fmt.Errorf("error saving block entities of chunk %v: %w", pos, err)
+			}
+		}
+	}
+	return r, nil
+}
+
+// longSignLines returns the lines of sign text that are too long to be shown by legacy clients.
+func longSignLines(s SignText) []LongSignLine {
+	var long []LongSignLine
+	for i, line := range s.Lines {
+		if n := utf8.RuneCountInString(normaliseSignLine(line, true)); n > maxSignLineLength {
+			long = append(long, LongSignLine{Pos: s.Pos, Line: i + 1, Text: line, Length: n})
+		}
+	}
+	return long
+}
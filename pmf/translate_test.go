@@ -0,0 +1,152 @@
+package pmf
+
+import (
+	"bytes"
+	"context"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// signFormats holds the functions used to write and read sign text in every format supported.
+var signFormats = []struct {
+	name  string
+	write func(w io.Writer, signs []SignText) error
+	read  func(r io.Reader) ([]SignText, error)
+}{
+	{name: "csv", write: WriteSignsCSV, read: ReadSignsCSV},
+	{name: "json", write: WriteSignsJSON, read: ReadSignsJSON},
+}
+
+// newSignTestLevel creates a test level with a standing sign in the first chunk and a wall sign in the last chunk.
+func newSignTestLevel(t *testing.T) *Level {
+	t.Helper()
+	p := newTestLevel(t, map[cube.Pos][2]byte{{3, 5, 3}: {63, 0}, {20, 5, 20}: {68, 2}})
+	p.SetTile(SignTile{Pos: cube.Pos{3, 5, 3}, Text: [4]string{`Welcome, "friend"`, "", "§aGreen"}})
+	p.SetTile(SignTile{Pos: cube.Pos{20, 5, 20}, Text: [4]string{"Shop", "Grüße"}})
+	return p
+}
+
+// translatedSigns is the translated text of the signs of newSignTestLevel, along with text for a sign that does not
+// exist. Only the first line of the standing sign is too long for legacy clients.
+var translatedSigns = []SignText{
+	{Pos: cube.Pos{3, 5, 3}, Lines: [4]string{`Willkommen, "Freund"`, "", "§aGrün§r0123456789a"}},
+	{Pos: cube.Pos{20, 5, 20}, Lines: [4]string{"“Laden”"}},
+	{Pos: cube.Pos{1, 5, 1}, Lines: [4]string{"missing"}},
+}
+
+// TestSignsRoundTrip tests that sign text written in every format is read back unchanged, and that importing it into
+// a level replaces the text of its signs.
+func TestSignsRoundTrip(t *testing.T) {
+	for _, format := range signFormats {
+		t.Run(format.name, func(t *testing.T) {
+			p := newSignTestLevel(t)
+			signs := p.Signs()
+			if len(signs) != 2 {
+				t.Fatalf("got signs %v, expected 2", signs)
+			}
+			buf := &bytes.Buffer{}
+			if err := format.write(buf, signs); err != nil {
+				t.Fatal(err)
+			}
+			read, err := format.read(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(read, signs) {
+				t.Fatalf("read signs\n%v\nexpected\n%v", read, signs)
+			}
+
+			buf.Reset()
+			if err := format.write(buf, translatedSigns); err != nil {
+				t.Fatal(err)
+			}
+			translated, err := format.read(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := p.ImportSigns(translated)
+			wantReport := SignImportReport{
+				Imported:     2,
+				MissingSigns: []cube.Pos{{1, 5, 1}},
+				LongLines:    []LongSignLine{{Pos: cube.Pos{3, 5, 3}, Line: 1, Text: `Willkommen, "Freund"`, Length: 20}},
+			}
+			if !reflect.DeepEqual(r, wantReport) {
+				t.Errorf("got report\n%v\nexpected\n%v", r, wantReport)
+			}
+			if err := p.SaveTiles(); err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := DecodeLevel(p.worldPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signs := decoded.Signs(); !reflect.DeepEqual(signs, translatedSigns[:2]) {
+				t.Errorf("got signs\n%v\nexpected\n%v", signs, translatedSigns[:2])
+			}
+		})
+	}
+}
+
+// TestImportConvertedSigns tests that translated sign text is imported into the block entities of a world converted
+// with an offset, normalised the same way as converted signs.
+func TestImportConvertedSigns(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset world.ChunkPos
+	}{
+		{name: "in place"},
+		{name: "offset", offset: world.ChunkPos{3, -2}},
+		{name: "negative offset", offset: world.ChunkPos{-5, -1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prov := openTestProvider(t)
+			if err := newSignTestLevel(t).ConvertContext(context.Background(), prov, ConvertOptions{Offset: test.offset}); err != nil {
+				t.Fatal(err)
+			}
+			r, err := ImportConvertedSigns(prov, translatedSigns, test.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Imported != 2 || !reflect.DeepEqual(r.MissingSigns, []cube.Pos{{1, 5, 1}}) || len(r.LongLines) != 1 {
+				t.Errorf("got report\n%v\nexpected 2 signs imported, 1 missing and 1 long line", r)
+			}
+
+			shift := cube.Pos{int(test.offset.X()) << 4, 0, int(test.offset.Z()) << 4}
+			for pos, want := range map[cube.Pos]string{
+				{3, 5, 3}:   "Willkommen, \"Freund\"\n\n§aGrün§r0123456789a",
+				{20, 5, 20}: `"Laden"`,
+			} {
+				if data := blockEntityAt(t, prov, pos.Add(shift)); data == nil || data["Text"] != want {
+					t.Errorf("got sign block entity %v at %v, expected the text %q", data, pos.Add(shift), want)
+				}
+			}
+		})
+	}
+}
+
+// TestReadSignsInvalid tests that malformed sign text files are refused.
+func TestReadSignsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		read func(r io.Reader) ([]SignText, error)
+		data string
+	}{
+		{name: "csv coordinate", read: ReadSignsCSV, data: "x,y,z,line1,line2,line3,line4\n1,two,3,a,b,c,d\n"},
+		{name: "csv missing lines", read: ReadSignsCSV, data: "1,2,3,a,b\n"},
+		{name: "csv quote", read: ReadSignsCSV, data: "1,2,3,\"a,b,c,d\n"},
+		{name: "json syntax", read: ReadSignsJSON, data: `[{"pos":[1,2,3],"lines":["a"]`},
+		{name: "json position", read: ReadSignsJSON, data: `[{"pos":"1,2,3","lines":["a"]}]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := test.read(strings.NewReader(test.data)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"io"
 	"math"
+	"os"
 )
 
 // chunkFilePath gets a chunk's file path from it's X and Z.
@@ -17,6 +20,17 @@ func getIndex(x, z int) int {
 	return (z << 4) + x
 }
 
+// posLess reports whether position a sorts before position b, comparing X, then Z, then Y.
+func posLess(a, b cube.Pos) bool {
+	if a.X() != b.X() {
+		return a.X() < b.X()
+	}
+	if a.Z() != b.Z() {
+		return a.Z() < b.Z()
+	}
+	return a.Y() < b.Y()
+}
+
 // boolByte returns 1 if the bool passed is true, or 0 if it is false.
 func boolByte(b bool) uint8 {
 	if b {
@@ -25,6 +39,32 @@ func boolByte(b bool) uint8 {
 	return 0
 }
 
+// readFileLimited reads a file from a path, returning an error if it is larger than the limit passed.
+func readFileLimited(filePath string, limit int64) ([]byte, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, fmt.Errorf("%v exceeds the maximum size of %v bytes", filePath, limit)
+	}
+	return b, nil
+}
+
+// next reads exactly n bytes from a buffer, returning io.ErrUnexpectedEOF if there are not enough bytes left.
+func next(buf *bytes.Buffer, n int) ([]byte, error) {
+	if n < 0 || buf.Len() < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf.Next(n), nil
+}
+
 // writeString writes a string to a buffer.
 func writeString(buf *bytes.Buffer, v string) {
 	writeUint16(buf, uint16(len(v)))
@@ -32,8 +72,16 @@ func writeString(buf *bytes.Buffer, v string) {
 }
 
 // readString reads a string from a buffer.
-func readString(buf *bytes.Buffer) string {
-	return string(buf.Next(int(readUint16(buf))))
+func readString(buf *bytes.Buffer) (string, error) {
+	l, err := readUint16(buf)
+	if err != nil {
+		return "", err
+	}
+	b, err := next(buf, int(l))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
 }
 
 // writeFloat32 writes a float32 to a buffer.
@@ -42,8 +90,9 @@ func writeFloat32(buf *bytes.Buffer, v float32) {
 }
 
 // readFloat32 reads a float32 from a buffer.
-func readFloat32(buf *bytes.Buffer) float32 {
-	return math.Float32frombits(readUint32(buf))
+func readFloat32(buf *bytes.Buffer) (float32, error) {
+	v, err := readUint32(buf)
+	return math.Float32frombits(v), err
 }
 
 // writeUint32 writes an uint32 to a buffer.
@@ -52,8 +101,12 @@ func writeUint32(buf *bytes.Buffer, v uint32) {
 }
 
 // readUint32 reads an uint32 from a buffer.
-func readUint32(buf *bytes.Buffer) uint32 {
-	return binary.BigEndian.Uint32(buf.Next(4))
+func readUint32(buf *bytes.Buffer) (uint32, error) {
+	b, err := next(buf, 4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
 }
 
 // writeUint16 writes an uint16 to a buffer.
@@ -62,6 +115,76 @@ func writeUint16(buf *bytes.Buffer, v uint16) {
 }
 
 // readUint16 reads an uint16 from a buffer.
-func readUint16(buf *bytes.Buffer) uint16 {
-	return binary.BigEndian.Uint16(buf.Next(2))
+func readUint16(buf *bytes.Buffer) (uint16, error) {
+	b, err := next(buf, 2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b), nil
+}
+
+// yamlInt converts a scalar decoded from YAML to an int. YAML files written by PocketMine sometimes store numbers as
+// floats or strings, so these are converted too.
+func yamlInt(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case int64:
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return 0, false
+		}
+		return int(v), true
+	case uint64:
+		if v > math.MaxInt32 {
+			return 0, false
+		}
+		return int(v), true
+	case float64:
+		if math.IsNaN(v) || v < math.MinInt32 || v > math.MaxInt32 {
+			return 0, false
+		}
+		return int(v), true
+	case string:
+		var i int
+		if _, err := fmt.Sscan(v, &i); err != nil {
+			return 0, false
+		}
+		return i, true
+	}
+	return 0, false
+}
+
+// yamlFloat converts a scalar decoded from YAML to a float32.
+func yamlFloat(v interface{}) (float32, bool) {
+	switch v := v.(type) {
+	case int:
+		return float32(v), true
+	case int64:
+		return float32(v), true
+	case uint64:
+		return float32(v), true
+	case float64:
+		return float32(v), true
+	case string:
+		var f float32
+		if _, err := fmt.Sscan(v, &f); err != nil {
+			return 0, false
+		}
+		return f, true
+	}
+	return 0, false
+}
+
+// yamlString converts a scalar decoded from YAML to a string. Sign text such as "123" is decoded as a number by the
+// YAML decoder, so any scalar is formatted back to its string form.
+func yamlString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case nil:
+		return "", true
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
 }
@@ -0,0 +1,299 @@
+package pmf
+
+import (
+	"fmt"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"strings"
+)
+
+// maxVerifySamples is the maximum amount of sample mismatches kept in a VerifyReport.
+const maxVerifySamples = 20
+
+// VerifyReport is the result of verifying a converted world against the level it was converted from.
+type VerifyReport struct {
+	// ChunksChecked is the amount of chunks of the level that were compared.
+	ChunksChecked int
+	// MissingChunks is the amount of chunks holding blocks that were not found in the provider.
+	MissingChunks int
+	// BlocksChecked is the amount of blocks that were compared.
+	BlocksChecked int
+	// BlockMismatches is the amount of blocks whose runtime ID in the provider differs from the one the conversion
+	// table says it should have.
+	BlockMismatches int
+	// TilesChecked is the amount of tiles of the level whose block entity was looked up.
+	TilesChecked int
+	// MissingBlockEntities is the amount of tiles without a block entity at their position in the provider.
+	MissingBlockEntities int
+	// Samples holds the first mismatches found, up to 20.
+	Samples []VerifyMismatch
+}
+
+// VerifyMismatch is a single difference between a converted world and its level.
+type VerifyMismatch struct {
+	// Pos is the position of the block or tile that differs.
+	Pos cube.Pos
+	// Expected is the block or block entity that should be at the position.
+	Expected string
+	// Actual is the block or block entity found at the position in the provider.
+	Actual string
+}
+
+// OK returns true if no differences were found.
+func (r VerifyReport) OK() bool {
+	return r.MissingChunks == 0 && r.BlockMismatches == 0 && r.MissingBlockEntities == 0
+}
+
+// String returns a human readable summary of the report, including the sample mismatches.
+func (r VerifyReport) String() string {
+	b := &strings.Builder{}
+	_, _ = fmt.Fprintf(b, "chunks: %v checked, %v missing\n", r.ChunksChecked, r.MissingChunks)
+	_, _ = fmt.Fprintf(b, "blocks: %v checked, %v mismatched\n", r.BlocksChecked, r.BlockMismatches)
+	_, _ = fmt.Fprintf(b, "block entities: %v checked, %v missing\n", r.TilesChecked, r.MissingBlockEntities)
+	for _, m := range r.Samples {
+		_, _ = fmt.Fprintf(b, "%v: expected %v, got %v\n", m.Pos, m.Expected, m.Actual)
+	}
+	return b.String()
+}
+
+// sample adds a mismatch to the samples of the report if there is room for it.
+func (r *VerifyReport) sample(pos cube.Pos, expected, actual string) {
+	if len(r.Samples) < maxVerifySamples {
+		r.Samples = append(r.Samples, VerifyMismatch{Pos: pos, Expected: expected, Actual: actual})
+	}
+}
+
+// Verify compares a world converted from a level using the options passed with the level. Every block in the
+// converted area is looked up in the conversion table of the game version of the level, with multi block structures
+// such as doors and beds fixed up and unknown blocks replaced with air, and its runtime ID is compared with the one at
+// its position in the converted world. Blocks of converted chunks outside of the area must be air, apart from the
+// border wall, which is checked around the area, and the empty chunks written for VoidChunks must exist. Every tile in
+// the area must also have a block entity at its position, so tiles that the conversion does not support are reported
+// as missing.
+func Verify(level *Level, prov *mcdb.Provider, opts ConvertOptions) (VerifyReport, error) {
+	return VerifyBatch(prov, []BatchLevel{{Level: level, Options: opts}})
+}
+
+// VerifyBatch compares a world converted from several levels using ConvertBatch with the levels, in the same way as
+// Verify. The margins around every level are only checked outside of the chunks of other levels, which the
+// conversion leaves alone.
+func VerifyBatch(prov *mcdb.Provider, levels []BatchLevel) (VerifyReport, error) {
+	var r VerifyReport
+	batch := &batchState{levelChunks: make(map[world.ChunkPos]bool)}
+	converters := make([]*converter, 0, len(levels))
+	for i, l := range levels {
+		c, err := newConverter(l.Level, prov, l.Options)
+		if err != nil {
+			return r, fmt.Errorf("level %v: %w", i, err)
+		}
+		c.batch = batch
+		for _, pos := range c.targetChunks() {
+			batch.levelChunks[pos] = true
+		}
+		converters = append(converters, c)
+	}
+	for i, c := range converters {
+		if err := c.verify(&r); err != nil {
+			return r, fmt.Errorf("level %v: %w", i, err)
+		}
+	}
+	return r, nil
+}
+
+// verify compares the converted area, the margin around it and the block entities of the tiles in it with the level.
+func (c *converter) verify(r *VerifyReport) error {
+	for _, pos := range c.sourceChunks() {
+		if err := c.verifyChunk(pos, r); err != nil {
+			return fmt.Errorf("error verifying chunk %v, %v: %w", pos.X(), pos.Z(), err)
+		}
+	}
+	if !c.area.Empty() {
+		for _, pos := range c.marginChunks() {
+			if c.batch.levelChunks[pos] {
+				continue
+			}
+			if err := c.verifyMarginChunk(pos, r); err != nil {
+				return fmt.Errorf("error verifying chunk %v, %v: %w", pos.X(), pos.Z(), err)
+			}
+		}
+	}
+
+	for _, pos := range c.sourceChunks() {
+		tiles := c.tiles[pos]
+		if len(tiles) == 0 {
+			continue
+		}
+		blockEntities, err := c.prov.LoadBlockNBT(world.ChunkPos{pos.X() + c.opts.Offset.X(), pos.Z() + c.opts.Offset.Z()})
+		if err != nil {
+			return err
+		}
+		for _, t := range tiles {
+			if _, ok := t.(NetherReactorTile); ok {
+				if id, err := c.level.BlockID(t.Position()); err != nil || id != 247 {
+					// The conversion leaves out reactor tiles without a core, as there is nothing to attach them to.
+					continue
+				}
+			}
+			r.TilesChecked++
+			if pos := c.target(t.Position()); !hasBlockEntity(blockEntities, pos) {
+				r.MissingBlockEntities++
+				r.sample(pos, t.ID()+" block entity", "nothing")
+			}
+		}
+	}
+	return nil
+}
+
+// verifyChunk compares the blocks of a chunk of the level with the chunk at its position in the converted world.
+// Blocks inside the converted area must have the runtime ID that the conversion table gives, and all other blocks
+// must be air or part of the border wall.
+func (c *converter) verifyChunk(pos world.ChunkPos, r *VerifyReport) error {
+	pm, err := c.level.Chunk(int(pos.X()), int(pos.Z()))
+	if err != nil {
+		return err
+	}
+	// expected holds the runtime IDs that every block of the chunk should have, indexed by X, Z and Y.
+	var expected [16][16][cube.MaxY + 1]uint32
+	needed := c.opts.VoidChunks
+	baseX, baseZ := int(pos.X())<<4, int(pos.Z())<<4
+	for x := 0; x < 16; x++ {
+		for z := 0; z < 16; z++ {
+			target := c.target(cube.Pos{baseX + x, 0, baseZ + z})
+			wall := c.opts.BorderWall != BorderWallNone && c.onWall(target.X(), target.Z())
+			for y := 0; y <= cube.MaxY; y++ {
+				blockPos := cube.Pos{baseX + x, y, baseZ + z}
+				switch {
+				case wall:
+					expected[x][z][y] = c.wall
+				case c.area.Contains(blockPos):
+					rid, err := c.expectedRuntimeID(pm, blockPos)
+					if err != nil {
+						return err
+					}
+					expected[x][z][y] = rid
+				default:
+					expected[x][z][y] = c.air
+				}
+				needed = needed || expected[x][z][y] != c.air
+			}
+		}
+	}
+
+	targetPos := world.ChunkPos{pos.X() + c.opts.Offset.X(), pos.Z() + c.opts.Offset.Z()}
+	r.ChunksChecked++
+	actual, exists, err := c.prov.LoadChunk(targetPos)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if needed {
+			r.MissingChunks++
+			r.sample(cube.Pos{int(targetPos.X()) << 4, 0, int(targetPos.Z()) << 4}, "chunk", "nothing")
+		}
+		// A chunk that is entirely air is not written unless VoidChunks is set, so there is nothing to compare.
+		return nil
+	}
+	for x := 0; x < 16; x++ {
+		for z := 0; z < 16; z++ {
+			for y := 0; y <= cube.MaxY; y++ {
+				r.BlocksChecked++
+				want, got := expected[x][z][y], actual.RuntimeID(uint8(x), int16(y), uint8(z), 0)
+				if want == got {
+					continue
+				}
+				r.BlockMismatches++
+				r.sample(cube.Pos{int(targetPos.X())<<4 + x, y, int(targetPos.Z())<<4 + z}, stateString(want), stateString(got))
+			}
+		}
+	}
+	return nil
+}
+
+// verifyMarginChunk checks that a chunk around the converted area exists and holds the border wall, if one was built.
+// Other blocks in the chunk are not compared, as the margin of another level in a batch may hold its wall.
+func (c *converter) verifyMarginChunk(pos world.ChunkPos, r *VerifyReport) error {
+	r.ChunksChecked++
+	actual, exists, err := c.prov.LoadChunk(pos)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		r.MissingChunks++
+		r.sample(cube.Pos{int(pos.X()) << 4, 0, int(pos.Z()) << 4}, "chunk", "nothing")
+		return nil
+	}
+	if c.opts.BorderWall == BorderWallNone {
+		return nil
+	}
+	for x := 0; x < 16; x++ {
+		for z := 0; z < 16; z++ {
+			blockX, blockZ := int(pos.X())<<4+x, int(pos.Z())<<4+z
+			if !c.onWall(blockX, blockZ) {
+				continue
+			}
+			for y := 0; y <= cube.MaxY; y++ {
+				r.BlocksChecked++
+				if got := actual.RuntimeID(uint8(x), int16(y), uint8(z), 0); got != c.wall {
+					r.BlockMismatches++
+					r.sample(cube.Pos{blockX, y, blockZ}, stateString(c.wall), stateString(got))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// expectedRuntimeID returns the runtime ID of the block that a block of a PMF chunk should be converted to: the
+// block in the conversion table of the game version, with the full state of multi block structures, or air if the
+// block is unknown.
+func (c *converter) expectedRuntimeID(pm *Chunk, pos cube.Pos) (uint32, error) {
+	id, err := pm.BlockID(pos)
+	if err != nil {
+		return 0, err
+	}
+	meta, err := pm.BlockMeta(pos)
+	if err != nil {
+		return 0, err
+	}
+	b, ok := c.version.block(id, meta)
+	if !ok {
+		return c.air, nil
+	}
+	if fix, ok := multiBlockFixers[id]; ok {
+		if fixed, ok := fix(c.level, pos, id, meta); ok {
+			b = fixed
+		}
+	}
+	rid, ok := chunk.StateToRuntimeID(b.name, b.properties)
+	if !ok {
+		return 0, fmt.Errorf("could not find runtime id for state: %v, %v", b.name, b.properties)
+	}
+	return rid, nil
+}
+
+// hasBlockEntity checks if a list of block entities holds one at the position passed.
+func hasBlockEntity(blockEntities []map[string]interface{}, pos cube.Pos) bool {
+	for _, data := range blockEntities {
+		x, _ := data["x"].(int32)
+		y, _ := data["y"].(int32)
+		z, _ := data["z"].(int32)
+		if int(x) == pos.X() && int(y) == pos.Y() && int(z) == pos.Z() {
+			return true
+		}
+	}
+	return false
+}
+
+// stateString returns the name and properties of the block state with a runtime ID.
+func stateString(rid uint32) string {
+	name, properties, ok := chunk.RuntimeIDToState(rid)
+	if !ok {
+		return fmt.Sprintf("unknown runtime ID %v", rid)
+	}
+	if len(properties) == 0 {
+		return name
+	}
+	return fmt.Sprintf("%v %v", name, properties)
+}
@@ -0,0 +1,120 @@
+package pmf
+
+import (
+	"context"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"testing"
+)
+
+// TestVerify tests that converted worlds are verified against the conversion table with the options they were
+// converted with, and that differences are reported.
+func TestVerify(t *testing.T) {
+	blocks := map[cube.Pos][2]byte{
+		{5, 4, 5}:   {64, 1},  // Lower door half.
+		{5, 5, 5}:   {64, 9},  // Upper door half with its hinge on the right.
+		{6, 4, 6}:   {54, 2},  // Chest.
+		{7, 4, 7}:   {210, 0}, // Unknown block, replaced with air.
+		{20, 4, 20}: {66, 1},  // Rail, which only exists in MCPE 0.8.
+	}
+	crop := &Box{Min: cube.Pos{4, 0, 4}, Max: cube.Pos{20, 127, 27}}
+	full := ConvertOptions{Offset: world.ChunkPos{-1, 2}, Crop: crop, VoidChunks: true, VoidMargin: 2, BorderWall: BorderWallBarrier}
+
+	tests := []struct {
+		name string
+		opts ConvertOptions
+		// setup changes the level before it is converted.
+		setup func(p *Level)
+		// change changes the level or the converted world after the conversion.
+		change func(t *testing.T, p *Level, prov *mcdb.Provider)
+		// verifyOpts are the options passed to Verify.
+		verifyOpts ConvertOptions
+		ok         bool
+		// sample is the position of the first mismatch expected, if any.
+		sample *cube.Pos
+	}{
+		{name: "default", ok: true},
+		{name: "offset, crop, void and wall", opts: full, verifyOpts: full, ok: true},
+		{name: "other offset", opts: full, verifyOpts: ConvertOptions{Crop: crop, VoidChunks: true, VoidMargin: 2, BorderWall: BorderWallBarrier}},
+		{name: "no wall", opts: ConvertOptions{VoidChunks: true}, verifyOpts: ConvertOptions{VoidChunks: true, BorderWall: BorderWallBarrier}, sample: &cube.Pos{-16, 0, -16}},
+		{name: "changed block", change: func(t *testing.T, p *Level, prov *mcdb.Provider) {
+			ch, _, err := prov.LoadChunk(world.ChunkPos{0, 0})
+			if err != nil {
+				t.Fatal(err)
+			}
+			rid, _ := chunk.StateToRuntimeID("minecraft:gold_block", nil)
+			ch.SetRuntimeID(1, 4, 1, 0, rid)
+			if err := prov.SaveChunk(world.ChunkPos{0, 0}, ch); err != nil {
+				t.Fatal(err)
+			}
+		}, sample: &cube.Pos{1, 4, 1}},
+		{name: "other game version", change: func(t *testing.T, p *Level, prov *mcdb.Provider) {
+			p.GameVersion = GameVersionAlpha02
+		}, sample: &cube.Pos{20, 4, 20}},
+		{name: "unsupported tile", setup: func(p *Level) {
+			p.SetTile(UnknownTile{TileID: "Unknown", Pos: cube.Pos{8, 4, 8}})
+		}, sample: &cube.Pos{8, 4, 8}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestLevel(t, blocks)
+			p.SetTile(ChestTile{Pos: cube.Pos{6, 4, 6}})
+			if test.setup != nil {
+				test.setup(p)
+			}
+			prov := openTestProvider(t)
+			if err := p.ConvertContext(context.Background(), prov, test.opts); err != nil {
+				t.Fatal(err)
+			}
+			if test.change != nil {
+				test.change(t, p, prov)
+			}
+			r, err := Verify(p, prov, test.verifyOpts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.OK() != test.ok {
+				t.Fatalf("got OK %v, expected %v:\n%v", r.OK(), test.ok, r)
+			}
+			if r.TilesChecked == 0 {
+				t.Errorf("no tiles were checked")
+			}
+			if test.sample != nil && (len(r.Samples) == 0 || r.Samples[0].Pos != *test.sample) {
+				t.Errorf("expected the first mismatch at %v:\n%v", *test.sample, r)
+			}
+		})
+	}
+}
+
+// TestVerifyBatch tests that the margins of levels converted in a batch are only checked outside of other levels.
+func TestVerifyBatch(t *testing.T) {
+	opts := ConvertOptions{VoidChunks: true, VoidMargin: 1, BorderWall: BorderWallInvisibleBedrock}
+	right := opts
+	right.Offset = world.ChunkPos{2, 0}
+	levels := []BatchLevel{
+		{Level: newTestLevel(t, map[cube.Pos][2]byte{{1, 4, 1}: {1, 0}}), Options: opts},
+		{Level: newTestLevel(t, map[cube.Pos][2]byte{{2, 4, 2}: {5, 3}}), Options: right},
+	}
+	prov := openTestProvider(t)
+	if err := ConvertBatch(context.Background(), prov, levels, Checkpoint{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := VerifyBatch(prov, levels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.OK() {
+		t.Fatalf("batch did not verify:\n%v", r)
+	}
+	// On its own, the wall of the left level is expected in the first chunk of the right level.
+	r, err = Verify(levels[0].Level, prov, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.OK() {
+		t.Fatal("expected the left level to differ from its margin on its own")
+	}
+}
@@ -0,0 +1,202 @@
+package pmf
+
+import "os"
+
+// GameVersion is a release of Minecraft: Pocket Edition that a PMF world was saved by. Block IDs and metadata values
+// changed meaning between releases, so the version decides which conversion table is used for a level.
+type GameVersion int
+
+const (
+	// GameVersionUnknown is the game version of a level that was not configured. The version of levels with an
+	// unknown version is detected by Level.Block and Level.Convert before their blocks are converted.
+	GameVersionUnknown GameVersion = iota
+	// GameVersionAlpha02 covers MCPE 0.2.0 up to 0.4.0, saved by the first PocketMine Alpha releases.
+	GameVersionAlpha02
+	// GameVersionAlpha05 covers MCPE 0.5.0 up to 0.7.6, which added the nether reactor and glowing obsidian.
+	GameVersionAlpha05
+	// GameVersionAlpha08 covers MCPE 0.8.x, the last releases with finite worlds, which added rails, carrots,
+	// potatoes and beetroots.
+	GameVersionAlpha08
+)
+
+// gameVersions holds all known game versions, from old to new.
+var gameVersions = []GameVersion{GameVersionAlpha02, GameVersionAlpha05, GameVersionAlpha08}
+
+// String returns the MCPE releases covered by the game version.
+func (v GameVersion) String() string {
+	switch v {
+	case GameVersionAlpha02:
+		return "MCPE 0.2-0.4"
+	case GameVersionAlpha05:
+		return "MCPE 0.5-0.7"
+	case GameVersionAlpha08:
+		return "MCPE 0.8"
+	}
+	return "unknown"
+}
+
+// blockIntroduced holds the game version in which each legacy block ID that was added during the lifetime of PMF
+// was introduced. IDs below 240 that are not in this map existed in all versions.
+//
+// Pocket Edition assigned ID 95 and the IDs from 240 up differently from Java Edition, and Bedrock Edition later
+// assigned the IDs from 240 up that were unused during MCPE 0.x to new blocks: chorus plants (240), stained glass
+// (241), the camera (242), podzol (243), moving blocks (250), observers (251), structure blocks (252) and hard glass
+// (253 and 254). Of those IDs, only the ones in this map are known in the tables of MCPE 0.x versions, so that they
+// are never converted to blocks that did not exist yet.
+var blockIntroduced = map[byte]GameVersion{
+	27:  GameVersionAlpha08, // Powered rail.
+	66:  GameVersionAlpha08, // Rail.
+	95:  GameVersionAlpha02, // Invisible bedrock, and stained glass from MCPE 0.8 on.
+	141: GameVersionAlpha08, // Carrots.
+	142: GameVersionAlpha08, // Potatoes.
+	244: GameVersionAlpha08, // Beetroot.
+	245: GameVersionAlpha02, // Stonecutter.
+	246: GameVersionAlpha05, // Glowing obsidian.
+	247: GameVersionAlpha05, // Nether reactor core.
+	248: GameVersionAlpha02, // Update game block.
+	249: GameVersionAlpha02, // Update game block.
+	255: GameVersionAlpha02, // Reserved block.
+}
+
+// reassignment is the meaning of a legacy block ID from a game version on, given as the ID that holds the same block
+// in the modern table.
+type reassignment struct {
+	since GameVersion
+	id    byte
+}
+
+// reassignedBlocks holds the legacy block IDs that held different blocks in different game versions, with the
+// meanings they had from old to new. ID 95 held invisible bedrock in the first releases, and stained glass in worlds
+// saved by MCPE 0.8, which Bedrock Edition later moved to ID 241 to give ID 95 back to invisible bedrock.
+var reassignedBlocks = map[byte][]reassignment{
+	95: {{since: GameVersionAlpha02, id: 95}, {since: GameVersionAlpha08, id: 241}},
+}
+
+// versionTables holds the conversion table of every game version, indexed by legacy ID and metadata value. The
+// tables are built from the modern table by init.
+var versionTables = map[GameVersion]map[oldBlock]newBlock{}
+
+func init() {
+	for _, v := range gameVersions {
+		table := make(map[oldBlock]newBlock, len(conversion))
+		for old, b := range conversion {
+			if _, ok := reassignedBlocks[old.id]; !ok && v.hasBlock(old.id) {
+				table[old] = b
+			}
+		}
+		for id, meanings := range reassignedBlocks {
+			if !v.hasBlock(id) {
+				continue
+			}
+			var current reassignment
+			for _, r := range meanings {
+				if r.since <= v {
+					current = r
+				}
+			}
+			for meta := uint8(0); meta < 16; meta++ {
+				if b, ok := conversion[oldBlock{id: current.id, metadata: meta}]; ok {
+					table[oldBlock{id: id, metadata: meta}] = b
+				}
+			}
+		}
+		// MCPE 0.x trapdoors had no upside down variant and stored whether they were open in the 0x4 bit, which the
+		// modern table reads as the upside down bit. The 0x8 bit, which is now the open bit, was unused.
+		for meta := uint8(0); meta < 16; meta++ {
+			table[oldBlock{id: 96, metadata: meta}] = newBlock{name: "minecraft:trapdoor", properties: map[string]interface{}{
+				"direction":       int32(meta & 0x3),
+				"open_bit":        (meta >> 2) & 0x1,
+				"upside_down_bit": uint8(0),
+			}}
+		}
+		versionTables[v] = table
+	}
+}
+
+// hasBlock checks if a legacy block ID existed in the game version.
+func (v GameVersion) hasBlock(id byte) bool {
+	introduced, ok := blockIntroduced[id]
+	if !ok {
+		return id < 240
+	}
+	return introduced <= v
+}
+
+// block looks up the new block for a legacy ID and metadata value in the conversion table of the game version. If
+// the exact metadata value is unknown, the block with metadata 0 is used instead, as unused metadata bits were often
+// left set by legacy servers. The table of the newest version is used for GameVersionUnknown.
+func (v GameVersion) block(id, metadata byte) (newBlock, bool) {
+	table, ok := versionTables[v]
+	if !ok {
+		table = versionTables[gameVersions[len(gameVersions)-1]]
+	}
+	if b, ok := table[oldBlock{id: id, metadata: metadata}]; ok {
+		return b, true
+	}
+	b, ok := table[oldBlock{id: id}]
+	return b, ok
+}
+
+// gameVersion returns the game version of the level, detecting it using DetectGameVersion if it is unknown. The
+// detected version is kept and updated by Level.SetBlockID, so that the level is only scanned again when a block
+// that raised the version is removed.
+func (p *Level) gameVersion() (GameVersion, error) {
+	if p.GameVersion != GameVersionUnknown {
+		return p.GameVersion, nil
+	}
+	if p.detectedVersion == GameVersionUnknown {
+		v, err := p.DetectGameVersion()
+		if err != nil {
+			return GameVersionUnknown, err
+		}
+		p.detectedVersion = v
+	}
+	return p.detectedVersion, nil
+}
+
+// updateDetectedVersion updates the detected game version of the level after a block with the old ID passed was
+// replaced with a block with the new ID. Only IDs introduced after the oldest version affect the version: placing
+// one raises the version right away, and removing one makes the version be detected again the next time it is
+// needed, as it may have been the only block that raised it.
+func (p *Level) updateDetectedVersion(old, new byte) {
+	if p.detectedVersion == GameVersionUnknown || old == new {
+		return
+	}
+	if introduced, ok := blockIntroduced[old]; ok && introduced > gameVersions[0] {
+		p.detectedVersion = GameVersionUnknown
+		return
+	}
+	if introduced, ok := blockIntroduced[new]; ok && introduced > p.detectedVersion {
+		p.detectedVersion = introduced
+	}
+}
+
+// DetectGameVersion detects the game version the level was saved by from the blocks in it. The oldest version in
+// which all blocks of the level existed is returned. Blocks that did not exist in any version, such as those that
+// Bedrock Edition added at IDs from 240 up, don't affect the version and stay unknown when the level is converted.
+// The level's GameVersion field is not changed.
+func (p *Level) DetectGameVersion() (GameVersion, error) {
+	version := gameVersions[0]
+	for x := 0; x < int(p.Width); x++ {
+		for z := 0; z < int(p.Width); z++ {
+			c, err := p.Chunk(x, z)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return GameVersionUnknown, err
+			}
+			for _, sub := range c.subChunks {
+				for i := 0; i < subChunkSize; i += 32 {
+					// Only the first 16 bytes of each 32 byte column are block IDs.
+					for _, id := range sub[i : i+16] {
+						if introduced, ok := blockIntroduced[id]; ok && introduced > version {
+							version = introduced
+						}
+					}
+				}
+			}
+		}
+	}
+	return version, nil
+}
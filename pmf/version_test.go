@@ -0,0 +1,168 @@
+package pmf
+
+import (
+	"context"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"reflect"
+	"testing"
+)
+
+// TestGameVersionBlock tests that the same legacy ID and metadata value converts to the block it was in the table of
+// every game version.
+func TestGameVersionBlock(t *testing.T) {
+	invisibleBedrock := newBlock{name: "minecraft:invisibleBedrock", properties: map[string]interface{}{}}
+	reactor := newBlock{name: "minecraft:netherreactor", properties: map[string]interface{}{}}
+	openTrapdoor := newBlock{name: "minecraft:trapdoor", properties: map[string]interface{}{
+		"direction": int32(1), "open_bit": uint8(1), "upside_down_bit": uint8(0),
+	}}
+	tests := []struct {
+		name     string
+		id, meta byte
+		// want holds the block expected for every version, indexed by version. Versions without an entry expect the
+		// block to be unknown.
+		want map[GameVersion]newBlock
+	}{
+		{name: "reassigned 95", id: 95, want: map[GameVersion]newBlock{
+			GameVersionAlpha02: invisibleBedrock, GameVersionAlpha05: invisibleBedrock, GameVersionAlpha08: conversion[oldBlock{id: 241}],
+		}},
+		{name: "reassigned 95 with metadata", id: 95, meta: 3, want: map[GameVersion]newBlock{
+			GameVersionAlpha02: invisibleBedrock, GameVersionAlpha05: invisibleBedrock, GameVersionAlpha08: conversion[oldBlock{id: 241, metadata: 3}],
+		}},
+		{name: "stained glass", id: 241, meta: 3},
+		{name: "podzol", id: 243},
+		{name: "observer", id: 251, meta: 2},
+		{name: "nether reactor", id: 247, want: map[GameVersion]newBlock{GameVersionAlpha05: reactor, GameVersionAlpha08: reactor}},
+		{name: "beetroot", id: 244, meta: 7, want: map[GameVersion]newBlock{
+			GameVersionAlpha08: {name: "minecraft:beetroot", properties: map[string]interface{}{"growth": int32(7)}},
+		}},
+		{name: "rail", id: 66, meta: 1, want: map[GameVersion]newBlock{
+			GameVersionAlpha08: conversion[oldBlock{id: 66, metadata: 1}],
+		}},
+		{name: "open trapdoor", id: 96, meta: 5, want: map[GameVersion]newBlock{
+			GameVersionAlpha02: openTrapdoor, GameVersionAlpha05: openTrapdoor, GameVersionAlpha08: openTrapdoor,
+		}},
+		{name: "stone", id: 1, want: map[GameVersion]newBlock{
+			GameVersionAlpha02: conversion[oldBlock{id: 1}], GameVersionAlpha05: conversion[oldBlock{id: 1}], GameVersionAlpha08: conversion[oldBlock{id: 1}],
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, v := range gameVersions {
+				b, ok := v.block(test.id, test.meta)
+				want, wantOK := test.want[v]
+				if ok != wantOK || (ok && !reflect.DeepEqual(b, want)) {
+					t.Errorf("%v: %v:%v converted to %v (%v), expected %v (%v)", v, test.id, test.meta, b, ok, want, wantOK)
+				}
+			}
+		})
+	}
+}
+
+// TestGameVersionConsistency tests that Level.Block and a conversion use the same table for a level with an unknown
+// game version, and that the table follows the detected version.
+func TestGameVersionConsistency(t *testing.T) {
+	trapdoor, reactor := cube.Pos{1, 5, 1}, cube.Pos{2, 5, 2}
+	tests := []struct {
+		name    string
+		blocks  map[cube.Pos][2]byte
+		version GameVersion
+	}{
+		{name: "old", blocks: map[cube.Pos][2]byte{trapdoor: {96, 6}}, version: GameVersionAlpha02},
+		{name: "reactor", blocks: map[cube.Pos][2]byte{trapdoor: {96, 6}, reactor: {247, 0}}, version: GameVersionAlpha05},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestLevel(t, test.blocks)
+			if v, err := p.gameVersion(); err != nil || v != test.version {
+				t.Fatalf("detected %v (%v), expected %v", v, err, test.version)
+			}
+			name, properties, err := p.Block(trapdoor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if properties["open_bit"] != uint8(1) || properties["upside_down_bit"] != uint8(0) {
+				t.Errorf("Level.Block returned %v %v for an open trapdoor", name, properties)
+			}
+
+			prov := openTestProvider(t)
+			if err := p.ConvertContext(context.Background(), prov, ConvertOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			ch, _, err := prov.LoadChunk(chunkPosOf(trapdoor))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := chunk.StateToRuntimeID(name, properties)
+			if got := ch.RuntimeID(uint8(trapdoor.X()), int16(trapdoor.Y()), uint8(trapdoor.Z()), 0); got != want {
+				t.Errorf("converted trapdoor has runtime ID %v, expected %v as returned by Level.Block", got, want)
+			}
+		})
+	}
+}
+
+// TestConvertReassignedBlock tests that a block with an ID that was reassigned between game versions is converted to
+// the block it was in the game version of the level.
+func TestConvertReassignedBlock(t *testing.T) {
+	pos := cube.Pos{4, 6, 4}
+	tests := []struct {
+		version GameVersion
+		want    string
+	}{
+		{version: GameVersionAlpha02, want: "minecraft:invisibleBedrock"},
+		{version: GameVersionAlpha08, want: "minecraft:stained_glass"},
+	}
+	for _, test := range tests {
+		t.Run(test.version.String(), func(t *testing.T) {
+			p := newTestLevel(t, map[cube.Pos][2]byte{pos: {95, 14}})
+			p.GameVersion = test.version
+			prov := openTestProvider(t)
+			if err := p.ConvertContext(context.Background(), prov, ConvertOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if name := blockNameAt(t, prov, pos); name != test.want {
+				t.Errorf("got %v, expected %v", name, test.want)
+			}
+		})
+	}
+}
+
+// TestDetectedVersionUpdate tests that the detected game version of a level follows the blocks placed and removed
+// through Level.SetBlockID, and that other blocks do not make the level be scanned again.
+func TestDetectedVersionUpdate(t *testing.T) {
+	reactor := cube.Pos{2, 5, 2}
+	tests := []struct {
+		name string
+		pos  cube.Pos
+		id   byte
+		want GameVersion
+	}{
+		{name: "stone", pos: cube.Pos{3, 6, 3}, id: 1, want: GameVersionAlpha05},
+		{name: "glowing obsidian", pos: cube.Pos{3, 6, 3}, id: 246, want: GameVersionAlpha05},
+		{name: "carrots", pos: cube.Pos{3, 6, 3}, id: 141, want: GameVersionAlpha08},
+		// Only removing the reactor makes the level be scanned again, which finds the rail.
+		{name: "removed reactor", pos: reactor, want: GameVersionAlpha08},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestLevel(t, map[cube.Pos][2]byte{reactor: {247, 0}})
+			if v, err := p.gameVersion(); err != nil || v != GameVersionAlpha05 {
+				t.Fatalf("detected %v (%v), expected %v", v, err, GameVersionAlpha05)
+			}
+			// The rail is placed without going through the level, so it is only found if the level is scanned again.
+			c, err := p.Chunk(1, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.SetBlockID(cube.Pos{20, 4, 20}, 66); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.SetBlockID(test.pos, test.id); err != nil {
+				t.Fatal(err)
+			}
+			if v, err := p.gameVersion(); err != nil || v != test.want {
+				t.Errorf("got %v (%v), expected %v", v, err, test.want)
+			}
+		})
+	}
+}
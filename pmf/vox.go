@@ -0,0 +1,444 @@
+package pmf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// voxVersion is the version of the MagicaVoxel file format written by WriteVox.
+	voxVersion = 150
+	// voxModelSize is the maximum size of a MagicaVoxel model on every axis.
+	voxModelSize = 256
+	// maxVoxFileSize is the maximum size of a MagicaVoxel file that will be read.
+	maxVoxFileSize = 64 << 20
+)
+
+// VoxBlock is a block with a legacy ID and metadata value that a colour of a MagicaVoxel palette stands for.
+type VoxBlock struct {
+	ID, Meta byte
+}
+
+// VoxPalette maps the colour indices of a MagicaVoxel palette, from 1 to 255, to the blocks they stand for.
+type VoxPalette map[uint8]VoxBlock
+
+// WriteVox writes the blocks of the level inside a box as a MagicaVoxel .vox file. If the box is nil, the whole level
+// is written. Every block type, as far as its colour goes, gets its own palette entry coloured by BlockColour, and the
+// palette used is returned so that it can be passed to ImportVox later. Boxes larger than 256 blocks on an axis are
+// split into several models, placed next to each other in the scene.
+//
+// MagicaVoxel uses Z as its vertical axis, so the Y axis of the level becomes the Z axis of the models, and the Z axis
+// of the level is mirrored into the Y axis of the models to keep the map from being flipped.
+func (p *Level) WriteVox(w io.Writer, box *Box) (VoxPalette, error) {
+	r, err := p.readRegion(box)
+	if err != nil {
+		return nil, err
+	}
+	// The size of the scene in MagicaVoxel's axes.
+	sizeX, sizeY, sizeZ := r.sizeX, r.sizeZ, r.sizeY
+
+	palette := make(VoxPalette)
+	indices := make(map[VoxBlock]uint8)
+	children := &bytes.Buffer{}
+	var models [][2][3]int
+	for mx := 0; mx < sizeX; mx += voxModelSize {
+		for my := 0; my < sizeY; my += voxModelSize {
+			for mz := 0; mz < sizeZ; mz += voxModelSize {
+				size := [3]int{minInt(voxModelSize, sizeX-mx), minInt(voxModelSize, sizeY-my), minInt(voxModelSize, sizeZ-mz)}
+				voxels := &bytes.Buffer{}
+				n := 0
+				for x := 0; x < size[0]; x++ {
+					for y := 0; y < size[1]; y++ {
+						for z := 0; z < size[2]; z++ {
+							id, meta := r.block(mx+x, mz+z, r.sizeZ-1-(my+y))
+							if id == 0 {
+								continue
+							}
+							b := VoxBlock{ID: id, Meta: colourMeta(id, meta)}
+							i, ok := indices[b]
+							if !ok {
+								if len(indices) == 255 {
+									return nil, fmt.Errorf("more than 255 block types in box %v, which do not fit in a palette", box)
+								}
+								i = uint8(len(indices) + 1)
+								indices[b], palette[i] = i, b
+							}
+							voxels.Write([]byte{byte(x), byte(y), byte(z), i})
+							n++
+						}
+					}
+				}
+				writeVoxChunk(children, "SIZE", voxInts(size[0], size[1], size[2]), nil)
+				writeVoxChunk(children, "XYZI", append(voxInts(n), voxels.Bytes()...), nil)
+				models = append(models, [2][3]int{{mx, my, mz}, size})
+			}
+		}
+	}
+	writeVoxScene(children, models)
+
+	colours := &bytes.Buffer{}
+	for i := 1; i <= 256; i++ {
+		c := color.RGBA{A: 255}
+		if b, ok := palette[uint8(i)]; ok && i < 256 {
+			c, _ = BlockColour(b.ID, b.Meta)
+		}
+		colours.Write([]byte{c.R, c.G, c.B, c.A})
+	}
+	writeVoxChunk(children, "RGBA", colours.Bytes(), nil)
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("VOX ")
+	buf.Write(voxInts(voxVersion))
+	writeVoxChunk(buf, "MAIN", nil, children.Bytes())
+	_, err = w.Write(buf.Bytes())
+	return palette, err
+}
+
+// writeVoxScene writes the scene graph that places the models passed, each with the position of its minimum corner and
+// its size, in the scene: a root transform with a group holding a transform and shape for every model.
+func writeVoxScene(buf *bytes.Buffer, models [][2][3]int) {
+	writeVoxChunk(buf, "nTRN", voxTransform(0, 1, -1, nil), nil)
+	group := voxInts(1)
+	group = append(group, voxDict(nil)...)
+	group = append(group, voxInts(len(models))...)
+	for i := range models {
+		group = append(group, voxInts(2+i*2)...)
+	}
+	writeVoxChunk(buf, "nGRP", group, nil)
+	for i, m := range models {
+		// MagicaVoxel translates models by their centre rather than by their minimum corner.
+		t := fmt.Sprintf("%v %v %v", m[0][0]+m[1][0]/2, m[0][1]+m[1][1]/2, m[0][2]+m[1][2]/2)
+		writeVoxChunk(buf, "nTRN", voxTransform(2+i*2, 3+i*2, 0, map[string]string{"_t": t}), nil)
+		shape := voxInts(3 + i*2)
+		shape = append(shape, voxDict(nil)...)
+		shape = append(shape, voxInts(1, i)...)
+		shape = append(shape, voxDict(nil)...)
+		writeVoxChunk(buf, "nSHP", shape, nil)
+	}
+}
+
+// voxTransform encodes the content of a transform node with a single frame.
+func voxTransform(node, child, layer int, frame map[string]string) []byte {
+	b := voxInts(node)
+	b = append(b, voxDict(nil)...)
+	b = append(b, voxInts(child, -1, layer, 1)...)
+	return append(b, voxDict(frame)...)
+}
+
+// writeVoxChunk writes a MagicaVoxel chunk with an ID, content and children to a buffer.
+func writeVoxChunk(buf *bytes.Buffer, id string, content, children []byte) {
+	buf.WriteString(id)
+	buf.Write(voxInts(len(content), len(children)))
+	buf.Write(content)
+	buf.Write(children)
+}
+
+// voxInts encodes a list of integers as little endian int32s.
+func voxInts(v ...int) []byte {
+	b := make([]byte, len(v)*4)
+	for i, n := range v {
+		binary.LittleEndian.PutUint32(b[i*4:], uint32(int32(n)))
+	}
+	return b
+}
+
+// voxDict encodes a MagicaVoxel dictionary, with its keys sorted.
+func voxDict(d map[string]string) []byte {
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b := voxInts(len(d))
+	for _, k := range keys {
+		b = append(b, voxInts(len(k))...)
+		b = append(b, k...)
+		b = append(b, voxInts(len(d[k]))...)
+		b = append(b, d[k]...)
+	}
+	return b
+}
+
+// voxModel is a model read from a MagicaVoxel file.
+type voxModel struct {
+	size   [3]int
+	voxels []byte
+	// offset is the position of the minimum corner of the model in the scene.
+	offset [3]int
+}
+
+// voxNode is a node of the scene graph of a MagicaVoxel file.
+type voxNode struct {
+	kind        string
+	translation [3]int
+	children    []int
+	model       int
+}
+
+// ImportVox reads a MagicaVoxel .vox file and places its voxels in the level, with the minimum corner of the scene at
+// the origin passed. The colour indices of the voxels are turned into blocks using the palette passed, such as the
+// one returned by WriteVox. Colour indices missing from the palette, or all of them if the palette is nil, are turned
+// into the full block with the colour closest to the colour in the file. Models are placed at the translations of the
+// scene graph, but rotations are not applied. Voxels that end up outside the level are left out. The changes are
+// written to disk by Save.
+func (p *Level) ImportVox(r io.Reader, origin cube.Pos, palette VoxPalette) error {
+	data, err := io.ReadAll(io.LimitReader(r, maxVoxFileSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxVoxFileSize {
+		return fmt.Errorf("vox file exceeds the maximum size of %v bytes", maxVoxFileSize)
+	}
+	if len(data) < 20 || string(data[:4]) != "VOX " || string(data[8:12]) != "MAIN" {
+		return fmt.Errorf("not a MagicaVoxel file")
+	}
+	mainSize := binary.LittleEndian.Uint32(data[12:])
+	if uint64(mainSize) > uint64(len(data)-20) {
+		return fmt.Errorf("MAIN chunk exceeds the vox file")
+	}
+	data = data[20+mainSize:]
+
+	var models []*voxModel
+	var colours []color.RGBA
+	nodes := make(map[int]*voxNode)
+	for len(data) > 0 {
+		if len(data) < 12 {
+			return fmt.Errorf("unexpected end of vox file")
+		}
+		id, contentSize, childrenSize := string(data[:4]), binary.LittleEndian.Uint32(data[4:]), binary.LittleEndian.Uint32(data[8:])
+		if uint64(contentSize)+uint64(childrenSize) > uint64(len(data)-12) {
+			return fmt.Errorf("%v chunk exceeds the vox file", id)
+		}
+		d := &voxReader{b: data[12 : 12+contentSize]}
+		data = data[12+contentSize+childrenSize:]
+
+		switch id {
+		case "SIZE":
+			models = append(models, &voxModel{size: [3]int{d.int(), d.int(), d.int()}})
+		case "XYZI":
+			if len(models) == 0 {
+				return fmt.Errorf("XYZI chunk without SIZE chunk")
+			}
+			n := d.int()
+			if n < 0 || n*4 > len(d.b) {
+				return fmt.Errorf("invalid voxel count %v", n)
+			}
+			models[len(models)-1].voxels = d.bytes(n * 4)
+		case "RGBA":
+			for i := 0; i < 256; i++ {
+				c := d.bytes(4)
+				if c == nil {
+					return fmt.Errorf("RGBA chunk too short")
+				}
+				colours = append(colours, color.RGBA{R: c[0], G: c[1], B: c[2], A: c[3]})
+			}
+		case "nTRN":
+			node := &voxNode{kind: id}
+			nodeID := d.int()
+			d.dict()
+			node.children = []int{d.int()}
+			d.int()
+			d.int()
+			if frames := d.int(); frames > 0 {
+				if t, ok := d.dict()["_t"]; ok {
+					fields := strings.Fields(t)
+					for i := 0; i < 3 && i < len(fields); i++ {
+						node.translation[i], _ = strconv.Atoi(fields[i])
+					}
+				}
+			}
+			nodes[nodeID] = node
+		case "nGRP":
+			node := &voxNode{kind: id}
+			nodeID := d.int()
+			d.dict()
+			for n := d.int(); n > 0 && d.err == nil; n-- {
+				node.children = append(node.children, d.int())
+			}
+			nodes[nodeID] = node
+		case "nSHP":
+			node := &voxNode{kind: id}
+			nodeID := d.int()
+			d.dict()
+			if d.int() > 0 {
+				node.model = d.int()
+			}
+			nodes[nodeID] = node
+		}
+		if d.err != nil {
+			return fmt.Errorf("error reading %v chunk: %w", id, d.err)
+		}
+	}
+	if len(nodes) > 0 {
+		if err := placeVoxModels(nodes, models); err != nil {
+			return err
+		}
+	}
+
+	// The bounds of the scene, in MagicaVoxel's axes.
+	min := [3]int{math.MaxInt32, math.MaxInt32, math.MaxInt32}
+	max := [3]int{math.MinInt32, math.MinInt32, math.MinInt32}
+	for _, m := range models {
+		for i := 0; i < 3; i++ {
+			min[i] = minInt(min[i], m.offset[i])
+			max[i] = maxInt(max[i], m.offset[i]+m.size[i]-1)
+		}
+	}
+	bounds := p.Bounds()
+	for _, m := range models {
+		for i := 0; i+4 <= len(m.voxels); i += 4 {
+			v := m.voxels[i : i+4]
+			b, ok := palette[v[3]]
+			if !ok {
+				if int(v[3]) > len(colours) || v[3] == 0 {
+					return fmt.Errorf("no block for colour index %v", v[3])
+				}
+				b = nearestBlock(colours[v[3]-1])
+			}
+			pos := cube.Pos{
+				origin.X() + m.offset[0] + int(v[0]) - min[0],
+				origin.Y() + m.offset[2] + int(v[2]) - min[2],
+				origin.Z() + max[1] - (m.offset[1] + int(v[1])),
+			}
+			if !bounds.Contains(pos) {
+				continue
+			}
+			if err := p.SetBlockID(pos, b.ID); err != nil {
+				return err
+			}
+			if err := p.SetBlockMeta(pos, b.Meta); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// placeVoxModels walks the scene graph of a MagicaVoxel file from the root node, adding up the translations of
+// transform nodes, and sets the offset of the models of the shape nodes it reaches. The scene graph must be a tree:
+// every node is visited at most once, so that graphs sharing nodes between parents, which could otherwise reach the
+// same node an exponential amount of times, or holding cycles, are refused.
+func placeVoxModels(nodes map[int]*voxNode, models []*voxModel) error {
+	type step struct {
+		id          int
+		translation [3]int
+	}
+	visited := make(map[int]bool, len(nodes))
+	stack := []step{{id: 0}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		node, ok := nodes[s.id]
+		if !ok {
+			return fmt.Errorf("scene graph node %v not found", s.id)
+		}
+		if visited[s.id] {
+			return fmt.Errorf("scene graph node %v is reached more than once", s.id)
+		}
+		visited[s.id] = true
+
+		switch node.kind {
+		case "nTRN":
+			for i := range s.translation {
+				s.translation[i] += node.translation[i]
+			}
+		case "nSHP":
+			if node.model < 0 || node.model >= len(models) {
+				return fmt.Errorf("shape node %v refers to unknown model %v", s.id, node.model)
+			}
+			m := models[node.model]
+			for i := range s.translation {
+				m.offset[i] = s.translation[i] - m.size[i]/2
+			}
+			continue
+		}
+		for _, child := range node.children {
+			stack = append(stack, step{id: child, translation: s.translation})
+		}
+	}
+	return nil
+}
+
+// nearestBlock returns the full block whose colour is closest to the colour passed.
+func nearestBlock(c color.RGBA) VoxBlock {
+	var best VoxBlock
+	bestDist := math.MaxInt32
+	try := func(id, meta byte) {
+		bc, _ := BlockColour(id, meta)
+		dr, dg, db := int(bc.R)-int(c.R), int(bc.G)-int(c.G), int(bc.B)-int(c.B)
+		if dist := dr*dr + dg*dg + db*db; dist < bestDist {
+			best, bestDist = VoxBlock{ID: id, Meta: meta}, dist
+		}
+	}
+	for id := 1; id < 256; id++ {
+		if _, ok := blockColours[byte(id)]; !ok || nonFullBlocks[byte(id)] {
+			continue
+		}
+		try(byte(id), 0)
+		if m := colourMeta(byte(id), 0xf); m != 0 {
+			for meta := 1; meta <= int(m); meta++ {
+				try(byte(id), byte(meta))
+			}
+		}
+	}
+	for meta := 0; meta < 16; meta++ {
+		try(35, byte(meta))
+		try(159, byte(meta))
+	}
+	return best
+}
+
+// voxReader reads the content of a MagicaVoxel chunk. After the first read past the end of the content, all reads
+// return zero values and err is set.
+type voxReader struct {
+	b   []byte
+	err error
+}
+
+// bytes reads n bytes.
+func (r *voxReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.b) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+// int reads a little endian int32.
+func (r *voxReader) int() int {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return int(int32(binary.LittleEndian.Uint32(b)))
+}
+
+// string reads a string prefixed by its length.
+func (r *voxReader) string() string {
+	n := r.int()
+	if n < 0 {
+		r.err = fmt.Errorf("invalid string length %v", n)
+		return ""
+	}
+	return string(r.bytes(n))
+}
+
+// dict reads a dictionary of strings.
+func (r *voxReader) dict() map[string]string {
+	d := make(map[string]string)
+	for n := r.int(); n > 0 && r.err == nil; n-- {
+		k := r.string()
+		d[k] = r.string()
+	}
+	return d
+}
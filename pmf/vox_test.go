@@ -0,0 +1,142 @@
+package pmf
+
+import (
+	"bytes"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"testing"
+)
+
+// TestVoxRoundTrip tests that blocks written by WriteVox are imported back in the same place relative to the origin,
+// using the palette returned.
+func TestVoxRoundTrip(t *testing.T) {
+	blocks := map[cube.Pos][2]byte{
+		{2, 4, 3}:   {41, 0},  // Gold block.
+		{9, 6, 12}:  {35, 14}, // Red wool.
+		{9, 7, 12}:  {35, 11}, // Blue wool.
+		{20, 9, 30}: {5, 2},   // Birch planks.
+	}
+	tests := []struct {
+		name   string
+		box    *Box
+		origin cube.Pos
+		// shift is the offset of the imported blocks from the blocks written.
+		shift cube.Pos
+	}{
+		{name: "level"},
+		{name: "box", box: &Box{Min: cube.Pos{2, 4, 3}, Max: cube.Pos{20, 9, 30}}, origin: cube.Pos{2, 4, 3}},
+		{name: "moved box", box: &Box{Min: cube.Pos{1, 3, 1}, Max: cube.Pos{21, 12, 31}}, origin: cube.Pos{0, 20, 0}, shift: cube.Pos{-1, 17, -1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			palette, err := newTestLevel(t, blocks).WriteVox(buf, test.box)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := newTestLevel(t, nil)
+			if err := p.ImportVox(buf, test.origin, palette); err != nil {
+				t.Fatal(err)
+			}
+			for pos, b := range blocks {
+				pos = pos.Add(test.shift)
+				id, _ := p.BlockID(pos)
+				meta, _ := p.BlockMeta(pos)
+				if id != b[0] || meta != b[1] {
+					t.Errorf("got %v:%v at %v, expected %v:%v", id, meta, pos, b[0], b[1])
+				}
+			}
+		})
+	}
+}
+
+// voxGroup encodes the content of a group node with the children passed.
+func voxGroup(node int, children ...int) []byte {
+	b := voxInts(node)
+	b = append(b, voxDict(nil)...)
+	b = append(b, voxInts(len(children))...)
+	return append(b, voxInts(children...)...)
+}
+
+// voxShape encodes the content of a shape node of a model.
+func voxShape(node, model int) []byte {
+	b := voxInts(node)
+	b = append(b, voxDict(nil)...)
+	b = append(b, voxInts(1, model)...)
+	return append(b, voxDict(nil)...)
+}
+
+// TestImportVoxScene tests that models are placed by the scene graph of a MagicaVoxel file, and that graphs that are
+// not trees are refused without walking them.
+func TestImportVoxScene(t *testing.T) {
+	// Every model is 2x2x2 voxels, with a single voxel of the first colour at its minimum corner.
+	tests := []struct {
+		name  string
+		scene func(buf *bytes.Buffer)
+		// blocks holds the positions of the blocks placed, relative to the origin.
+		blocks []cube.Pos
+		ok     bool
+	}{
+		{name: "written scene", scene: func(buf *bytes.Buffer) {
+			writeVoxScene(buf, [][2][3]int{{{0, 0, 0}, {2, 2, 2}}, {{4, 0, 0}, {2, 2, 2}}})
+		}, blocks: []cube.Pos{{0, 0, 1}, {4, 0, 1}}, ok: true},
+		{name: "no scene", blocks: []cube.Pos{{0, 0, 1}}, ok: true},
+		{name: "shared child", scene: func(buf *bytes.Buffer) {
+			writeVoxChunk(buf, "nTRN", voxTransform(0, 1, -1, nil), nil)
+			writeVoxChunk(buf, "nGRP", voxGroup(1, 2, 2), nil)
+			writeVoxChunk(buf, "nSHP", voxShape(2, 0), nil)
+		}},
+		{name: "cycle", scene: func(buf *bytes.Buffer) {
+			writeVoxChunk(buf, "nTRN", voxTransform(0, 1, -1, nil), nil)
+			writeVoxChunk(buf, "nGRP", voxGroup(1, 0), nil)
+		}},
+		{name: "exponential", scene: func(buf *bytes.Buffer) {
+			// Without tracking the nodes visited, walking this graph would visit the shape 2^64 times.
+			writeVoxChunk(buf, "nTRN", voxTransform(0, 1, -1, nil), nil)
+			for i := 1; i <= 64; i++ {
+				writeVoxChunk(buf, "nGRP", voxGroup(i, i+1, i+1), nil)
+			}
+			writeVoxChunk(buf, "nSHP", voxShape(65, 0), nil)
+		}},
+		{name: "missing node", scene: func(buf *bytes.Buffer) {
+			writeVoxChunk(buf, "nTRN", voxTransform(0, 1, -1, nil), nil)
+		}},
+		{name: "unknown model", scene: func(buf *bytes.Buffer) {
+			writeVoxChunk(buf, "nTRN", voxTransform(0, 1, -1, nil), nil)
+			writeVoxChunk(buf, "nSHP", voxShape(1, 5), nil)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			children := &bytes.Buffer{}
+			for i := 0; i < 2; i++ {
+				writeVoxChunk(children, "SIZE", voxInts(2, 2, 2), nil)
+				writeVoxChunk(children, "XYZI", append(voxInts(1), 0, 0, 0, 1), nil)
+			}
+			if test.scene != nil {
+				test.scene(children)
+			}
+			buf := &bytes.Buffer{}
+			buf.WriteString("VOX ")
+			buf.Write(voxInts(voxVersion))
+			writeVoxChunk(buf, "MAIN", nil, children.Bytes())
+
+			origin := cube.Pos{4, 10, 4}
+			p := newTestLevel(t, nil)
+			err := p.ImportVox(buf, origin, VoxPalette{1: {ID: 41}})
+			if !test.ok {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, pos := range test.blocks {
+				if id, _ := p.BlockID(origin.Add(pos)); id != 41 {
+					t.Errorf("got block %v at %v, expected a gold block", id, origin.Add(pos))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/justtaldevelops/pmf/pmf"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// viewerPage is the web page of the map viewer served by the serve subcommand.
+//
+//go:embed serve.html
+var viewerPage []byte
+
+// viewer serves a web based viewer for a PMF level. Levels are not safe for concurrent use, so all requests lock the
+// mutex of the viewer before reading from the level.
+type viewer struct {
+	mu    sync.Mutex
+	level *pmf.Level
+}
+
+// runServe runs the serve subcommand, which serves a map viewer for a PMF world over HTTP.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:8080", "the address to serve the viewer on")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pmf serve [-addr host:port] <world>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one world, got %v", fs.NArg())
+	}
+
+	level, err := pmf.DecodeLevel(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error decoding %v: %w", fs.Arg(0), err)
+	}
+	if level.GameVersion, err = level.DetectGameVersion(); err != nil {
+		return fmt.Errorf("error detecting game version: %w", err)
+	}
+	v := &viewer{level: level}
+
+	fmt.Printf("Serving %v on http://%v/\n", level.Name, *addr)
+	return http.ListenAndServe(*addr, v.handler())
+}
+
+// handler returns the handler that serves the viewer page and the API it uses.
+func (v *viewer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", v.handlePage)
+	mux.HandleFunc("/api/level", v.handleLevel)
+	mux.HandleFunc("/api/map.png", v.handleMap)
+	mux.HandleFunc("/api/block", v.handleBlock)
+	mux.HandleFunc("/api/tiles", v.handleTiles)
+	mux.HandleFunc("/api/entities", v.handleEntities)
+	return mux
+}
+
+// handlePage serves the viewer page.
+func (v *viewer) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(viewerPage)
+}
+
+// handleLevel serves the header of the level.
+func (v *viewer) handleLevel(w http.ResponseWriter, _ *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	writeJSON(w, map[string]interface{}{
+		"name":        v.level.Name,
+		"seed":        v.level.Seed,
+		"time":        v.level.Time,
+		"spawn":       v.level.Spawn,
+		"width":       v.level.Width,
+		"height":      v.level.Height,
+		"gameVersion": v.level.GameVersion.String(),
+	})
+}
+
+// handleMap serves a top-down map of the level as PNG, sliced at the Y level in the y query parameter.
+func (v *viewer) handleMap(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	box := v.level.Bounds()
+	if y, err := strconv.Atoi(r.URL.Query().Get("y")); err == nil {
+		box.Max = cube.Pos{box.Max.X(), y, box.Max.Z()}
+	}
+	// The map is rendered before anything is written, so that errors are not sent as a PNG.
+	buf := &bytes.Buffer{}
+	if err := v.level.WriteMapPNG(buf, &box); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// handleBlock serves the highest block at or below the Y level in the y query parameter in the column at the x and z
+// query parameters, which is the block shown on the map at that position.
+func (v *viewer) handleBlock(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	x, errX := strconv.Atoi(q.Get("x"))
+	y, errY := strconv.Atoi(q.Get("y"))
+	z, errZ := strconv.Atoi(q.Get("z"))
+	if errX != nil || errY != nil || errZ != nil {
+		http.Error(w, "x, y and z must be integers", http.StatusBadRequest)
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	bounds := v.level.Bounds()
+	if y > bounds.Max.Y() {
+		y = bounds.Max.Y()
+	}
+	for ; y >= 0; y-- {
+		pos := cube.Pos{x, y, z}
+		if !bounds.Contains(pos) {
+			break
+		}
+		id, err := v.level.BlockID(pos)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if id == 0 {
+			continue
+		}
+		meta, err := v.level.BlockMeta(pos)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		block := map[string]interface{}{"pos": pos, "id": id, "meta": meta}
+		if name, properties, err := v.level.Block(pos); err == nil {
+			block["name"], block["properties"] = name, properties
+		}
+		if t, ok := v.level.TileAt(pos); ok {
+			block["tile"] = pmf.TileData(t)
+		}
+		writeJSON(w, block)
+		return
+	}
+	writeJSON(w, nil)
+}
+
+// handleTiles serves all tiles of the level.
+func (v *viewer) handleTiles(w http.ResponseWriter, _ *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	tiles := make([]map[string]interface{}, 0)
+	for _, t := range v.level.Tiles() {
+		tiles = append(tiles, map[string]interface{}{"pos": t.Position(), "data": pmf.TileData(t)})
+	}
+	writeJSON(w, tiles)
+}
+
+// handleEntities serves all entities of the level.
+func (v *viewer) handleEntities(w http.ResponseWriter, _ *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	entities := v.level.Entities()
+	if entities == nil {
+		entities = []pmf.Entity{}
+	}
+	writeJSON(w, entities)
+}
+
+// writeJSON writes a value to an HTTP response as JSON.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/justtaldevelops/pmf/pmf"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newTestViewer serves a viewer for the test world over HTTP, which is closed when the test finishes.
+func newTestViewer(t *testing.T) *httptest.Server {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "world")
+	newTestWorld(t, dir)
+	level, err := pmf.DecodeLevel(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer((&viewer{level: level}).handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// get requests a path from the server and fails the test if the status code is not the one passed.
+func get(t *testing.T, srv *httptest.Server, path string, status int) *http.Response {
+	t.Helper()
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = resp.Body.Close()
+	})
+	if resp.StatusCode != status {
+		t.Fatalf("got status %v for %v, expected %v", resp.StatusCode, path, status)
+	}
+	return resp
+}
+
+// TestServeBlock tests that /api/block serves the highest block in a column at or below the Y level requested.
+func TestServeBlock(t *testing.T) {
+	srv := newTestViewer(t)
+	tests := []struct {
+		name  string
+		query string
+		// block holds the fields expected, or nil if no block is expected.
+		block map[string]interface{}
+		tile  bool
+	}{
+		{name: "sign", query: "x=3&y=100&z=3", block: map[string]interface{}{"id": 63.0, "name": "minecraft:standing_sign"}, tile: true},
+		{name: "below the sign", query: "x=3&y=4&z=3", block: map[string]interface{}{"id": 1.0, "name": "minecraft:stone"}},
+		{name: "other column", query: "x=20&y=2&z=9", block: map[string]interface{}{"id": 1.0, "pos": []interface{}{20.0, 2.0, 9.0}}},
+		{name: "above the level", query: "x=3&y=1000&z=3", block: map[string]interface{}{"id": 63.0, "pos": []interface{}{3.0, 5.0, 3.0}}, tile: true},
+		{name: "outside of the level", query: "x=40&y=10&z=3"},
+		{name: "negative", query: "x=-1&y=10&z=3"},
+		{name: "below the level", query: "x=3&y=-1&z=3"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var block map[string]interface{}
+			if err := json.NewDecoder(get(t, srv, "/api/block?"+test.query, http.StatusOK).Body).Decode(&block); err != nil {
+				t.Fatal(err)
+			}
+			if test.block == nil {
+				if block != nil {
+					t.Errorf("got block %v, expected none", block)
+				}
+				return
+			}
+			for k, v := range test.block {
+				if !reflect.DeepEqual(block[k], v) {
+					t.Errorf("got %v %v, expected %v", k, block[k], v)
+				}
+			}
+			if _, ok := block["tile"]; ok != test.tile {
+				t.Errorf("block has a tile: %v, expected %v", ok, test.tile)
+			}
+		})
+	}
+	get(t, srv, "/api/block?x=a&y=1&z=1", http.StatusBadRequest)
+}
+
+// TestServeMap tests that /api/map.png serves a map of the whole level that can be sliced at a Y level, and an error
+// that is not sent as a PNG if the slice is below the level.
+func TestServeMap(t *testing.T) {
+	srv := newTestViewer(t)
+	decode := func(path string) image.Image {
+		resp := get(t, srv, path, http.StatusOK)
+		if ct := resp.Header.Get("Content-Type"); ct != "image/png" {
+			t.Fatalf("got content type %v for %v", ct, path)
+		}
+		img, err := png.Decode(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds() != image.Rect(0, 0, 32, 32) {
+			t.Fatalf("got bounds %v for %v, expected 32x32", img.Bounds(), path)
+		}
+		return img
+	}
+	full, sliced := decode("/api/map.png"), decode("/api/map.png?y=4")
+
+	sign, _ := pmf.BlockColour(63, 0)
+	if c := full.At(3, 3); c != sign {
+		t.Errorf("got colour %v for the sign, expected %v", c, sign)
+	}
+	// Below the sign, the map shows the same stone as the columns around it.
+	if c, stone := sliced.At(3, 3), full.At(10, 10); c != stone {
+		t.Errorf("got colour %v below the sign, expected the colour of stone %v", c, stone)
+	}
+
+	resp := get(t, srv, "/api/map.png?y=-5", http.StatusBadRequest)
+	if ct := resp.Header.Get("Content-Type"); ct == "image/png" {
+		t.Error("error was sent as a PNG")
+	}
+}
+
+// TestServeEntities tests that /api/entities serves the entities of the level.
+func TestServeEntities(t *testing.T) {
+	srv := newTestViewer(t)
+	var entities []pmf.Entity
+	if err := json.NewDecoder(get(t, srv, "/api/entities", http.StatusOK).Body).Decode(&entities); err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 1 || entities[0].ID != 83 || entities[0].Data["Motive"] != "Kebab" {
+		t.Errorf("got entities %+v, expected the painting", entities)
+	}
+}
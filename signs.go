@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/mcdb"
+	"github.com/justtaldevelops/pmf/pmf"
+	"os"
+)
+
+// runSigns runs the signs subcommand, which exports the text of all signs in a PMF world for translation, or imports
+// translated text back into a PMF world or a world converted from it.
+func runSigns(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			return runSignsExport(args[1:])
+		case "import":
+			return runSignsImport(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "usage: pmf signs export [-json] <world>")
+	fmt.Fprintln(os.Stderr, "       pmf signs import [-json] [-converted] [-offsetx n] [-offsetz n] <sign file> <world>")
+	return fmt.Errorf("expected export or import")
+}
+
+// runSignsExport prints the text of all signs in a PMF world as CSV or JSON.
+func runSignsExport(args []string) error {
+	fs := flag.NewFlagSet("signs export", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the signs as JSON instead of CSV")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pmf signs export [-json] <world>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one world, got %v", fs.NArg())
+	}
+
+	level, err := pmf.DecodeLevel(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error decoding %v: %w", fs.Arg(0), err)
+	}
+	if *asJSON {
+		return pmf.WriteSignsJSON(stdout, level.Signs())
+	}
+	return pmf.WriteSignsCSV(stdout, level.Signs())
+}
+
+// runSignsImport writes the sign text in a CSV or JSON file into the tiles.yml of a PMF world, or into the sign block
+// entities of a converted world, and prints a report of missing signs and lines that are too long.
+func runSignsImport(args []string) error {
+	fs := flag.NewFlagSet("signs import", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "read the signs as JSON instead of CSV")
+	converted := fs.Bool("converted", false, "write the signs into a converted world instead of a PMF world")
+	offsetX := fs.Int("offsetx", 0, "the X offset in chunks that the converted world was converted with")
+	offsetZ := fs.Int("offsetz", 0, "the Z offset in chunks that the converted world was converted with")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pmf signs import [-json] [-converted] [-offsetx n] [-offsetz n] <sign file> <world>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected a sign file and a world, got %v arguments", fs.NArg())
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var signs []pmf.SignText
+	if *asJSON {
+		signs, err = pmf.ReadSignsJSON(bufio.NewReader(f))
+	} else {
+		signs, err = pmf.ReadSignsCSV(bufio.NewReader(f))
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %v: %w", fs.Arg(0), err)
+	}
+
+	var report pmf.SignImportReport
+	if *converted {
+		prov, err := mcdb.New(fs.Arg(1))
+		if err != nil {
+			return fmt.Errorf("error opening %v: %w", fs.Arg(1), err)
+		}
+		report, err = pmf.ImportConvertedSigns(prov, signs, world.ChunkPos{int32(*offsetX), int32(*offsetZ)})
+		if closeErr := prov.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		level, err := pmf.DecodeLevel(fs.Arg(1))
+		if err != nil {
+			return fmt.Errorf("error decoding %v: %w", fs.Arg(1), err)
+		}
+		report = level.ImportSigns(signs)
+		if err := level.SaveTiles(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprint(stdout, report)
+	return nil
+}